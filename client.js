@@ -41,26 +41,40 @@ const questionBuilder = async () => {
 //     });
 // }
 
-const outputBuilder = async (questions) => {
-    for (const question of questions) {
-        console.log(`Processing question: ${question}`);
-        
-        try {
-            const response = await axios.get(`http://localhost:3000/campaigns/bssqmz`, {
-                params: { text: question }
-            });
-            console.log(`Success for "${question}":`, response.data);
-        } catch (error) {
-            console.error(`Error for "${question}":`, 
-                error.response ? error.response.data : error.message
-            );
+const SITE_CODE = "bssqmz";
+const BASE_URL = "http://localhost:3000";
+
+const createRun = async () => {
+    const response = await axios.post(`${BASE_URL}/eval-runs/${SITE_CODE}`, { source: "client.js" });
+    console.log(`Created evaluation run ${response.data.run_id} in ${response.data.output_dir}`);
+    return response.data.run_id;
+}
+
+const outputBuilder = async (questions, runId) => {
+    try {
+        const response = await axios.post(`${BASE_URL}/campaigns/${SITE_CODE}/batch`, {
+            queries: questions,
+            run_id: runId
+        });
+        for (const [question, outcome] of Object.entries(response.data.results)) {
+            if (outcome.error) {
+                console.error(`Error for "${question}":`, outcome.error);
+                continue;
+            }
+            console.log(`Success for "${question}" in ${outcome.timings.total_ms}ms:`, outcome.results);
         }
+        console.log(`Batch finished in ${response.data.total_ms}ms with ${response.data.failed} failures`);
+    } catch (error) {
+        console.error("Batch request failed:",
+            error.response ? error.response.data : error.message
+        );
     }
 }
 
 (async () => {
     const questions = await questionBuilder();
     console.log(`Total questions to process: ${questions.length}`);
-    await outputBuilder(questions);
+    const runId = await createRun();
+    await outputBuilder(questions, runId);
     console.log("All questions processed.");
 })();
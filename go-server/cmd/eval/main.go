@@ -0,0 +1,301 @@
+// Command eval posts the labeled question set to a running short-code-mapper
+// and prints precision@k, recall@k, MRR and nDCG overall and per intent,
+// then every missed expected product grouped by cause and by product.
+// Expected products that do not resolve to one mapped name are printed
+// before the run; -strict stops there. -html writes the run as an HTML
+// page, and -compare scores it against an earlier -out report question by
+// question; with -head two saved reports are compared without a run.
+//
+//	go run ./cmd/eval -site bssqmz -questions ../questions.txt -k 5
+//	go run ./cmd/eval -site bssqmz -golden-set-version 0 -k 5 -out run.json -html run.html
+//	go run ./cmd/eval -site bssqmz -k 5 -compare run.json -html compare.html
+//	go run ./cmd/eval -compare before.json -head after.json -html compare.html
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/types/consts"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:3000", "short-code-mapper base URL")
+	siteCode := flag.String("site", "", "site code to evaluate (required)")
+	questionsFile := flag.String("questions", consts.DefaultQuestionsFile, "labeled questions file")
+	k := flag.Int("k", consts.DefaultEvalK, "cutoff used for @k metrics")
+	out := flag.String("out", "", "optional path to write the full JSON report")
+	goldenSetVersion := flag.Int("golden-set-version", -1, "evaluate a version of the site's stored golden set instead of the questions file (0 is the latest)")
+	strict := flag.Bool("strict", false, "do not run when an expected product is ambiguous or unresolved")
+	htmlOut := flag.String("html", "", "optional path to write the report, or the comparison with -compare, as an HTML page")
+	compare := flag.String("compare", "", "optional JSON report of an earlier run (-out) to compare this run against")
+	head := flag.String("head", "", "JSON report to compare against -compare instead of running an evaluation")
+	flag.Parse()
+
+	if *head != "" {
+		if *compare == "" {
+			log.Fatalf("-head needs a -compare report to compare against")
+		}
+		headReport, err := readReport(*head)
+		if err != nil {
+			log.Fatalf("reading head report: %v", err)
+		}
+		compareReport(*compare, headReport, *htmlOut)
+		return
+	}
+	if *siteCode == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	base := fmt.Sprintf("%s/evaluations/%s", strings.TrimRight(*server, "/"), url.PathEscape(*siteCode))
+	endpoint := fmt.Sprintf("%s?k=%d", base, *k)
+	labelsEndpoint := base + "/labels"
+	var body []byte
+	if *goldenSetVersion >= 0 {
+		endpoint += fmt.Sprintf("&golden_set_version=%d", *goldenSetVersion)
+		labelsEndpoint += fmt.Sprintf("?golden_set_version=%d", *goldenSetVersion)
+		fmt.Printf("Evaluating golden set version %d of %s (k=%d)\n", *goldenSetVersion, *siteCode, *k)
+	} else {
+		// parse locally first so malformed lines are reported before any search runs
+		var err error
+		body, err = os.ReadFile(*questionsFile)
+		if err != nil {
+			log.Fatalf("reading questions: %v", err)
+		}
+		questions, err := eval.ParseQuestions(bytes.NewReader(body))
+		if err != nil {
+			log.Fatalf("parsing questions: %v", err)
+		}
+		fmt.Printf("Evaluating %d questions against %s (k=%d)\n", len(questions), *siteCode, *k)
+	}
+
+	labels, err := resolveLabels(labelsEndpoint, body)
+	if err != nil {
+		log.Fatalf("resolving expected products: %v", err)
+	}
+	printLabels(labels)
+	if *strict && !labels.Clean() {
+		log.Fatalf("%d ambiguous and %d unresolved expected products, fix the labels or run without -strict", len(labels.Ambiguous), len(labels.Unresolved))
+	}
+
+	resp, err := http.Post(endpoint, "text/csv", bytes.NewReader(body))
+	if err != nil {
+		log.Fatalf("calling %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("reading response: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		log.Fatalf("evaluation failed (%d): %s", resp.StatusCode, respBody)
+	}
+
+	var report eval.Report
+	if err := json.Unmarshal(respBody, &report); err != nil {
+		log.Fatalf("decoding report: %v", err)
+	}
+	if *out != "" {
+		if err := os.WriteFile(*out, respBody, 0644); err != nil {
+			log.Fatalf("writing report: %v", err)
+		}
+	}
+	printReport(&report)
+	if *compare != "" {
+		compareReport(*compare, &report, *htmlOut)
+	} else if *htmlOut != "" {
+		if err := writeHTML(*htmlOut, func(w io.Writer) error { return eval.WriteHTMLReport(w, &report) }); err != nil {
+			log.Fatalf("writing HTML report: %v", err)
+		}
+	}
+}
+
+// compareReport scores a report against the base report at path, prints
+// the questions that regressed or improved and writes the side-by-side
+// page when htmlOut is set.
+func compareReport(path string, head *eval.Report, htmlOut string) {
+	base, err := readReport(path)
+	if err != nil {
+		log.Fatalf("reading base report: %v", err)
+	}
+	comparison := eval.CompareReports(base, head)
+	printComparison(comparison)
+	if htmlOut != "" {
+		if err := writeHTML(htmlOut, func(w io.Writer) error { return eval.WriteHTMLComparison(w, comparison) }); err != nil {
+			log.Fatalf("writing HTML comparison: %v", err)
+		}
+	}
+}
+
+func readReport(path string) (*eval.Report, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report eval.Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func writeHTML(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// resolveLabels asks the server how the expected products resolve to the
+// names of the site's latest mapping.
+func resolveLabels(endpoint string, body []byte) (*eval.LabelReport, error) {
+	resp, err := http.Post(endpoint, "text/csv", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%d: %s", resp.StatusCode, respBody)
+	}
+	var labels eval.LabelReport
+	if err := json.Unmarshal(respBody, &labels); err != nil {
+		return nil, err
+	}
+	return &labels, nil
+}
+
+func printLabels(labels *eval.LabelReport) {
+	fmt.Printf("%d expected products against mapping version %d: %d exact, %d normalized, %d fuzzy, %d ambiguous, %d unresolved\n",
+		labels.Labels, labels.MappingVersion, labels.Exact, len(labels.Normalized), len(labels.Fuzzy), len(labels.Ambiguous), len(labels.Unresolved))
+	if len(labels.Fuzzy)+len(labels.Ambiguous)+len(labels.Unresolved) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MATCH\tLABEL\tMAPPED NAME\tQUESTIONS")
+	for _, group := range [][]eval.LabelIssue{labels.Fuzzy, labels.Ambiguous, labels.Unresolved} {
+		for _, issue := range group {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", issue.Match, issue.Label, mappedNames(issue.NameResolution), len(issue.Questions))
+		}
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+// mappedNames is the name a label resolved to, or the closest candidates.
+func mappedNames(resolution eval.NameResolution) string {
+	if resolution.Resolved() {
+		return fmt.Sprintf("%s (%.2f)", resolution.Name, resolution.Score)
+	}
+	candidates := make([]string, 0, len(resolution.Candidates))
+	for _, candidate := range resolution.Candidates {
+		candidates = append(candidates, fmt.Sprintf("%s? (%.2f)", candidate.Name, candidate.Score))
+	}
+	if len(candidates) == 0 {
+		return "-"
+	}
+	return strings.Join(candidates, ", ")
+}
+
+func printReport(report *eval.Report) {
+	fmt.Printf("Run %s\n", report.RunID)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "INTENT\tN\tP@%d\tR@%d\tMRR\tNDCG@%d\n", report.K, report.K, report.K)
+
+	intents := make([]string, 0, len(report.ByIntent))
+	for intent := range report.ByIntent {
+		intents = append(intents, intent)
+	}
+	sort.Strings(intents)
+	for _, intent := range intents {
+		printMetrics(w, intent, report.ByIntent[intent])
+	}
+	printMetrics(w, "Overall", report.Overall)
+	w.Flush()
+
+	for _, result := range report.Results {
+		if result.Error != "" {
+			fmt.Printf("error for %q: %s\n", result.Question, result.Error)
+		}
+	}
+	if report.Misses != nil && report.Misses.Total > 0 {
+		printMisses(report.Misses)
+	}
+}
+
+func printMisses(misses *eval.MissReport) {
+	fmt.Printf("\n%d missed expected products (deep search to %d)\n", misses.Total, misses.DeepK)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CAUSE\tN")
+	for _, group := range misses.ByCause {
+		fmt.Fprintf(w, "%s\t%d\n", group.Cause, group.Count)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT\tSHORT CODE\tMISSES\tCAUSES")
+	for _, product := range misses.ByProduct {
+		causes := make([]string, 0, len(product.Causes))
+		for _, cause := range eval.MissCauses {
+			if n := product.Causes[cause]; n > 0 {
+				causes = append(causes, fmt.Sprintf("%s=%d", cause, n))
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", product.Product, product.ShortCode, product.Misses, strings.Join(causes, " "))
+	}
+	w.Flush()
+}
+
+func printComparison(comparison *eval.Comparison) {
+	fmt.Printf("\nAgainst %s: %d regressed, %d improved, %d unchanged, %d added, %d removed\n",
+		comparison.Base.RunID, comparison.Regressed, comparison.Improved, comparison.Unchanged, comparison.Added, comparison.Removed)
+	if comparison.Base.K != comparison.Head.K {
+		fmt.Printf("the runs were scored at different cutoffs: k=%d and k=%d\n", comparison.Base.K, comparison.Head.K)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTENT\tNDCG BASE\tNDCG HEAD\tDELTA")
+	for _, intent := range comparison.ByIntent {
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%+.3f\n", intent.Intent, intent.Base.NDCG, intent.Head.NDCG, intent.Head.NDCG-intent.Base.NDCG)
+	}
+	w.Flush()
+
+	if comparison.Regressed+comparison.Improved == 0 {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tQUESTION\tNDCG BASE\tNDCG HEAD\tLOST\tFOUND")
+	for _, question := range comparison.Questions {
+		if question.Change != eval.ChangeRegressed && question.Change != eval.ChangeImproved {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.3f\t%.3f\t%s\t%s\n", question.Change, question.Question, question.Base.NDCG, question.Head.NDCG,
+			strings.Join(question.Lost, ", "), strings.Join(question.Gained, ", "))
+	}
+	w.Flush()
+}
+
+func printMetrics(w io.Writer, label string, m eval.Metrics) {
+	fmt.Fprintf(w, "%s\t%d\t%.3f\t%.3f\t%.3f\t%.3f\n", label, m.Questions, m.Precision, m.Recall, m.MRR, m.NDCG)
+}
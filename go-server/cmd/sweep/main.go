@@ -0,0 +1,285 @@
+// Command sweep runs the labeled question set through a running
+// short-code-mapper once, with a large candidate pool and no cutoffs, then
+// replays every top_k × min_score cutoff of a grid offline. It prints the
+// recommended cutoff per site code and writes every point, with precision,
+// recall and F1 overall and per intent, as CSV and JSON.
+//
+//	go run ./cmd/sweep -site bssqmz,abc123 -top-k 1,3,5,10 -min-score auto
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:3000", "short-code-mapper base URL")
+	sites := flag.String("site", "", "comma separated site codes to sweep (required)")
+	questionsFile := flag.String("questions", consts.DefaultQuestionsFile, "labeled questions file")
+	mode := flag.String("mode", "", "retrieval mode, the server default when empty")
+	reranker := flag.String("reranker", "", "reranker, the server default when empty")
+	pool := flag.Int("pool", consts.MaxTopK, "candidates retrieved per question")
+	topKs := flag.String("top-k", "1,2,3,5,10,20", "comma separated top_k values")
+	minScores := flag.String("min-score", "auto", `comma separated min_score values, or "auto" for the deciles of the returned vector scores`)
+	csvOut := flag.String("csv", "sweep_results.csv", "path of the CSV with every point")
+	jsonOut := flag.String("json", "sweep_report.json", "path of the JSON report")
+	flag.Parse()
+
+	if *sites == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *pool <= 0 || *pool > consts.MaxTopK {
+		log.Fatalf("-pool must be between 1 and %d", consts.MaxTopK)
+	}
+	topKGrid, err := parseInts(*topKs)
+	if err != nil {
+		log.Fatalf("parsing -top-k: %v", err)
+	}
+	var scoreGrid []float64
+	if *minScores != "auto" {
+		if scoreGrid, err = parseFloats(*minScores); err != nil {
+			log.Fatalf("parsing -min-score: %v", err)
+		}
+	}
+
+	questions, err := eval.LoadQuestions(*questionsFile)
+	if err != nil {
+		log.Fatalf("reading questions: %v", err)
+	}
+
+	var reports []*eval.SweepReport
+	for _, siteCode := range strings.Split(*sites, ",") {
+		siteCode = strings.TrimSpace(siteCode)
+		if siteCode == "" {
+			continue
+		}
+		mapping, err := fetchMapping(*server, siteCode)
+		if err != nil {
+			log.Fatalf("loading the mapping of %s: %v", siteCode, err)
+		}
+		// expected products are scored by the names the mapping gives them
+		siteQuestions, labels := eval.ResolveLabels(questions, eval.NewNameResolver(mapping), mapping.Version)
+		if !labels.Clean() {
+			fmt.Printf("%d ambiguous and %d unresolved expected products in %s are scored as written\n", len(labels.Ambiguous), len(labels.Unresolved), siteCode)
+		}
+		fmt.Printf("Retrieving %d candidates for %d questions of %s\n", *pool, len(siteQuestions), siteCode)
+		candidates, resolvedMode, err := retrieve(*server, siteCode, siteQuestions, *mode, *reranker, *pool)
+		if err != nil {
+			log.Fatalf("searching %s: %v", siteCode, err)
+		}
+		grid := scoreGrid
+		if grid == nil {
+			grid = eval.ScoreGrid(candidates)
+		}
+		report := eval.Sweep(siteCode, candidates, topKGrid, grid)
+		report.Mode = resolvedMode
+		report.Reranker = *reranker
+		reports = append(reports, report)
+	}
+
+	if err := writeCSV(*csvOut, reports); err != nil {
+		log.Fatalf("writing %s: %v", *csvOut, err)
+	}
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		log.Fatalf("encoding report: %v", err)
+	}
+	if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+		log.Fatalf("writing %s: %v", *jsonOut, err)
+	}
+	printRecommendations(reports)
+	fmt.Printf("Wrote %s and %s\n", *csvOut, *jsonOut)
+}
+
+// fetchMapping loads the latest mapping of a site code.
+func fetchMapping(server string, siteCode string) (*models.MappingData, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/mappings/%s", strings.TrimRight(server, "/"), url.PathEscape(siteCode)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%d: %s", resp.StatusCode, body)
+	}
+	var mapping models.MappingData
+	if err := json.Unmarshal(body, &mapping); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// retrieve searches every question through the batch endpoint with the
+// whole pool kept: no score or confidence cutoff and no paging.
+func retrieve(server string, siteCode string, questions []eval.LabeledQuestion, mode string, reranker string, pool int) ([]eval.QuestionCandidates, string, error) {
+	endpoint := fmt.Sprintf("%s/campaigns/%s/batch", strings.TrimRight(server, "/"), url.PathEscape(siteCode))
+	noCutoff := 0.0
+	results := make(map[string]dtos.SearchResultDto, len(questions))
+	resolvedMode := mode
+	for start := 0; start < len(questions); start += consts.MaxBatchQueries {
+		end := start + consts.MaxBatchQueries
+		if end > len(questions) {
+			end = len(questions)
+		}
+		request := dtos.BatchSearchRequestDto{
+			SearchParamsDto: dtos.SearchParamsDto{
+				Mode:          mode,
+				Reranker:      reranker,
+				TopK:          pool,
+				Limit:         pool,
+				MinScore:      &noCutoff,
+				MinConfidence: &noCutoff,
+			},
+		}
+		for _, question := range questions[start:end] {
+			request.Queries = append(request.Queries, question.Text)
+		}
+		body, err := json.Marshal(request)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := http.Post(endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return nil, "", err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode >= 400 {
+			return nil, "", fmt.Errorf("batch search failed (%d): %s", resp.StatusCode, respBody)
+		}
+		var batch dtos.BatchSearchResponseDto
+		if err := json.Unmarshal(respBody, &batch); err != nil {
+			return nil, "", err
+		}
+		resolvedMode = batch.Mode
+		for query, result := range batch.Results {
+			results[query] = result
+		}
+	}
+
+	candidates := make([]eval.QuestionCandidates, 0, len(questions))
+	for _, question := range questions {
+		result := results[question.Text]
+		candidates = append(candidates, eval.QuestionCandidates{
+			Question: question,
+			Results:  result.Results,
+			Error:    result.Error,
+		})
+	}
+	return candidates, resolvedMode, nil
+}
+
+func writeCSV(path string, reports []*eval.SweepReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"SiteCode", "TopK", "MinScore", "Intent", "Questions", "Returned", "Expected", "Hits", "Precision", "Recall", "F1"})
+	for _, report := range reports {
+		for _, point := range report.Points {
+			writer.Write(csvRow(report.SiteCode, point, eval.OverallIntent, point.Overall))
+			for _, intent := range report.Intents {
+				writer.Write(csvRow(report.SiteCode, point, intent, point.ByIntent[intent]))
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvRow(siteCode string, point eval.SweepPoint, intent string, m eval.CutoffMetrics) []string {
+	return []string{
+		siteCode,
+		strconv.Itoa(point.TopK),
+		strconv.FormatFloat(point.MinScore, 'f', -1, 64),
+		intent,
+		strconv.Itoa(m.Questions),
+		strconv.Itoa(m.Returned),
+		strconv.Itoa(m.Expected),
+		strconv.Itoa(m.Hits),
+		fmt.Sprintf("%.4f", m.Precision),
+		fmt.Sprintf("%.4f", m.Recall),
+		fmt.Sprintf("%.4f", m.F1),
+	}
+}
+
+func printRecommendations(reports []*eval.SweepReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SITE\tINTENT\tTOP_K\tMIN_SCORE\tP\tR\tF1\n")
+	siteDefaults := make(map[string]map[string]interface{}, len(reports))
+	for _, report := range reports {
+		for _, intent := range report.Intents {
+			printRecommendation(w, report.SiteCode, intent, report.RecommendedIntent[intent])
+		}
+		printRecommendation(w, report.SiteCode, eval.OverallIntent, report.Recommended)
+		siteDefaults[report.SiteCode] = map[string]interface{}{
+			"top_k":     report.Recommended.TopK,
+			"min_score": report.Recommended.MinScore,
+		}
+		if report.Failed > 0 {
+			fmt.Printf("%s: %d of %d questions failed and count as empty\n", report.SiteCode, report.Failed, report.Questions)
+		}
+	}
+	w.Flush()
+
+	// ready to paste into the service's search_site_defaults
+	if data, err := json.Marshal(siteDefaults); err == nil {
+		fmt.Printf("search_site_defaults=%s\n", data)
+	}
+}
+
+func printRecommendation(w io.Writer, siteCode string, intent string, r eval.Recommendation) {
+	fmt.Fprintf(w, "%s\t%s\t%d\t%g\t%.3f\t%.3f\t%.3f\n", siteCode, intent, r.TopK, r.MinScore, r.Precision, r.Recall, r.F1)
+}
+
+func parseInts(list string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if value <= 0 || value > consts.MaxTopK {
+			return nil, fmt.Errorf("top_k %d is not between 1 and %d", value, consts.MaxTopK)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func parseFloats(list string) ([]float64, error) {
+	var values []float64
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -44,6 +45,9 @@ type Configurations struct {
 	UserSvcBaseURL    string
 	PaymentSvcBaseURL string
 	EmbeddingModel    EmbeddingModelConfig
+	MappingStore      MappingStoreConfig
+	Search            SearchConfig
+	VectorStore       VectorStoreConfig
 }
 
 // GCP Credential
@@ -53,9 +57,118 @@ type PosthogConfig struct {
 	Endpoint      string
 }
 
+// EmbeddingModelConfig selects the embedding provider ("http" or "local"),
+// how the embedding API is called and sizes the embedding cache. ModelName
+// and Dimension seed the cache keys until the API reports its model;
+// Dimension also sizes the local provider's vectors.
 type EmbeddingModelConfig struct {
-	URL    string
-	APIKey string
+	Provider        string
+	URL             string
+	BatchURL        string
+	APIKey          string
+	TimeoutMs       int
+	MaxRetries      int
+	BackoffMs       int
+	ModelName       string
+	Dimension       int
+	CacheSize       int
+	CacheTTLSeconds int
+}
+
+// MappingStoreConfig selects where short code mappings are kept:
+// "mongo" (default), "redis" or "file".
+type MappingStoreConfig struct {
+	Backend         string
+	CacheTTLSeconds int
+}
+
+// SearchConfig tunes retrieval: the default mode ("vector", "lexical" or
+// "hybrid") and how the vector and lexical ranks are weighted when fused.
+type SearchConfig struct {
+	DefaultMode   string
+	VectorWeight  float64
+	LexicalWeight float64
+	RRFK          int
+	// VocabularyDir holds per site attribute vocabularies, <sitecode>.json.
+	VocabularyDir string
+	// Defaults is the result window of a search that does not set one;
+	// SiteDefaults overrides it per site code.
+	Defaults     SearchDefaults
+	SiteDefaults map[string]SearchDefaults
+	Rerank       RerankConfig
+	// SynonymCacheTTLSeconds is how long a site's synonym dictionary is
+	// served from memory before it is read again.
+	SynonymCacheTTLSeconds int
+	// CalibrationCacheTTLSeconds is how long a site's score calibrations
+	// are served from memory before they are read again.
+	CalibrationCacheTTLSeconds int
+	// CatalogueCacheTTLSeconds is how long a site's catalogue of products,
+	// prices and categories is served from memory before it is read again,
+	// so catalogue edits that keep the mapping version show up.
+	CatalogueCacheTTLSeconds int
+}
+
+// RerankConfig selects the reranker applied after retrieval ("none",
+// "linear" or "cross-encoder") and how many candidates it sees. The
+// cross-encoder is called at URL, or stubbed locally when URL is empty.
+type RerankConfig struct {
+	Default    string
+	Candidates int
+	URL        string
+	APIKey     string
+	TimeoutMs  int
+	Weights    RerankWeights
+}
+
+// RerankWeights weigh the features of the linear reranker.
+type RerankWeights struct {
+	Retrieval     float64 `json:"retrieval"`
+	NameOverlap   float64 `json:"name_overlap"`
+	CategoryMatch float64 `json:"category_match"`
+	PriceFit      float64 `json:"price_fit"`
+}
+
+// SearchDefaults bounds the results of a search: at most TopK results,
+// vector matches below MinScore dropped, Limit of them per page (0 keeps
+// the whole window). MinScore is a cutoff on the vector search's cosine
+// similarity only: it applies before fusion and reranking, never to their
+// scores, and lexical or browse candidates are not cut by it. Once a
+// site's scores are calibrated, results below MinConfidence are dropped as
+// well. Zero values in a site override keep the global value.
+type SearchDefaults struct {
+	TopK          int      `json:"top_k"`
+	MinScore      *float64 `json:"min_score"`
+	Limit         int      `json:"limit"`
+	MinConfidence *float64 `json:"min_confidence"`
+}
+
+// DefaultsFor returns the search defaults of a site code.
+func (c SearchConfig) DefaultsFor(siteCode string) SearchDefaults {
+	defaults := c.Defaults
+	site, ok := c.SiteDefaults[siteCode]
+	if !ok {
+		return defaults
+	}
+	if site.TopK > 0 {
+		defaults.TopK = site.TopK
+	}
+	if site.MinScore != nil {
+		defaults.MinScore = site.MinScore
+	}
+	if site.Limit > 0 {
+		defaults.Limit = site.Limit
+	}
+	if site.MinConfidence != nil {
+		defaults.MinConfidence = site.MinConfidence
+	}
+	return defaults
+}
+
+// VectorStoreConfig selects where product vectors are searched: "milvus"
+// (default) or "memory", seeded from FixturePath (JSON or JSON lines).
+type VectorStoreConfig struct {
+	Backend     string
+	FixturePath string
 }
 
 type GCP struct {
@@ -88,7 +201,35 @@ type MilvusConfig struct {
 	Host           string
 	Key            string
 	CollectionName string
+	// SearchCollectionName, when set, is searched instead of the production
+	// vectors, e.g. to search the collection a qa deployment writes to
+	SearchCollectionName string
+}
+
+// Collection is the collection vectors are written to and deleted from:
+// CollectionName, or product_vectors_<env> for the app environment.
+func (c MilvusConfig) Collection(appEnv string) string {
+	if c.CollectionName != "" {
+		return c.CollectionName
+	}
+	switch appEnv {
+	case "non-prod":
+		appEnv = "qa"
+	case "":
+		appEnv = "prod"
+	}
+	return fmt.Sprintf("product_vectors_%s", appEnv)
+}
+
+// SearchCollection is the collection searched. Every environment searches
+// the production vectors unless SearchCollectionName overrides it.
+func (c MilvusConfig) SearchCollection() string {
+	if c.SearchCollectionName != "" {
+		return c.SearchCollectionName
+	}
+	return "product_vectors_prod"
 }
+
 type MilvusClient struct {
 	Client client.Client
 }
@@ -190,14 +331,161 @@ func LoadConfig() Configurations {
 		Host:           env["milvus_host"],
 		Key:            env["milvus_api_key"],
 		CollectionName: env["milvus_collection"],
+
+		SearchCollectionName: env["milvus_search_collection"],
 	}
 	conf.EmbeddingModel = EmbeddingModelConfig{
-		URL:    env["embedding_api_url"],
-		APIKey: env["embedding_api_key"],
+		Provider:        env["embedding_provider"],
+		URL:             env["embedding_api_url"],
+		BatchURL:        env["embedding_batch_url"],
+		APIKey:          env["embedding_api_key"],
+		TimeoutMs:       5000,
+		MaxRetries:      2,
+		BackoffMs:       200,
+		ModelName:       env["embedding_model_name"],
+		CacheSize:       10000,
+		CacheTTLSeconds: 7 * 24 * 60 * 60,
+	}
+	if timeout, err := strconv.Atoi(env["embedding_timeout_ms"]); err == nil && timeout > 0 {
+		conf.EmbeddingModel.TimeoutMs = timeout
+	}
+	if retries, err := strconv.Atoi(env["embedding_max_retries"]); err == nil && retries >= 0 {
+		conf.EmbeddingModel.MaxRetries = retries
+	}
+	if backoff, err := strconv.Atoi(env["embedding_backoff_ms"]); err == nil && backoff >= 0 {
+		conf.EmbeddingModel.BackoffMs = backoff
+	}
+	if dimension, err := strconv.Atoi(env["embedding_dimension"]); err == nil && dimension > 0 {
+		conf.EmbeddingModel.Dimension = dimension
+	}
+	if size, err := strconv.Atoi(env["embedding_cache_size"]); err == nil && size >= 0 {
+		conf.EmbeddingModel.CacheSize = size
+	}
+	if ttl, err := strconv.Atoi(env["embedding_cache_ttl_seconds"]); err == nil && ttl >= 0 {
+		conf.EmbeddingModel.CacheTTLSeconds = ttl
+	}
+	mappingCacheTTL, err := strconv.Atoi(env["mapping_cache_ttl_seconds"])
+	if err != nil || mappingCacheTTL <= 0 {
+		mappingCacheTTL = 60
+	}
+	mappingStoreBackend := env["mapping_store"]
+	if mappingStoreBackend == "" {
+		mappingStoreBackend = "mongo"
+	}
+	conf.MappingStore = MappingStoreConfig{
+		Backend:         mappingStoreBackend,
+		CacheTTLSeconds: mappingCacheTTL,
+	}
+	conf.Search = loadSearchConfig(env)
+	conf.VectorStore = VectorStoreConfig{
+		Backend:     env["vector_store"],
+		FixturePath: env["vector_fixture"],
 	}
 	return conf
 }
 
+func loadSearchConfig(env map[string]string) SearchConfig {
+	searchConf := SearchConfig{
+		DefaultMode:   env["search_mode"],
+		VectorWeight:  1,
+		LexicalWeight: 1,
+		RRFK:          60,
+		VocabularyDir: env["attribute_vocabulary_dir"],
+
+		SynonymCacheTTLSeconds:     60,
+		CalibrationCacheTTLSeconds: 60,
+		CatalogueCacheTTLSeconds:   300,
+	}
+	if searchConf.DefaultMode == "" {
+		searchConf.DefaultMode = "vector"
+	}
+	if searchConf.VocabularyDir == "" {
+		searchConf.VocabularyDir = "attribute_vocabularies"
+	}
+	if weight, err := strconv.ParseFloat(env["search_vector_weight"], 64); err == nil && weight >= 0 {
+		searchConf.VectorWeight = weight
+	}
+	if weight, err := strconv.ParseFloat(env["search_lexical_weight"], 64); err == nil && weight >= 0 {
+		searchConf.LexicalWeight = weight
+	}
+	if rrfK, err := strconv.Atoi(env["search_rrf_k"]); err == nil && rrfK > 0 {
+		searchConf.RRFK = rrfK
+	}
+	if ttl, err := strconv.Atoi(env["synonym_cache_ttl_seconds"]); err == nil && ttl >= 0 {
+		searchConf.SynonymCacheTTLSeconds = ttl
+	}
+	if ttl, err := strconv.Atoi(env["calibration_cache_ttl_seconds"]); err == nil && ttl >= 0 {
+		searchConf.CalibrationCacheTTLSeconds = ttl
+	}
+	if ttl, err := strconv.Atoi(env["catalogue_cache_ttl_seconds"]); err == nil && ttl >= 0 {
+		searchConf.CatalogueCacheTTLSeconds = ttl
+	}
+
+	minScore := float64(consts.SimilarityThreshold)
+	if score, err := strconv.ParseFloat(env["search_min_score"], 64); err == nil {
+		minScore = score
+	}
+	minConfidence := consts.DefaultMinConfidence
+	if confidence, err := strconv.ParseFloat(env["search_min_confidence"], 64); err == nil && confidence >= 0 && confidence <= 1 {
+		minConfidence = confidence
+	}
+	searchConf.Defaults = SearchDefaults{TopK: consts.SearchTopK, MinScore: &minScore, MinConfidence: &minConfidence}
+	if topK, err := strconv.Atoi(env["search_top_k"]); err == nil && topK > 0 && topK <= consts.MaxTopK {
+		searchConf.Defaults.TopK = topK
+	}
+	if limit, err := strconv.Atoi(env["search_limit"]); err == nil && limit > 0 {
+		searchConf.Defaults.Limit = limit
+	}
+	searchConf.Rerank = loadRerankConfig(env)
+	// search_site_defaults is a JSON object keyed by site code, e.g.
+	// {"abc123": {"top_k": 10, "min_score": 0.2}}
+	if raw := env["search_site_defaults"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &searchConf.SiteDefaults); err != nil {
+			log.Printf("Ignoring invalid search_site_defaults: %v", err)
+			searchConf.SiteDefaults = nil
+		}
+		for siteCode, site := range searchConf.SiteDefaults {
+			if site.TopK > consts.MaxTopK {
+				site.TopK = consts.MaxTopK
+				searchConf.SiteDefaults[siteCode] = site
+			}
+		}
+	}
+	return searchConf
+}
+
+func loadRerankConfig(env map[string]string) RerankConfig {
+	rerankConf := RerankConfig{
+		Default:    env["reranker"],
+		Candidates: 50,
+		URL:        env["rerank_url"],
+		APIKey:     env["rerank_api_key"],
+		TimeoutMs:  2000,
+		Weights: RerankWeights{
+			Retrieval:     1,
+			NameOverlap:   0.5,
+			CategoryMatch: 0.25,
+			PriceFit:      0.25,
+		},
+	}
+	if rerankConf.Default == "" {
+		rerankConf.Default = "none"
+	}
+	if candidates, err := strconv.Atoi(env["rerank_candidates"]); err == nil && candidates > 0 {
+		rerankConf.Candidates = candidates
+	}
+	if timeout, err := strconv.Atoi(env["rerank_timeout_ms"]); err == nil && timeout > 0 {
+		rerankConf.TimeoutMs = timeout
+	}
+	// rerank_weights overrides any of the linear weights, e.g. {"price_fit": 1}
+	if raw := env["rerank_weights"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &rerankConf.Weights); err != nil {
+			log.Printf("Ignoring invalid rerank_weights: %v", err)
+		}
+	}
+	return rerankConf
+}
+
 func configureDatabase(ctx context.Context, lgr *zap.SugaredLogger, conf DBConfig) *mongo.Database {
 	if conf.URI == "" {
 		lgr.Fatal("Set MongoDB URI in your config.yaml file")
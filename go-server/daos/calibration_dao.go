@@ -0,0 +1,18 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	"github.com/homingos/campaign-svc/models"
+)
+
+var ErrCalibrationNotFound = errors.New("calibration not found")
+
+// CalibrationDao stores score calibrations, one per site code and score
+// model; fitting a model again replaces its calibration.
+type CalibrationDao interface {
+	SaveCalibrationDao(ctx context.Context, calibration *models.ScoreCalibration) error
+	GetCalibrationDao(ctx context.Context, siteCode string, model string) (*models.ScoreCalibration, error)
+	GetCalibrationsBySiteCodeDao(ctx context.Context, siteCode string) ([]models.ScoreCalibration, error)
+}
@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type CalibrationDaoImpl struct {
+	lgr *zap.SugaredLogger
+	db  *mongo.Database
+}
+
+func createCalibrationIndexes(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	coll := db.Collection(consts.ScoreCalibrationCollection)
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "site_code", Value: 1}, {Key: "model", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	_, err := coll.Indexes().CreateMany(ctx, indexes, opts)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func NewCalibrationDao(lgr *zap.SugaredLogger, db *mongo.Database) *CalibrationDaoImpl {
+	createCalibrationIndexes(db)
+	return &CalibrationDaoImpl{lgr: lgr, db: db}
+}
+
+func (impl *CalibrationDaoImpl) SaveCalibrationDao(ctx context.Context, calibration *models.ScoreCalibration) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll := impl.db.Collection(consts.ScoreCalibrationCollection)
+	filter := bson.M{"site_code": calibration.SiteCode, "model": calibration.Model}
+	_, err := coll.ReplaceOne(ctx, filter, calibration, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (impl *CalibrationDaoImpl) GetCalibrationDao(ctx context.Context, siteCode string, model string) (*models.ScoreCalibration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll := impl.db.Collection(consts.ScoreCalibrationCollection)
+	var calibration models.ScoreCalibration
+	err := coll.FindOne(ctx, bson.M{"site_code": siteCode, "model": model}).Decode(&calibration)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCalibrationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &calibration, nil
+}
+
+func (impl *CalibrationDaoImpl) GetCalibrationsBySiteCodeDao(ctx context.Context, siteCode string) ([]models.ScoreCalibration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll := impl.db.Collection(consts.ScoreCalibrationCollection)
+	cursor, err := coll.Find(ctx, bson.M{"site_code": siteCode}, options.Find().SetSort(bson.M{"model": 1}))
+	if err != nil {
+		return nil, err
+	}
+	calibrations := []models.ScoreCalibration{}
+	if err := cursor.All(ctx, &calibrations); err != nil {
+		return nil, err
+	}
+	return calibrations, nil
+}
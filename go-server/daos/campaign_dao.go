@@ -37,5 +37,6 @@ type CampaignDao interface {
 	GetCampaignWithExperienceBySourceCodeDao(shortcode string, pending bool) (*dtos.CampaignsExperiences, error)
 	GetClientCampaignsDAO(clientID primitive.ObjectID) ([]dtos.ClientCampaignsInfo, error)
 	GetCampaignByShortCodesDao(shortCodes []string, clientId string) ([]models.Campaign, error)
-	GetShortcodesByMilvusRefID(IDs []string) ([]string, error)
+	GetCampaignsByMilvusRefIDsDao(ctx context.Context, IDs []string) (map[string]dtos.MilvusCampaignRefDto, error)
+	GetCampaignStatesByShortCodesDao(ctx context.Context, shortCodes []string) (map[string]dtos.CampaignStateDto, error)
 }
@@ -72,6 +72,13 @@ func createCampaignIndexes(db *mongo.Database) {
 			},
 			Options: options.Index(),
 		},
+		{
+			Keys: bson.D{
+				{Key: "milvus_ref_id", Value: 1},
+				{Key: "is_active", Value: 1},
+			},
+			Options: options.Index(),
+		},
 		{
 			Keys: bson.D{
 				{Key: "is_active", Value: 1},
@@ -1833,49 +1840,83 @@ func (impl *CampaignDaoImpl) GetCampaignByShortCodesDao(shortCodes []string, cli
 	}
 	return campaigns, nil
 }
-func (impl *CampaignDaoImpl) GetShortcodesByMilvusRefID(IDs []string) ([]string, error) {
+
+// GetCampaignsByMilvusRefIDsDao returns the active campaign of every vector
+// ID that has one, keyed by milvus_ref_id, in a single query.
+func (impl *CampaignDaoImpl) GetCampaignsByMilvusRefIDsDao(ctx context.Context, IDs []string) (map[string]dtos.MilvusCampaignRefDto, error) {
+	refs := make(map[string]dtos.MilvusCampaignRefDto, len(IDs))
+	if len(IDs) == 0 {
+		return refs, nil
+	}
 	coll := impl.db.Collection(consts.CampaignCollection)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	// clientObjId, err := primitive.ObjectIDFromHex(clientId)
-	// if err != nil {
-	// 	return nil, err
-	// }
 
-	fmt.Println("IDS in repo: ", IDs)
 	filter := bson.M{"milvus_ref_id": bson.M{"$in": IDs}, "is_active": true}
-	pipelines := []bson.M{
-		{
-			"$match": filter,
-		},
-		{
-			"$group": bson.M{
-				"_id": nil,
-				"short_codes": bson.M{
-					"$push": "$short_code",
-				},
-			},
-		},
-		{
-			"$project": bson.M{
-				"_id": 0,
-			},
-		},
+	projection := bson.M{"_id": 1, "milvus_ref_id": 1, "short_code": 1, "name": 1}
+	cursor, err := coll.Find(ctx, filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
 	}
-	var data []map[string][]string
+	defer cursor.Close(ctx)
 
-	cursor, err := coll.Aggregate(ctx, pipelines)
+	var campaigns []dtos.MilvusCampaignRefDto
+	if err := cursor.All(ctx, &campaigns); err != nil {
+		return nil, err
+	}
+	for _, campaign := range campaigns {
+		refs[campaign.MilvusRefID] = campaign
+	}
+	return refs, nil
+}
+
+// GetCampaignStatesByShortCodesDao returns, keyed by short code, whether
+// each campaign is active and the statuses of its active experiences,
+// whatever the campaign's own state. When a short code has several
+// campaigns the active one wins.
+func (impl *CampaignDaoImpl) GetCampaignStatesByShortCodesDao(ctx context.Context, shortCodes []string) (map[string]dtos.CampaignStateDto, error) {
+	states := make(map[string]dtos.CampaignStateDto, len(shortCodes))
+	if len(shortCodes) == 0 {
+		return states, nil
+	}
+	coll := impl.db.Collection(consts.CampaignCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"short_code": bson.M{"$in": shortCodes}}},
+		{"$lookup": bson.M{
+			"from":         "experiences",
+			"localField":   "_id",
+			"foreignField": "campaign_id",
+			"as":           "experiences",
+			"pipeline": []bson.M{
+				{"$match": bson.M{"is_active": true}},
+				{"$project": bson.M{"status": 1}},
+			},
+		}},
+		{"$project": bson.M{
+			"_id":                 0,
+			"short_code":          1,
+			"is_active":           1,
+			"experience_statuses": "$experiences.status",
+		}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	if err = cursor.All(ctx, &data); err != nil {
+	var campaigns []dtos.CampaignStateDto
+	if err := cursor.All(ctx, &campaigns); err != nil {
 		return nil, err
 	}
-	if len(data) > 0 {
-		return data[0]["short_codes"], nil
+	for _, campaign := range campaigns {
+		if current, ok := states[campaign.ShortCode]; ok && current.IsActive {
+			continue
+		}
+		states[campaign.ShortCode] = campaign
 	}
-
-	return nil, nil
+	return states, nil
 }
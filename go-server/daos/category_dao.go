@@ -17,4 +17,5 @@ type CategoryDao interface {
 	ClientCategoriesDao(ID string) ([]models.Category, error)
 	GetCategoryByNameDao(name string, ClientID string) (*models.Category, error)
 	GetCategoryByID(ctx context.Context, clientObjID, categoryObjID primitive.ObjectID) (map[string]any, error)
+	GetCatalogueProductsBySiteCodeDao(ctx context.Context, siteCode string) ([]dtos.CatalogueProductDto, error)
 }
@@ -718,3 +718,65 @@ func (impl *CategoryDaoImpl) GetCategoryByID(ctx context.Context, clientObjID, c
 
 	return categories[0], nil
 }
+
+// GetCatalogueProductsBySiteCodeDao lists every active campaign of a site
+// code with its category and the catalogue details of its first active
+// experience, whatever the experience status.
+func (impl *CategoryDaoImpl) GetCatalogueProductsBySiteCodeDao(ctx context.Context, siteCode string) ([]dtos.CatalogueProductDto, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	pipeline := []bson.M{
+		{"$match": bson.M{"site_code": siteCode, "is_active": true}},
+		{"$unwind": "$categories"},
+		{"$unwind": "$categories.campaigns"},
+		{"$lookup": bson.M{
+			"from":         consts.CampaignCollection,
+			"localField":   "categories.campaigns",
+			"foreignField": "short_code",
+			"as":           "campaign_doc",
+			"pipeline": []bson.M{
+				{"$match": bson.M{"is_active": true}},
+				{"$project": bson.M{"_id": 1, "name": 1, "short_code": 1}},
+			},
+		}},
+		{"$unwind": "$campaign_doc"},
+		{"$lookup": bson.M{
+			"from":         "experiences",
+			"localField":   "campaign_doc._id",
+			"foreignField": "campaign_id",
+			"as":           "experience",
+			"pipeline": []bson.M{
+				{"$match": bson.M{"is_active": true}},
+				{"$limit": 1},
+				{"$project": bson.M{"status": 1, "catalogue_details": 1}},
+			},
+		}},
+		{"$unwind": bson.M{"path": "$experience", "preserveNullAndEmptyArrays": true}},
+		{"$project": bson.M{
+			"_id":               0,
+			"short_code":        "$campaign_doc.short_code",
+			"campaign_id":       bson.M{"$toString": "$campaign_doc._id"},
+			"campaign_name":     "$campaign_doc.name",
+			"category":          "$categories.name",
+			"experience_status": "$experience.status",
+			"name":              "$experience.catalogue_details.name",
+			"description":       "$experience.catalogue_details.description",
+			"price":             "$experience.catalogue_details.price",
+			"currency":          "$experience.catalogue_details.currency",
+			"attributes":        "$experience.catalogue_details.attributes",
+		}},
+	}
+
+	coll := impl.db.Collection(consts.CategoryCollection)
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []dtos.CatalogueProductDto{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
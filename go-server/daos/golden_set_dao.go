@@ -0,0 +1,30 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+)
+
+// LatestGoldenSetVersion asks a GoldenSetDao for the newest golden set.
+const LatestGoldenSetVersion = 0
+
+var (
+	ErrGoldenSetNotFound = errors.New("golden set not found")
+	// ErrGoldenSetConflict is returned when the version being saved was
+	// written by someone else first
+	ErrGoldenSetConflict = errors.New("golden set version already exists")
+)
+
+// GoldenSetDao stores the versions of each site code's golden set, one
+// document per version.
+type GoldenSetDao interface {
+	// CreateGoldenSetDao inserts a set under its Version, failing with
+	// ErrGoldenSetConflict when that version exists.
+	CreateGoldenSetDao(ctx context.Context, set *models.GoldenSet) error
+	// GetGoldenSetDao returns a version, or the latest for LatestGoldenSetVersion.
+	GetGoldenSetDao(ctx context.Context, siteCode string, version int) (*models.GoldenSet, error)
+	GetGoldenSetVersionsDao(ctx context.Context, siteCode string) ([]dtos.GoldenSetVersionDto, error)
+}
@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type GoldenSetDaoImpl struct {
+	lgr *zap.SugaredLogger
+	db  *mongo.Database
+}
+
+func createGoldenSetIndexes(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	coll := db.Collection(consts.GoldenSetCollection)
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "site_code", Value: 1}, {Key: "version", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	_, err := coll.Indexes().CreateMany(ctx, indexes, opts)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func NewGoldenSetDao(lgr *zap.SugaredLogger, db *mongo.Database) *GoldenSetDaoImpl {
+	createGoldenSetIndexes(db)
+	return &GoldenSetDaoImpl{lgr: lgr, db: db}
+}
+
+func (impl *GoldenSetDaoImpl) CreateGoldenSetDao(ctx context.Context, set *models.GoldenSet) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// the unique (site_code, version) index turns a concurrent edit of the
+	// same version into a conflict instead of a lost update
+	_, err := impl.db.Collection(consts.GoldenSetCollection).InsertOne(ctx, set)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrGoldenSetConflict
+	}
+	return err
+}
+
+func (impl *GoldenSetDaoImpl) GetGoldenSetDao(ctx context.Context, siteCode string, version int) (*models.GoldenSet, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"site_code": siteCode}
+	if version != LatestGoldenSetVersion {
+		filter["version"] = version
+	}
+	opts := options.FindOne().SetSort(bson.M{"version": -1})
+
+	var set models.GoldenSet
+	err := impl.db.Collection(consts.GoldenSetCollection).FindOne(ctx, filter, opts).Decode(&set)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrGoldenSetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (impl *GoldenSetDaoImpl) GetGoldenSetVersionsDao(ctx context.Context, siteCode string) ([]dtos.GoldenSetVersionDto, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.M{"version": -1}).
+		SetProjection(bson.M{"questions": 0})
+	cursor, err := impl.db.Collection(consts.GoldenSetCollection).Find(ctx, bson.M{"site_code": siteCode}, opts)
+	if err != nil {
+		return nil, err
+	}
+	versions := []dtos.GoldenSetVersionDto{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
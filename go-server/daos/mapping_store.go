@@ -0,0 +1,246 @@
+package dao
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	redisStorage "github.com/homingos/campaign-svc/lib/redis"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// LatestMappingVersion asks a MappingStore for the newest mapping.
+const LatestMappingVersion = 0
+
+// Mapping store backends selectable through config.
+const (
+	MappingStoreFile  = "file"
+	MappingStoreRedis = "redis"
+	MappingStoreMongo = "mongo"
+)
+
+var (
+	ErrMappingNotFound = errors.New("mapping not found")
+	// ErrMappingConflict is returned by Create when the version being
+	// created was already taken by another writer.
+	ErrMappingConflict = errors.New("mapping version already exists")
+)
+
+// mappingPatchAttempts bounds how often PatchMapping replays a patch, and
+// the Redis store retries a save, on a version taken meanwhile by another
+// writer.
+const mappingPatchAttempts = 3
+
+// MappingStore persists versioned short code to name mappings per site code.
+type MappingStore interface {
+	// Save stores the mapping as the next version for its site code and
+	// returns it with Version set.
+	Save(ctx context.Context, mapping *models.MappingData) (*models.MappingData, error)
+	// Create stores the mapping as exactly its Version, returning
+	// ErrMappingConflict when that version was already taken.
+	Create(ctx context.Context, mapping *models.MappingData) error
+	// Get returns a specific version, or the latest for LatestMappingVersion.
+	Get(ctx context.Context, siteCode string, version int) (*models.MappingData, error)
+}
+
+// NewMappingStore builds the configured backend wrapped in an in-process
+// cache. Unknown backends fall back to Mongo.
+func NewMappingStore(lgr *zap.SugaredLogger, backend string, cacheTTL time.Duration, db *mongo.Database, redisClient *redisStorage.RedisClient) *CachedMappingStore {
+	var store MappingStore
+	switch backend {
+	case MappingStoreFile:
+		store = NewFileMappingStore(".")
+	case MappingStoreRedis:
+		store = NewRedisMappingStore(redisClient)
+	case MappingStoreMongo, "":
+		store = NewMongoMappingStore(lgr, db)
+	default:
+		lgr.Warnf("Unknown mapping store %q, using %s", backend, MappingStoreMongo)
+		store = NewMongoMappingStore(lgr, db)
+	}
+	return NewCachedMappingStore(store, cacheTTL)
+}
+
+type cachedMapping struct {
+	mapping  *models.MappingData
+	cachedAt time.Time
+}
+
+type pinnedMapping struct {
+	key     string
+	mapping *models.MappingData
+}
+
+// CachedMappingStore keeps mappings in process memory in front of another
+// store. Pinned versions never change and the most recently used of them
+// are cached; the latest version is re-read once it is older than the TTL.
+type CachedMappingStore struct {
+	store       MappingStore
+	ttl         time.Duration
+	mu          sync.Mutex
+	latest      map[string]cachedMapping
+	pinned      map[string]*list.Element
+	pinnedOrder *list.List
+}
+
+func NewCachedMappingStore(store MappingStore, ttl time.Duration) *CachedMappingStore {
+	return &CachedMappingStore{
+		store:       store,
+		ttl:         ttl,
+		latest:      make(map[string]cachedMapping),
+		pinned:      make(map[string]*list.Element),
+		pinnedOrder: list.New(),
+	}
+}
+
+func pinnedKey(siteCode string, version int) string {
+	return fmt.Sprintf("%s:%d", siteCode, version)
+}
+
+func (impl *CachedMappingStore) Save(ctx context.Context, mapping *models.MappingData) (*models.MappingData, error) {
+	saved, err := impl.store.Save(ctx, mapping)
+	if err != nil {
+		return nil, err
+	}
+	impl.mu.Lock()
+	impl.latest[saved.SiteCode] = cachedMapping{mapping: saved, cachedAt: time.Now()}
+	impl.pin(saved)
+	impl.mu.Unlock()
+	return saved, nil
+}
+
+func (impl *CachedMappingStore) Create(ctx context.Context, mapping *models.MappingData) error {
+	if err := impl.store.Create(ctx, mapping); err != nil {
+		return err
+	}
+	impl.mu.Lock()
+	impl.latest[mapping.SiteCode] = cachedMapping{mapping: mapping, cachedAt: time.Now()}
+	impl.pin(mapping)
+	impl.mu.Unlock()
+	return nil
+}
+
+func (impl *CachedMappingStore) Get(ctx context.Context, siteCode string, version int) (*models.MappingData, error) {
+	impl.mu.Lock()
+	if version == LatestMappingVersion {
+		if cached, ok := impl.latest[siteCode]; ok && time.Since(cached.cachedAt) < impl.ttl {
+			impl.mu.Unlock()
+			return cached.mapping, nil
+		}
+	} else if element, ok := impl.pinned[pinnedKey(siteCode, version)]; ok {
+		impl.pinnedOrder.MoveToFront(element)
+		impl.mu.Unlock()
+		return element.Value.(*pinnedMapping).mapping, nil
+	}
+	impl.mu.Unlock()
+
+	mapping, err := impl.store.Get(ctx, siteCode, version)
+	if err != nil {
+		return nil, err
+	}
+	impl.mu.Lock()
+	if version == LatestMappingVersion {
+		impl.latest[siteCode] = cachedMapping{mapping: mapping, cachedAt: time.Now()}
+	}
+	impl.pin(mapping)
+	impl.mu.Unlock()
+	return mapping, nil
+}
+
+// pin caches a mapping version and evicts the least recently used versions
+// past MaxCachedMappingVersions. The caller holds impl.mu.
+func (impl *CachedMappingStore) pin(mapping *models.MappingData) {
+	key := pinnedKey(mapping.SiteCode, mapping.Version)
+	if element, ok := impl.pinned[key]; ok {
+		element.Value.(*pinnedMapping).mapping = mapping
+		impl.pinnedOrder.MoveToFront(element)
+		return
+	}
+	impl.pinned[key] = impl.pinnedOrder.PushFront(&pinnedMapping{key: key, mapping: mapping})
+	for impl.pinnedOrder.Len() > consts.MaxCachedMappingVersions {
+		oldest := impl.pinnedOrder.Back()
+		impl.pinnedOrder.Remove(oldest)
+		delete(impl.pinned, oldest.Value.(*pinnedMapping).key)
+	}
+}
+
+// Invalidate drops the cached latest mapping of a site code so the next
+// read goes to the backing store.
+func (impl *CachedMappingStore) Invalidate(siteCode string) {
+	impl.mu.Lock()
+	delete(impl.latest, siteCode)
+	impl.mu.Unlock()
+}
+
+// MappingCache is implemented by stores that keep mappings in memory.
+type MappingCache interface {
+	Invalidate(siteCode string)
+}
+
+// PatchMapping adds the short codes missing from the latest mapping of a
+// site code and saves the result as the next version. Existing entries are
+// left untouched, and a version saved meanwhile by another writer replays
+// the patch on it so neither writer's short codes are lost. It returns the
+// previous version (0 when the site had no mapping yet), the new mapping
+// and the short codes that were added; when nothing was missing no version
+// is written and added is empty.
+func PatchMapping(ctx context.Context, store MappingStore, siteCode string, entries []models.ShortCodeMapping) (int, *models.MappingData, []string, error) {
+	for attempt := 0; attempt < mappingPatchAttempts; attempt++ {
+		// a read-modify-write must start from the stored latest, not a
+		// cached copy
+		if cache, ok := store.(MappingCache); ok {
+			cache.Invalidate(siteCode)
+		}
+
+		previousVersion := 0
+		current := &models.MappingData{SiteCode: siteCode}
+		latest, err := store.Get(ctx, siteCode, LatestMappingVersion)
+		if err == nil {
+			current = latest
+			previousVersion = latest.Version
+		} else if err != ErrMappingNotFound {
+			return 0, nil, nil, err
+		}
+
+		known := make(map[string]bool, len(current.Mappings))
+		for _, mapping := range current.Mappings {
+			known[mapping.ShortCode] = true
+		}
+		mappings := append([]models.ShortCodeMapping{}, current.Mappings...)
+		var added []string
+		for _, entry := range entries {
+			if entry.ShortCode == "" || known[entry.ShortCode] {
+				continue
+			}
+			known[entry.ShortCode] = true
+			mappings = append(mappings, entry)
+			added = append(added, entry.ShortCode)
+		}
+		if len(added) == 0 {
+			return previousVersion, current, nil, nil
+		}
+
+		patched := &models.MappingData{
+			SiteCode:    siteCode,
+			Version:     previousVersion + 1,
+			Mappings:    mappings,
+			LastUpdated: time.Now().Format(time.RFC3339),
+			Count:       len(mappings),
+		}
+		err = store.Create(ctx, patched)
+		if err == ErrMappingConflict {
+			continue
+		}
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		return previousVersion, patched, added, nil
+	}
+	return 0, nil, nil, fmt.Errorf("mapping of %s is being changed concurrently", siteCode)
+}
@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/homingos/campaign-svc/models"
+)
+
+// FileMappingStore keeps mappings as JSON files in a directory. The latest
+// version is always mirrored to mapping_<sitecode>.json, which is also what
+// earlier releases wrote, so existing files keep working. It is meant for
+// local runs with a single process.
+type FileMappingStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileMappingStore(dir string) *FileMappingStore {
+	return &FileMappingStore{dir: dir}
+}
+
+func (impl *FileMappingStore) latestPath(siteCode string) string {
+	return filepath.Join(impl.dir, "mapping_"+siteCode+".json")
+}
+
+func (impl *FileMappingStore) versionPath(siteCode string, version int) string {
+	return filepath.Join(impl.dir, fmt.Sprintf("mapping_%s.v%d.json", siteCode, version))
+}
+
+// validSiteCode keeps site codes from the URL from escaping the directory.
+func validSiteCode(siteCode string) bool {
+	return siteCode != "" && filepath.Base(siteCode) == siteCode
+}
+
+func (impl *FileMappingStore) Save(ctx context.Context, mapping *models.MappingData) (*models.MappingData, error) {
+	if !validSiteCode(mapping.SiteCode) {
+		return nil, fmt.Errorf("invalid site code %q", mapping.SiteCode)
+	}
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	saved := *mapping
+	saved.Version = 1
+	if latest, err := impl.read(impl.latestPath(mapping.SiteCode)); err == nil {
+		saved.Version = latest.Version + 1
+	} else if err != ErrMappingNotFound {
+		return nil, err
+	}
+	if err := impl.write(&saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (impl *FileMappingStore) Create(ctx context.Context, mapping *models.MappingData) error {
+	if !validSiteCode(mapping.SiteCode) {
+		return fmt.Errorf("invalid site code %q", mapping.SiteCode)
+	}
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	next := 1
+	if latest, err := impl.read(impl.latestPath(mapping.SiteCode)); err == nil {
+		next = latest.Version + 1
+	} else if err != ErrMappingNotFound {
+		return err
+	}
+	if mapping.Version != next {
+		return ErrMappingConflict
+	}
+	return impl.write(mapping)
+}
+
+// write stores a version and mirrors it as the latest.
+func (impl *FileMappingStore) write(mapping *models.MappingData) error {
+	jsonData, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(impl.versionPath(mapping.SiteCode, mapping.Version), jsonData, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(impl.latestPath(mapping.SiteCode), jsonData, 0644)
+}
+
+func (impl *FileMappingStore) Get(ctx context.Context, siteCode string, version int) (*models.MappingData, error) {
+	if !validSiteCode(siteCode) {
+		return nil, ErrMappingNotFound
+	}
+	if version == LatestMappingVersion {
+		return impl.read(impl.latestPath(siteCode))
+	}
+	return impl.read(impl.versionPath(siteCode, version))
+}
+
+func (impl *FileMappingStore) read(path string) (*models.MappingData, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrMappingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mapping models.MappingData
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file %s: %w", path, err)
+	}
+	return &mapping, nil
+}
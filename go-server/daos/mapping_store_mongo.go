@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// MongoMappingStore keeps one document per mapping version.
+type MongoMappingStore struct {
+	lgr *zap.SugaredLogger
+	db  *mongo.Database
+}
+
+func createMappingIndexes(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	coll := db.Collection(consts.MappingCollection)
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "site_code", Value: 1}, {Key: "version", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	_, err := coll.Indexes().CreateMany(ctx, indexes, opts)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func NewMongoMappingStore(lgr *zap.SugaredLogger, db *mongo.Database) *MongoMappingStore {
+	createMappingIndexes(db)
+	return &MongoMappingStore{lgr: lgr, db: db}
+}
+
+func (impl *MongoMappingStore) Save(ctx context.Context, mapping *models.MappingData) (*models.MappingData, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	coll := impl.db.Collection(consts.MappingCollection)
+
+	// the unique (site_code, version) index settles concurrent writers;
+	// the loser re-reads the latest version and tries again
+	for attempt := 0; attempt < 3; attempt++ {
+		saved := *mapping
+		saved.Version = 1
+		latest, err := impl.Get(ctx, mapping.SiteCode, LatestMappingVersion)
+		if err == nil {
+			saved.Version = latest.Version + 1
+		} else if err != ErrMappingNotFound {
+			return nil, err
+		}
+
+		_, err = coll.InsertOne(ctx, saved)
+		if err == nil {
+			return &saved, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to allocate mapping version for %s", mapping.SiteCode)
+}
+
+func (impl *MongoMappingStore) Create(ctx context.Context, mapping *models.MappingData) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err := impl.db.Collection(consts.MappingCollection).InsertOne(ctx, mapping)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrMappingConflict
+	}
+	return err
+}
+
+func (impl *MongoMappingStore) Get(ctx context.Context, siteCode string, version int) (*models.MappingData, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	coll := impl.db.Collection(consts.MappingCollection)
+
+	filter := bson.M{"site_code": siteCode}
+	opts := options.FindOne().SetSort(bson.M{"version": -1})
+	if version != LatestMappingVersion {
+		filter["version"] = version
+	}
+
+	var mapping models.MappingData
+	if err := coll.FindOne(ctx, filter, opts).Decode(&mapping); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMappingNotFound
+		}
+		return nil, err
+	}
+	return &mapping, nil
+}
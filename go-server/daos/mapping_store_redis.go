@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+
+	redisStorage "github.com/homingos/campaign-svc/lib/redis"
+	"github.com/homingos/campaign-svc/models"
+)
+
+// RedisMappingStore keeps every mapping version in Redis without expiry so
+// all replicas share them.
+type RedisMappingStore struct {
+	redisClient *redisStorage.RedisClient
+}
+
+func NewRedisMappingStore(redisClient *redisStorage.RedisClient) *RedisMappingStore {
+	return &RedisMappingStore{redisClient: redisClient}
+}
+
+// Save stores the mapping as the version after the latest allocated one,
+// retrying when another writer takes that version first.
+func (impl *RedisMappingStore) Save(ctx context.Context, mapping *models.MappingData) (*models.MappingData, error) {
+	for attempt := 0; attempt < mappingPatchAttempts; attempt++ {
+		latest, err := impl.redisClient.MappingVersion(ctx, mapping.SiteCode)
+		if err != nil {
+			return nil, err
+		}
+		saved := *mapping
+		saved.Version = latest + 1
+		created, err := impl.redisClient.CreateMapping(ctx, saved.SiteCode, saved.Version, &saved)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return &saved, nil
+		}
+	}
+	return nil, fmt.Errorf("mapping of %s is being saved concurrently", mapping.SiteCode)
+}
+
+func (impl *RedisMappingStore) Create(ctx context.Context, mapping *models.MappingData) error {
+	created, err := impl.redisClient.CreateMapping(ctx, mapping.SiteCode, mapping.Version, mapping)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrMappingConflict
+	}
+	return nil
+}
+
+func (impl *RedisMappingStore) Get(ctx context.Context, siteCode string, version int) (*models.MappingData, error) {
+	var mapping models.MappingData
+	found, err := impl.redisClient.GetMapping(ctx, siteCode, version, &mapping)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrMappingNotFound
+	}
+	return &mapping, nil
+}
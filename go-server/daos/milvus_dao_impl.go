@@ -4,23 +4,55 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/milvus"
 	"github.com/homingos/flam-go-common/errors"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	"go.uber.org/zap"
 )
 
+// Fields of the product vector collection that searches and deletes
+// filter on.
+const (
+	vectorFieldID        = "id"
+	vectorFieldCatalogID = "catalog_id"
+	vectorFieldClientID  = "client_id"
+	vectorFieldCategory  = "category"
+	vectorFieldPrice     = "price"
+	vectorFieldVector    = "vector_information"
+)
+
+// missingPrice is the price of products stored without one, so a price
+// range, which never goes below 0, leaves them out.
+const missingPrice = -1
+
 type MilvusDaoImpl struct {
 	lgr          *zap.SugaredLogger
 	milvusClient client.Client
+	// collection is written to and deleted from; searchColl is searched
+	// and is the same collection unless configured otherwise
+	collection string
+	searchColl string
+
+	schemaMu sync.Mutex
+	schemas  map[string]milvus.Schema
 }
 
-func NewMilvusDao(lgr *zap.SugaredLogger, milvusClient client.Client) *MilvusDaoImpl {
-	return &MilvusDaoImpl{lgr: lgr, milvusClient: milvusClient}
+func NewMilvusDao(lgr *zap.SugaredLogger, milvusClient client.Client, collection string, searchCollection string) *MilvusDaoImpl {
+	if searchCollection == "" {
+		searchCollection = collection
+	}
+	return &MilvusDaoImpl{
+		lgr:          lgr,
+		milvusClient: milvusClient,
+		collection:   collection,
+		searchColl:   searchCollection,
+		schemas:      make(map[string]milvus.Schema),
+	}
 }
 
 // L2DistanceToSimilarity converts L2 distance to similarity score
@@ -40,22 +72,20 @@ func NormalizeCOSINESimilarity(score float32) float32 {
 	return score
 }
 
-func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, siteCode string) ([]dtos.SearchResult, error) {
-	fmt.Println("searching in milvus")
+func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, siteCode string, filter dtos.VectorFilterDto, topK int) ([]dtos.SearchResult, error) {
 	searchParams, err := entity.NewIndexFlatSearchParam()
 	if err != nil {
 		return nil, errors.InternalServerError(err.Error())
 	}
-	expr := fmt.Sprintf("catalog_id == '%s'", siteCode)
-	Env := os.Getenv("APP_ENV")
-	Env = "prod"
-	// if Env == "non-prod" {
-	// 	Env = "qa"
-	// }
-	// if Env == "" {
-	// 	Env = "dev"
-	// }
-	milvusColl := fmt.Sprintf("product_vectors_%s", Env)
+	milvusColl := impl.searchColl
+	schema, err := impl.collectionSchema(ctx, milvusColl)
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	expr, err := searchFilter(siteCode, filter, schema).Expr(schema)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
 
 	// fmt.Println(milvusColl)
 	// fmt.Println(embeddings)
@@ -72,9 +102,9 @@ func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, sit
 		expr,
 		[]string{"*"},
 		[]entity.Vector{entity.FloatVector(embeddings)},
-		"vector_information",
+		vectorFieldVector,
 		entity.COSINE,
-		5,
+		topK,
 		searchParams,
 	)
 
@@ -82,14 +112,10 @@ func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, sit
 		return nil, errors.InternalServerError(err.Error())
 	}
 
-	var searchResults []dtos.SearchResult
+	searchResults := []dtos.SearchResult{}
 
 	if len(results) > 0 {
 		numHits := results[0].ResultCount
-
-		if numHits == 0 {
-			return nil, fmt.Errorf("no hits found")
-		}
 		for i := 0; i < numHits; i++ {
 			id, err := results[0].IDs.GetAsString(i)
 			if err != nil {
@@ -100,6 +126,8 @@ func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, sit
 			clientID := ""
 			description := ""
 			name := ""
+			category := ""
+			var price *float64
 
 			if len(results[0].Fields) > 0 {
 				for _, field := range results[0].Fields {
@@ -118,15 +146,18 @@ func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, sit
 						if name == "" {
 							name, _ = field.GetAsString(i)
 						}
+					case vectorFieldCategory:
+						category, _ = field.GetAsString(i)
+					case vectorFieldPrice:
+						if value, err := field.GetAsDouble(i); err == nil && value != missingPrice {
+							price = &value
+						}
 					default:
 						//
 					}
 				}
 			}
 
-			_ = catalogID
-			_ = clientID
-			_ = description
 			candidate := dtos.SearchResult{
 				Document: dtos.Document{
 					ID:          id,
@@ -134,36 +165,200 @@ func (impl *MilvusDaoImpl) Search(ctx context.Context, embeddings []float32, sit
 					CatalogID:   catalogID,
 					ClientID:    clientID,
 					Description: description,
+					Category:    category,
+					Price:       price,
 				},
 				Score: score,
-			}	
+			}
 
 			searchResults = append(searchResults, candidate)
 		}
-	} else {
-		return nil, errors.InternalServerError("no results found")
 	}
 
-	PrettyPrint(searchResults)
-
+	impl.lgr.Debugw("Searched Milvus", "collection", milvusColl, "expr", expr, "topK", topK, "hits", len(searchResults))
 	return searchResults, nil
 }
 
+// Describe returns the collection, vector field, metric and filter
+// expression Search uses for a site code.
+func (impl *MilvusDaoImpl) Describe(ctx context.Context, siteCode string, filter dtos.VectorFilterDto) (*dtos.VectorQueryDto, error) {
+	milvusColl := impl.searchColl
+	schema, err := impl.collectionSchema(ctx, milvusColl)
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	expr, err := searchFilter(siteCode, filter, schema).Expr(schema)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+	return &dtos.VectorQueryDto{
+		Backend:     VectorStoreMilvus,
+		Collection:  milvusColl,
+		VectorField: vectorFieldVector,
+		Metric:      string(entity.COSINE),
+		Expression:  expr,
+	}, nil
+}
+
+// searchFilter narrows a search to a site code and whatever else the
+// filter sets. Collections written before category and price were stored
+// are not narrowed by them; search drops products outside the categories
+// or price range of a query from the catalogue after retrieval either way.
+func searchFilter(siteCode string, filter dtos.VectorFilterDto, schema milvus.Schema) milvus.Filter {
+	filters := []milvus.Filter{milvus.Eq(vectorFieldCatalogID, siteCode)}
+	if filter.ClientID != "" {
+		filters = append(filters, milvus.Eq(vectorFieldClientID, filter.ClientID))
+	}
+	if len(filter.Categories) > 0 && schema.Has(vectorFieldCategory) {
+		filters = append(filters, milvus.In(vectorFieldCategory, stringValues(filter.Categories)...))
+	}
+	if (filter.MinPrice != nil || filter.MaxPrice != nil) && schema.Has(vectorFieldPrice) {
+		minPrice := filter.MinPrice
+		if minPrice == nil || *minPrice < 0 {
+			// products without a price are stored below 0
+			zero := 0.0
+			minPrice = &zero
+		}
+		filters = append(filters, milvus.Range(vectorFieldPrice, minPrice, filter.MaxPrice))
+	}
+	if len(filter.ProductIDs) > 0 {
+		filters = append(filters, milvus.In(vectorFieldID, stringValues(filter.ProductIDs)...))
+	}
+	return milvus.And(filters...)
+}
+
+func stringValues(values []string) []interface{} {
+	converted := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		converted = append(converted, value)
+	}
+	return converted
+}
+
+// collectionSchema returns the fields a collection can be filtered on,
+// described once per collection.
+func (impl *MilvusDaoImpl) collectionSchema(ctx context.Context, collection string) (milvus.Schema, error) {
+	impl.schemaMu.Lock()
+	defer impl.schemaMu.Unlock()
+	if schema, ok := impl.schemas[collection]; ok {
+		return schema, nil
+	}
+	described, err := impl.milvusClient.DescribeCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	schema := milvus.SchemaOf(described.Schema)
+	impl.schemas[collection] = schema
+	return schema, nil
+}
+
+// Upsert writes documents to the collection, replacing those with the same
+// ID. Name, description, category and price are written when the collection
+// has them, so searches can return and filter on them.
+func (impl *MilvusDaoImpl) Upsert(ctx context.Context, docs []dtos.VectorDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	milvusColl := impl.collection
+	schema, err := impl.collectionSchema(ctx, milvusColl)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+	dimension := len(docs[0].Vector)
+	ids := make([]string, 0, len(docs))
+	catalogIDs := make([]string, 0, len(docs))
+	clientIDs := make([]string, 0, len(docs))
+	names := make([]string, 0, len(docs))
+	descriptions := make([]string, 0, len(docs))
+	categories := make([]string, 0, len(docs))
+	prices := make([]float64, 0, len(docs))
+	vectors := make([][]float32, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return errors.BadRequest("vector document without id")
+		}
+		if dimension == 0 || len(doc.Vector) != dimension {
+			return errors.BadRequest(fmt.Sprintf("document %s has dimension %d, expected %d", doc.ID, len(doc.Vector), dimension))
+		}
+		ids = append(ids, doc.ID)
+		catalogIDs = append(catalogIDs, doc.CatalogID)
+		clientIDs = append(clientIDs, doc.ClientID)
+		names = append(names, doc.Name)
+		descriptions = append(descriptions, doc.Description)
+		categories = append(categories, doc.Category)
+		price := float64(missingPrice)
+		if doc.Price != nil {
+			price = *doc.Price
+		}
+		prices = append(prices, price)
+		vectors = append(vectors, doc.Vector)
+	}
+
+	columns := []entity.Column{
+		entity.NewColumnVarChar(vectorFieldID, ids),
+		entity.NewColumnVarChar(vectorFieldCatalogID, catalogIDs),
+		entity.NewColumnVarChar(vectorFieldClientID, clientIDs),
+		entity.NewColumnFloatVector(vectorFieldVector, dimension, vectors),
+	}
+	if schema.Has("name") {
+		columns = append(columns, entity.NewColumnVarChar("name", names))
+	}
+	if schema.Has("description") {
+		columns = append(columns, entity.NewColumnVarChar("description", descriptions))
+	}
+	if schema.Has(vectorFieldCategory) {
+		columns = append(columns, entity.NewColumnVarChar(vectorFieldCategory, categories))
+	}
+	if schema.Has(vectorFieldPrice) {
+		columns = append(columns, entity.NewColumnDouble(vectorFieldPrice, prices))
+	}
+	_, err = impl.milvusClient.Upsert(ctx, milvusColl, "", columns...)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+	return nil
+}
+
+// LoadState reports whether the searched collection is loaded into memory.
+func (impl *MilvusDaoImpl) LoadState(ctx context.Context) (*dtos.VectorStoreStateDto, error) {
+	collection := impl.searchColl
+	state, err := impl.milvusClient.GetLoadState(ctx, collection, nil)
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	states := map[entity.LoadState]string{
+		entity.LoadStateNotExist: "not_exist",
+		entity.LoadStateNotLoad:  "not_loaded",
+		entity.LoadStateLoading:  "loading",
+		entity.LoadStateLoaded:   "loaded",
+	}
+	return &dtos.VectorStoreStateDto{
+		Backend:    VectorStoreMilvus,
+		Collection: collection,
+		State:      states[state],
+		Loaded:     state == entity.LoadStateLoaded,
+	}, nil
+}
+
 func (impl *MilvusDaoImpl) Delete(ctx context.Context, clientID string, milvusRefID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	Env := os.Getenv("APP_ENV")
-	if Env == "non-prod" {
-		Env = "qa"
+	milvusColl := impl.collection
+	schema, err := impl.collectionSchema(ctx, milvusColl)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
 	}
-	if Env == "" {
-		Env = "prod"
+	filter := milvus.Eq(vectorFieldID, milvusRefID)
+	if clientID != "" {
+		filter = milvus.And(filter, milvus.Eq(vectorFieldClientID, clientID))
+	}
+	expr, err := filter.Expr(schema)
+	if err != nil {
+		return errors.BadRequest(err.Error())
 	}
-	milvusColl := fmt.Sprintf("product_vectors_%s", Env)
-	expr := fmt.Sprintf("id == '%s'", milvusRefID)
 
-	err := impl.milvusClient.Delete(ctx, milvusColl, "", expr)
+	err = impl.milvusClient.Delete(ctx, milvusColl, "", expr)
 	if err != nil {
 		return errors.InternalServerError(err.Error())
 	}
@@ -0,0 +1,21 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrSynonymNotFound = errors.New("synonym not found")
+
+// SynonymDao stores the synonym dictionaries of site codes, one document
+// per entry.
+type SynonymDao interface {
+	CreateSynonymDao(ctx context.Context, siteCode string, synonymDto dtos.SynonymDto) (*models.Synonym, error)
+	GetSynonymsBySiteCodeDao(ctx context.Context, siteCode string) ([]models.Synonym, error)
+	UpdateSynonymDao(ctx context.Context, siteCode string, ID primitive.ObjectID, synonymDto dtos.SynonymDto) (*models.Synonym, error)
+	DeleteSynonymDao(ctx context.Context, siteCode string, ID primitive.ObjectID) error
+}
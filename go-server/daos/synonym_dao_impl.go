@@ -0,0 +1,119 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type SynonymDaoImpl struct {
+	lgr *zap.SugaredLogger
+	db  *mongo.Database
+}
+
+func createSynonymIndexes(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	coll := db.Collection(consts.SynonymCollection)
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "site_code", Value: 1}},
+		},
+	}
+	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	_, err := coll.Indexes().CreateMany(ctx, indexes, opts)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func NewSynonymDao(lgr *zap.SugaredLogger, db *mongo.Database) *SynonymDaoImpl {
+	createSynonymIndexes(db)
+	return &SynonymDaoImpl{lgr: lgr, db: db}
+}
+
+func (impl *SynonymDaoImpl) CreateSynonymDao(ctx context.Context, siteCode string, synonymDto dtos.SynonymDto) (*models.Synonym, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	synonym := models.Synonym{
+		ID:        primitive.NewObjectID(),
+		SiteCode:  siteCode,
+		Direction: synonymDto.Direction,
+		Term:      synonymDto.Term,
+		Synonyms:  synonymDto.Synonyms,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	coll := impl.db.Collection(consts.SynonymCollection)
+	if _, err := coll.InsertOne(ctx, synonym); err != nil {
+		return nil, err
+	}
+	return &synonym, nil
+}
+
+func (impl *SynonymDaoImpl) GetSynonymsBySiteCodeDao(ctx context.Context, siteCode string) ([]models.Synonym, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll := impl.db.Collection(consts.SynonymCollection)
+	cursor, err := coll.Find(ctx, bson.M{"site_code": siteCode}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	synonyms := []models.Synonym{}
+	if err := cursor.All(ctx, &synonyms); err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+func (impl *SynonymDaoImpl) UpdateSynonymDao(ctx context.Context, siteCode string, ID primitive.ObjectID, synonymDto dtos.SynonymDto) (*models.Synonym, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll := impl.db.Collection(consts.SynonymCollection)
+	update := bson.M{
+		"$set": bson.M{
+			"direction":  synonymDto.Direction,
+			"term":       synonymDto.Term,
+			"synonyms":   synonymDto.Synonyms,
+			"updated_at": time.Now().UTC(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var synonym models.Synonym
+	err := coll.FindOneAndUpdate(ctx, bson.M{"_id": ID, "site_code": siteCode}, update, opts).Decode(&synonym)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSynonymNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &synonym, nil
+}
+
+func (impl *SynonymDaoImpl) DeleteSynonymDao(ctx context.Context, siteCode string, ID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	coll := impl.db.Collection(consts.SynonymCollection)
+	result, err := coll.DeleteOne(ctx, bson.M{"_id": ID, "site_code": siteCode})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSynonymNotFound
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+const (
+	VectorStoreMilvus = "milvus"
+	VectorStoreMemory = "memory"
+)
+
+// VectorStore keeps product embeddings and finds the nearest ones to a
+// query embedding within a site code.
+type VectorStore interface {
+	// Search returns up to topK nearest products of a site code that pass
+	// filter; no hits is an empty result, not an error.
+	Search(ctx context.Context, embeddings []float32, siteCode string, filter dtos.VectorFilterDto, topK int) ([]dtos.SearchResult, error)
+	// Upsert inserts documents or replaces those with the same ID.
+	Upsert(ctx context.Context, docs []dtos.VectorDocument) error
+	// Delete removes a document, only from clientID when it is set.
+	Delete(ctx context.Context, clientID string, id string) error
+	// Describe returns where Search looks for a site code's products and
+	// the filter expression it applies, for explaining a search.
+	Describe(ctx context.Context, siteCode string, filter dtos.VectorFilterDto) (*dtos.VectorQueryDto, error)
+	// LoadState reports whether the store is ready to be searched.
+	LoadState(ctx context.Context) (*dtos.VectorStoreStateDto, error)
+}
@@ -0,0 +1,231 @@
+package dao
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/milvus"
+	"github.com/homingos/flam-go-common/errors"
+	"go.uber.org/zap"
+)
+
+type memoryVector struct {
+	doc  dtos.Document
+	unit []float32
+}
+
+// MemoryVectorStore keeps vectors in process and searches them by brute
+// force cosine similarity. It serves local runs and tests without Milvus.
+type MemoryVectorStore struct {
+	lgr  *zap.SugaredLogger
+	mu   sync.RWMutex
+	docs map[string]memoryVector
+	// schema is the fields searches filter on, memorySchema unless a
+	// collection without some of them is imitated
+	schema milvus.Schema
+}
+
+func NewMemoryVectorStore(lgr *zap.SugaredLogger) *MemoryVectorStore {
+	return &MemoryVectorStore{lgr: lgr, docs: make(map[string]memoryVector), schema: memorySchema}
+}
+
+// NewSeededMemoryVectorStore returns a memory store holding the documents
+// of a fixture file, if one is given.
+func NewSeededMemoryVectorStore(lgr *zap.SugaredLogger, fixturePath string) (*MemoryVectorStore, error) {
+	store := NewMemoryVectorStore(lgr)
+	if fixturePath == "" {
+		return store, nil
+	}
+	docs, err := LoadVectorFixture(fixturePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Upsert(context.Background(), docs); err != nil {
+		return nil, err
+	}
+	lgr.Infow("Seeded memory vector store", "fixture", fixturePath, "documents", len(docs))
+	return store, nil
+}
+
+// LoadVectorFixture reads vector documents from a JSON array or from JSON
+// lines, one document per line.
+func LoadVectorFixture(path string) ([]dtos.VectorDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var docs []dtos.VectorDocument
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &docs); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return docs, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var doc dtos.VectorDocument
+		if err := json.Unmarshal(text, &doc); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, scanner.Err()
+}
+
+func (impl *MemoryVectorStore) Search(ctx context.Context, embeddings []float32, siteCode string, filter dtos.VectorFilterDto, topK int) ([]dtos.SearchResult, error) {
+	query := unitVector(embeddings)
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+
+	results := []dtos.SearchResult{}
+	for _, vector := range impl.docs {
+		if vector.doc.CatalogID != siteCode || !matchesFilter(vector.doc, filter, impl.schema) {
+			continue
+		}
+		if len(vector.unit) != len(query) {
+			return nil, errors.InternalServerError(fmt.Sprintf("query has dimension %d, document %s has %d", len(query), vector.doc.ID, len(vector.unit)))
+		}
+		var score float32
+		for i := range query {
+			score += query[i] * vector.unit[i]
+		}
+		results = append(results, dtos.SearchResult{Document: vector.doc, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// Upsert inserts documents or replaces those with the same ID.
+func (impl *MemoryVectorStore) Upsert(ctx context.Context, docs []dtos.VectorDocument) error {
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return errors.BadRequest("vector document without id")
+		}
+		if len(doc.Vector) == 0 {
+			return errors.BadRequest(fmt.Sprintf("vector document %s has no vector", doc.ID))
+		}
+	}
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	for _, doc := range docs {
+		impl.docs[doc.ID] = memoryVector{doc: doc.Document, unit: unitVector(doc.Vector)}
+	}
+	return nil
+}
+
+func (impl *MemoryVectorStore) Delete(ctx context.Context, clientID string, id string) error {
+	impl.mu.Lock()
+	if vector, ok := impl.docs[id]; ok && (clientID == "" || vector.doc.ClientID == clientID) {
+		delete(impl.docs, id)
+	}
+	impl.mu.Unlock()
+	return nil
+}
+
+func (impl *MemoryVectorStore) LoadState(ctx context.Context) (*dtos.VectorStoreStateDto, error) {
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+	return &dtos.VectorStoreStateDto{
+		Backend:   VectorStoreMemory,
+		State:     "loaded",
+		Loaded:    true,
+		Documents: len(impl.docs),
+	}, nil
+}
+
+// memorySchema is the fields a memory store filters on, as the search
+// collection has them.
+var memorySchema = milvus.Schema{
+	vectorFieldID:        milvus.KindString,
+	vectorFieldCatalogID: milvus.KindString,
+	vectorFieldClientID:  milvus.KindString,
+	vectorFieldCategory:  milvus.KindString,
+	vectorFieldPrice:     milvus.KindFloat,
+}
+
+// Describe returns the expression Milvus would filter a search with; the
+// memory store applies the same filter by brute force.
+func (impl *MemoryVectorStore) Describe(ctx context.Context, siteCode string, filter dtos.VectorFilterDto) (*dtos.VectorQueryDto, error) {
+	expr, err := searchFilter(siteCode, filter, impl.schema).Expr(impl.schema)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+	return &dtos.VectorQueryDto{Backend: VectorStoreMemory, Metric: "COSINE", Expression: expr}, nil
+}
+
+// matchesFilter applies a search filter the way searchFilter has Milvus
+// do it: category and price only narrow a schema that has them, and a
+// price range drops documents without a price.
+func matchesFilter(doc dtos.Document, filter dtos.VectorFilterDto, schema milvus.Schema) bool {
+	if filter.ClientID != "" && doc.ClientID != filter.ClientID {
+		return false
+	}
+	if len(filter.Categories) > 0 && schema.Has(vectorFieldCategory) && !containsString(filter.Categories, doc.Category) {
+		return false
+	}
+	if (filter.MinPrice != nil || filter.MaxPrice != nil) && schema.Has(vectorFieldPrice) {
+		// like searchFilter, a range never goes below 0
+		minPrice := 0.0
+		if filter.MinPrice != nil && *filter.MinPrice > 0 {
+			minPrice = *filter.MinPrice
+		}
+		if doc.Price == nil || *doc.Price < minPrice {
+			return false
+		}
+		if filter.MaxPrice != nil && *doc.Price > *filter.MaxPrice {
+			return false
+		}
+	}
+	if len(filter.ProductIDs) > 0 && !containsString(filter.ProductIDs, doc.ID) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// unitVector returns a copy of vector scaled to length 1, so a dot product
+// is the cosine similarity.
+func unitVector(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	unit := make([]float32, len(vector))
+	if norm == 0 {
+		return unit
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i, v := range vector {
+		unit[i] = v * scale
+	}
+	return unit
+}
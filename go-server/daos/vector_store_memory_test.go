@@ -0,0 +1,195 @@
+package dao
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/milvus"
+	"go.uber.org/zap"
+)
+
+func price(value float64) *float64 {
+	return &value
+}
+
+// seededStore holds four products of site s1 at known angles from the
+// query [1, 0] and one of site s2 right on it.
+func seededStore(t *testing.T) *MemoryVectorStore {
+	t.Helper()
+	store := NewMemoryVectorStore(zap.NewNop().Sugar())
+	docs := []dtos.VectorDocument{
+		{Document: dtos.Document{ID: "sofa", CatalogID: "s1", ClientID: "c1", Category: "Seating", Price: price(900)}, Vector: []float32{2, 0}},
+		{Document: dtos.Document{ID: "chair", CatalogID: "s1", ClientID: "c1", Category: "Seating", Price: price(300)}, Vector: []float32{3, 1}},
+		{Document: dtos.Document{ID: "lamp", CatalogID: "s1", ClientID: "c2", Category: "Lighting"}, Vector: []float32{1, 1}},
+		{Document: dtos.Document{ID: "rug", CatalogID: "s1", ClientID: "c2", Category: "Decor", Price: price(500)}, Vector: []float32{0, 1}},
+		{Document: dtos.Document{ID: "other", CatalogID: "s2", ClientID: "c1", Category: "Seating"}, Vector: []float32{1, 0}},
+	}
+	if err := store.Upsert(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func resultIDs(results []dtos.SearchResult) []string {
+	ids := []string{}
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestMemoryVectorStoreSearch(t *testing.T) {
+	store := seededStore(t)
+	results, err := store.Search(context.Background(), []float32{5, 0}, "s1", dtos.VectorFilterDto{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resultIDs(results), []string{"sofa", "chair", "lamp", "rug"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+	// cosine similarity, whatever the vector lengths
+	wantScores := []float32{1, 0.9486833, 0.70710677, 0}
+	for i, result := range results {
+		if diff := result.Score - wantScores[i]; diff > 1e-6 || diff < -1e-6 {
+			t.Errorf("score of %s = %v, want %v", result.ID, result.Score, wantScores[i])
+		}
+	}
+
+	top, err := store.Search(context.Background(), []float32{5, 0}, "s1", dtos.VectorFilterDto{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resultIDs(top), []string{"sofa", "chair"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top 2 = %v, want %v", got, want)
+	}
+
+	none, err := store.Search(context.Background(), []float32{5, 0}, "s3", dtos.VectorFilterDto{}, 10)
+	if err != nil || none == nil || len(none) != 0 {
+		t.Errorf("unknown site = %v, %v, want an empty result", none, err)
+	}
+}
+
+func TestMemoryVectorStoreFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter dtos.VectorFilterDto
+		schema milvus.Schema
+		extra  []dtos.VectorDocument
+		want   []string
+	}{
+		{name: "client", filter: dtos.VectorFilterDto{ClientID: "c2"}, want: []string{"lamp", "rug"}},
+		{name: "categories", filter: dtos.VectorFilterDto{Categories: []string{"Seating", "Decor"}}, want: []string{"sofa", "chair", "rug"}},
+		{name: "price range drops products without a price", filter: dtos.VectorFilterDto{MinPrice: price(300), MaxPrice: price(500)}, want: []string{"chair", "rug"}},
+		{name: "max price only", filter: dtos.VectorFilterDto{MaxPrice: price(600)}, want: []string{"chair", "rug"}},
+		{
+			// a price stored the way Milvus stores a missing one stays out
+			name:   "negative min price starts at 0",
+			filter: dtos.VectorFilterDto{MinPrice: price(-5), MaxPrice: price(400)},
+			extra:  []dtos.VectorDocument{{Document: dtos.Document{ID: "stool", CatalogID: "s1", Price: price(missingPrice)}, Vector: []float32{1, 0}}},
+			want:   []string{"chair"},
+		},
+		{name: "product ids", filter: dtos.VectorFilterDto{ProductIDs: []string{"rug", "sofa", "other"}}, want: []string{"sofa", "rug"}},
+		{name: "combined", filter: dtos.VectorFilterDto{ClientID: "c1", Categories: []string{"Seating"}, MinPrice: price(500)}, want: []string{"sofa"}},
+		{
+			// like Milvus, a collection written before category and price
+			// were stored is not narrowed by them
+			name:   "schema without category and price",
+			filter: dtos.VectorFilterDto{ClientID: "c2", Categories: []string{"Seating"}, MaxPrice: price(100)},
+			schema: milvus.Schema{
+				vectorFieldID:        milvus.KindString,
+				vectorFieldCatalogID: milvus.KindString,
+				vectorFieldClientID:  milvus.KindString,
+			},
+			want: []string{"lamp", "rug"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := seededStore(t)
+			if test.schema != nil {
+				store.schema = test.schema
+			}
+			if err := store.Upsert(context.Background(), test.extra); err != nil {
+				t.Fatal(err)
+			}
+			results, err := store.Search(context.Background(), []float32{1, 0}, "s1", test.filter, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultIDs(results); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ids = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMemoryVectorStoreDimensionMismatch(t *testing.T) {
+	store := seededStore(t)
+	if _, err := store.Search(context.Background(), []float32{1, 0, 0}, "s1", dtos.VectorFilterDto{}, 10); err == nil {
+		t.Error("search with a 3 dimensional query succeeded, want an error")
+	}
+}
+
+func TestMemoryVectorStoreUpsertAndDelete(t *testing.T) {
+	store := seededStore(t)
+	ctx := context.Background()
+	if err := store.Upsert(ctx, []dtos.VectorDocument{{Document: dtos.Document{ID: "rug", CatalogID: "s1", ClientID: "c2"}, Vector: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Upsert(ctx, []dtos.VectorDocument{{Document: dtos.Document{ID: "empty", CatalogID: "s1"}}}); err == nil {
+		t.Error("upserting a document without a vector succeeded, want an error")
+	}
+	// another client's delete leaves the document alone
+	if err := store.Delete(ctx, "c1", "lamp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "", "chair"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := store.Search(ctx, []float32{1, 0}, "s1", dtos.VectorFilterDto{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resultIDs(results), []string{"rug", "sofa", "lamp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+	state, err := store.LoadState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Documents != 4 || !state.Loaded {
+		t.Errorf("state = %+v, want 4 loaded documents", state)
+	}
+}
+
+func TestLoadVectorFixture(t *testing.T) {
+	dir := t.TempDir()
+	fixtures := map[string]string{
+		"array.json":  `[{"id": "a", "catalog_id": "s1", "price": 10, "vector": [1, 0]}, {"id": "b", "catalog_id": "s1", "vector": [0, 1]}]`,
+		"lines.jsonl": "{\"id\": \"a\", \"catalog_id\": \"s1\", \"price\": 10, \"vector\": [1, 0]}\n\n{\"id\": \"b\", \"catalog_id\": \"s1\", \"vector\": [0, 1]}\n",
+	}
+	for name, content := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			store, err := NewSeededMemoryVectorStore(zap.NewNop().Sugar(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := store.Search(context.Background(), []float32{1, 0}, "s1", dtos.VectorFilterDto{MaxPrice: price(20)}, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultIDs(results); !reflect.DeepEqual(got, []string{"a"}) {
+				t.Errorf("ids = %v, want [a]", got)
+			}
+		})
+	}
+}
@@ -164,3 +164,19 @@ type ClientCampaignsInfo struct {
 	Name      string             `json:"name" bson:"name"`
 	ShortCode string             `json:"short_code" bson:"short_code"`
 }
+
+// MilvusCampaignRefDto - the campaign a vector store document belongs to
+type MilvusCampaignRefDto struct {
+	ID          primitive.ObjectID `json:"_id" bson:"_id"`
+	MilvusRefID string             `json:"milvus_ref_id" bson:"milvus_ref_id"`
+	ShortCode   string             `json:"short_code" bson:"short_code"`
+	Name        string             `json:"name" bson:"name"`
+}
+
+// CampaignStateDto - whether a campaign can be served: it is active and one
+// of its active experiences is processed
+type CampaignStateDto struct {
+	ShortCode          string   `json:"short_code" bson:"short_code"`
+	IsActive           bool     `json:"is_active" bson:"is_active"`
+	ExperienceStatuses []string `json:"experience_statuses" bson:"experience_statuses"`
+}
@@ -26,6 +26,11 @@ type CategorySearchResponseDto struct {
 	ShareMeta       models.CategoryShareMeta      `bson:"share_meta" json:"share_meta"`
 	Categories      []CategoriesSearchResponseDto `bson:"categories" json:"categories"`
 	OrderButtonText string                        `json:"order_button_text"`
+	Intent          string                        `bson:"-" json:"intent,omitempty"`
+	ExpandedQuery   string                        `bson:"-" json:"expanded_query,omitempty"`
+	NoConfidentMatch bool                         `bson:"-" json:"no_confident_match,omitempty"`
+	Page            *PageDto                      `bson:"-" json:"page,omitempty"`
+	Results         []ResultItem                  `bson:"-" json:"results"`
 }
 
 type CategoriesSearchResponseDto struct {
@@ -135,11 +140,23 @@ type ProductDto struct {
 }
 
 type Document struct {
-	ID          string `json:"id"`
-	Name 	  	string `json:"name"`
-	CatalogID   string `json:"catalog_id"`
-	ClientID    string `json:"client_id"`
-	Description string `json:"description"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	CatalogID   string   `json:"catalog_id"`
+	ClientID    string   `json:"client_id"`
+	Description string   `json:"description"`
+	Category    string   `json:"category,omitempty"`
+	Price       *float64 `json:"price,omitempty"`
+}
+
+// VectorFilterDto - narrows a vector search beyond the site code; empty
+// fields do not filter
+type VectorFilterDto struct {
+	ClientID   string   `json:"client_id,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	MinPrice   *float64 `json:"min_price,omitempty"`
+	MaxPrice   *float64 `json:"max_price,omitempty"`
+	ProductIDs []string `json:"product_ids,omitempty"`
 }
 
 type SearchResult struct {
@@ -147,6 +164,21 @@ type SearchResult struct {
 	Score float32 `json:"score,omitempty"`
 }
 
+// VectorDocument - a product embedding as kept in a vector store
+type VectorDocument struct {
+	Document
+	Vector []float32 `json:"vector"`
+}
+
+// VectorStoreStateDto - whether a vector store is loaded and ready to search
+type VectorStoreStateDto struct {
+	Backend    string `json:"backend"`
+	Collection string `json:"collection,omitempty"`
+	State      string `json:"state"`
+	Loaded     bool   `json:"loaded"`
+	Documents  int    `json:"documents,omitempty"`
+}
+
 type EmbeddingResponse struct {
 	Embedding []float32 `json:"embedding,omitempty"`
 	ModelName string    `json:"model_name,omitempty"`
@@ -154,6 +186,15 @@ type EmbeddingResponse struct {
 	DType     string    `json:"d_type,omitempty"`
 }
 
+// EmbeddingBatchResponse - the embedding API's answer to a batch of texts,
+// one embedding per text in request order
+type EmbeddingBatchResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	ModelName  string      `json:"model_name,omitempty"`
+	Dimension  int         `json:"dimension,omitempty"`
+	DType      string      `json:"d_type,omitempty"`
+}
+
 type ReprocessCatalogueDto struct {
 	ClientID   string `json:"client_id"`
 	CampaignID string `json:"campaign_id" validate:"required"`
@@ -0,0 +1,52 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/homingos/campaign-svc/models"
+)
+
+// GoldenExpectedDto - a product a golden question expects, named or given
+// by short code; relevance defaults to 1
+type GoldenExpectedDto struct {
+	Name      string `json:"name"`
+	ShortCode string `json:"short_code,omitempty"`
+	Relevance int    `json:"relevance,omitempty"`
+}
+
+// GoldenQuestionDto - a labeled query as it is created, updated and imported
+type GoldenQuestionDto struct {
+	ID       string              `json:"id,omitempty"`
+	Text     string              `json:"text"`
+	Expected []GoldenExpectedDto `json:"expected"`
+	Intent   string              `json:"intent"`
+	Tags     []string            `json:"tags"`
+}
+
+// GoldenSetVersionDto - one version of a site code's golden set, without its questions
+type GoldenSetVersionDto struct {
+	SiteCode       string    `json:"site_code" bson:"site_code"`
+	Version        int       `json:"version" bson:"version"`
+	MappingVersion int       `json:"mapping_version" bson:"mapping_version"`
+	Change         string    `json:"change" bson:"change"`
+	Count          int       `json:"count" bson:"count"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+}
+
+// GoldenSetChangeDto - the version a change to a golden set saved and the
+// question it created or updated
+type GoldenSetChangeDto struct {
+	GoldenSetVersionDto
+	Question *models.GoldenQuestion `json:"question,omitempty"`
+}
+
+// GeneratedQuestionsDto - questions generated from a site code's catalogue,
+// counted by intent, and the golden set version they were saved as
+type GeneratedQuestionsDto struct {
+	SiteCode       string              `json:"site_code"`
+	MappingVersion int                 `json:"mapping_version"`
+	Count          int                 `json:"count"`
+	ByIntent       map[string]int      `json:"by_intent"`
+	Questions      []GoldenQuestionDto `json:"questions"`
+	Saved          *GoldenSetChangeDto `json:"saved,omitempty"`
+}
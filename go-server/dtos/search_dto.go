@@ -0,0 +1,253 @@
+package dtos
+
+type ResultItem struct {
+	Code  string  `json:"code"`
+	Name  string  `json:"name"`
+	Score float32 `json:"score"`
+	// VectorScore is the similarity the vector search gave the result,
+	// which min_score cuts on; unset for results it did not retrieve
+	VectorScore *float32 `json:"vector_score,omitempty"`
+	// Confidence is the calibrated probability that the result is relevant,
+	// set once the site's scores are calibrated
+	Confidence *float32 `json:"confidence,omitempty"`
+}
+
+// SearchParamsDto - options shared by the single and batch search endpoints
+type SearchParamsDto struct {
+	MappingVersion int    `json:"mapping_version"`
+	Mode           string `json:"mode"`
+	// Intent skips classification and routes every query as this intent
+	Intent string `json:"intent"`
+	// TopK, MinScore and Limit fall back to the site's search defaults;
+	// MinScore cuts the vector search's cosine similarity only
+	TopK     int      `json:"top_k"`
+	MinScore *float64 `json:"min_score"`
+	Offset   int      `json:"offset"`
+	Limit    int      `json:"limit"`
+	// Reranker overrides the configured reranker: none, linear or cross-encoder
+	Reranker string `json:"reranker"`
+	// Explain adds how each stage ranked the candidates to the result
+	Explain bool `json:"explain"`
+	// MinConfidence overrides the site's confidence cutoff; 0 keeps every result
+	MinConfidence *float64 `json:"min_confidence"`
+	// ClientID, Categories and ProductIDs narrow the vector search; products
+	// outside Categories are dropped from lexical results as well
+	ClientID   string   `json:"client_id"`
+	Categories []string `json:"categories"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// PageDto - the slice of a query's top results that was returned
+type PageDto struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+}
+
+type BatchSearchRequestDto struct {
+	Queries     []string `json:"queries" validate:"required,min=1"`
+	Concurrency int      `json:"concurrency"`
+	RunID       string   `json:"run_id"`
+	SearchParamsDto
+}
+
+// CatalogueProductDto - an active campaign of a site code with the catalogue
+// details of its experience
+type CatalogueProductDto struct {
+	ShortCode        string              `bson:"short_code" json:"short_code"`
+	CampaignID       string              `bson:"campaign_id" json:"campaign_id"`
+	CampaignName     string              `bson:"campaign_name" json:"campaign_name"`
+	Category         string              `bson:"category" json:"category"`
+	ExperienceStatus string              `bson:"experience_status" json:"experience_status"`
+	Name             string              `bson:"name" json:"name"`
+	Description      string              `bson:"description" json:"description"`
+	Price            string              `bson:"price" json:"price"`
+	Currency         string              `bson:"currency" json:"currency"`
+	Attributes       map[string][]string `bson:"attributes" json:"attributes,omitempty"`
+}
+
+// SearchTimingsDto - per stage latency of one query in milliseconds
+type SearchTimingsDto struct {
+	EmbeddingMs float64 `json:"embedding_ms"`
+	SearchMs    float64 `json:"search_ms"`
+	ResolveMs   float64 `json:"resolve_ms"`
+	LexicalMs   float64 `json:"lexical_ms"`
+	FusionMs    float64 `json:"fusion_ms"`
+	RerankMs    float64 `json:"rerank_ms"`
+	TotalMs     float64 `json:"total_ms"`
+}
+
+// PriceConstraintDto - a price range parsed from a query, bounds inclusive
+type PriceConstraintDto struct {
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+	Text     string   `json:"text"`
+}
+
+// AttributeConstraintDto - an attribute found in a query and how it was applied
+type AttributeConstraintDto struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+	Mode      string   `json:"mode"`
+}
+
+// RerankedItemDto - a candidate's rank and score before and after reranking
+type RerankedItemDto struct {
+	Code          string             `json:"code"`
+	Name          string             `json:"name"`
+	OriginalRank  int                `json:"original_rank"`
+	OriginalScore float32            `json:"original_score"`
+	Rank          int                `json:"rank"`
+	Score         float32            `json:"score"`
+	Features      map[string]float64 `json:"features,omitempty"`
+}
+
+// RerankExplainDto - what the reranker did to the retrieved candidates
+type RerankExplainDto struct {
+	Reranker   string            `json:"reranker"`
+	Error      string            `json:"error,omitempty"`
+	Candidates []RerankedItemDto `json:"candidates"`
+}
+
+// QueryExplainDto - how a query was read: the price phrase removed, the
+// text embedded and the tokens matched lexically, after synonyms
+type QueryExplainDto struct {
+	Text         string   `json:"text"`
+	WithoutPrice string   `json:"without_price"`
+	Expanded     string   `json:"expanded"`
+	Normalized   string   `json:"normalized"`
+	Tokens       []string `json:"tokens"`
+	Intent       string   `json:"intent"`
+	IntentReason string   `json:"intent_reason"`
+}
+
+// RouteExplainDto - the retrieval strategy a query's intent chose and how
+// many of its candidates are kept
+type RouteExplainDto struct {
+	Intent     string   `json:"intent"`
+	Strategy   string   `json:"strategy"`
+	Categories []string `json:"categories,omitempty"`
+	ShortCode  string   `json:"short_code,omitempty"`
+	Limit      int      `json:"limit"`
+}
+
+// EmbeddingExplainDto - the model that embedded a query
+type EmbeddingExplainDto struct {
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+}
+
+// VectorQueryDto - where a vector store searches a site code's products
+// and the filter expression it applies
+type VectorQueryDto struct {
+	Backend     string `json:"backend"`
+	Collection  string `json:"collection,omitempty"`
+	VectorField string `json:"vector_field,omitempty"`
+	Metric      string `json:"metric"`
+	Expression  string `json:"expression"`
+}
+
+// VectorSearchExplainDto - a vector search and its raw hits with their fields
+type VectorSearchExplainDto struct {
+	VectorQueryDto
+	TopK  int            `json:"top_k"`
+	Hits  []SearchResult `json:"hits"`
+	Error string         `json:"error,omitempty"`
+}
+
+// HitResolutionDto - the campaign a vector hit's ref id resolved to, or
+// why it was dropped
+type HitResolutionDto struct {
+	ID         string  `json:"id"`
+	Score      float32 `json:"score"`
+	ShortCode  string  `json:"short_code,omitempty"`
+	CampaignID string  `json:"campaign_id,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Kept       bool    `json:"kept"`
+	Reason     string  `json:"reason,omitempty"`
+	// InMapping is false for a campaign the search's mapping does not have
+	InMapping bool `json:"in_mapping"`
+}
+
+// DroppedCandidateDto - a candidate a stage dropped and why
+type DroppedCandidateDto struct {
+	Stage  string  `json:"stage"`
+	Reason string  `json:"reason"`
+	Code   string  `json:"code"`
+	Name   string  `json:"name"`
+	Score  float32 `json:"score"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// SearchExplainDto - every stage a query went through, in order, and every
+// candidate a stage dropped. Stages the query's route skipped are left out.
+type SearchExplainDto struct {
+	Query        QueryExplainDto         `json:"query"`
+	Route        RouteExplainDto         `json:"route"`
+	Embedding    *EmbeddingExplainDto    `json:"embedding,omitempty"`
+	VectorSearch *VectorSearchExplainDto `json:"vector_search,omitempty"`
+	Resolution   []HitResolutionDto      `json:"resolution,omitempty"`
+	Lexical      []ResultItem            `json:"lexical,omitempty"`
+	Fusion       []ResultItem            `json:"fusion,omitempty"`
+	Rerank       *RerankExplainDto       `json:"rerank,omitempty"`
+	Results      []ResultItem            `json:"results"`
+	// Servable is whether each result passes the category pipeline:
+	// an active campaign with a processed experience in an active category
+	Servable map[string]bool       `json:"servable,omitempty"`
+	Dropped  []DroppedCandidateDto `json:"dropped"`
+	Errors   []string              `json:"errors,omitempty"`
+}
+
+// SynonymDto - an entry of a site code's synonym dictionary, one_way from
+// Term to Synonyms or two_way between all Synonyms
+type SynonymDto struct {
+	Direction string   `json:"direction"`
+	Term      string   `json:"term"`
+	Synonyms  []string `json:"synonyms"`
+}
+
+// AppliedSynonymDto - a dictionary phrase found in a query and the terms it added
+type AppliedSynonymDto struct {
+	Term       string   `json:"term"`
+	Expansions []string `json:"expansions"`
+}
+
+// SearchResultDto - the ranked results of one query and how they were produced
+type SearchResultDto struct {
+	Results         []ResultItem             `json:"results"`
+	ExpandedQuery   string                   `json:"expanded_query,omitempty"`
+	Synonyms        []AppliedSynonymDto      `json:"synonyms,omitempty"`
+	Intent          string                   `json:"intent,omitempty"`
+	IntentReason    string                   `json:"intent_reason,omitempty"`
+	Strategy        string                   `json:"strategy,omitempty"`
+	PriceConstraint *PriceConstraintDto      `json:"price_constraint,omitempty"`
+	Attributes      []AttributeConstraintDto `json:"attributes,omitempty"`
+	Page            *PageDto                 `json:"page,omitempty"`
+	Rerank          *RerankExplainDto        `json:"rerank,omitempty"`
+	Explain         *SearchExplainDto        `json:"explain,omitempty"`
+	Timings         SearchTimingsDto         `json:"timings"`
+	Error           string                   `json:"error,omitempty"`
+	// NoConfidentMatch is set when calibrated results all fell below the
+	// confidence cutoff and none are returned
+	NoConfidentMatch bool `json:"no_confident_match,omitempty"`
+}
+
+type BatchSearchResponseDto struct {
+	SiteCode       string                     `json:"site_code"`
+	MappingVersion int                        `json:"mapping_version"`
+	Mode           string                     `json:"mode"`
+	Results        map[string]SearchResultDto `json:"results"`
+	Failed         int                        `json:"failed"`
+	TotalMs        float64                    `json:"total_ms"`
+}
+
+// MappingUpdatedEventDto - published when a site code gets a new mapping version
+type MappingUpdatedEventDto struct {
+	SiteCode        string   `json:"site_code"`
+	Version         int      `json:"version"`
+	PreviousVersion int      `json:"previous_version"`
+	AddedShortCodes []string `json:"added_short_codes"`
+	Source          string   `json:"source"`
+	UpdatedAt       string   `json:"updated_at"`
+}
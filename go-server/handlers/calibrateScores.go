@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/embedding"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// CalibrateScoresSvc fits a score calibration for a site code from labeled
+// questions. The questions are searched in a new evaluation run with the
+// configured mode and reranker, without a confidence cutoff, and every
+// result routed through retrieval becomes a sample labeled by whether it
+// was expected. The calibration replaces the previous one of the same
+// score model.
+func (impl *CategorySvcImpl) CalibrateScoresSvc(ctx context.Context, siteCode string, questions []eval.LabeledQuestion, config eval.RunConfig, method string) (*models.ScoreCalibration, *errors.AppError) {
+	if len(questions) == 0 {
+		return nil, errors.BadRequest("no labeled questions to calibrate with")
+	}
+	if method == "" {
+		method = search.CalibrationPlatt
+	}
+	if method != search.CalibrationPlatt && method != search.CalibrationIsotonic {
+		return nil, errors.BadRequest(fmt.Sprintf("unknown calibration method %q, use %s or %s", method, search.CalibrationPlatt, search.CalibrationIsotonic))
+	}
+	if config.K <= 0 {
+		config.K = consts.DefaultCalibrationDepth
+	}
+	manifest, appErr := impl.CreateEvalRunSvc(ctx, siteCode, config)
+	if appErr != nil {
+		return nil, appErr
+	}
+	questions, _ = impl.resolveRunLabels(manifest, questions)
+
+	texts := make([]string, 0, len(questions))
+	for _, question := range questions {
+		texts = append(texts, question.Text)
+	}
+	noCutoff := 0.0
+	batch, appErr := impl.searchBatch(ctx, siteCode, &dtos.BatchSearchRequestDto{
+		Queries: texts,
+		RunID:   manifest.RunID,
+		SearchParamsDto: dtos.SearchParamsDto{
+			MappingVersion: manifest.Config.MappingVersion,
+			Mode:           manifest.Config.Mode,
+			TopK:           config.K,
+			Reranker:       manifest.Config.Reranker,
+			MinConfidence:  &noCutoff,
+		},
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	// only retrieval scores share a scale, exact names and browsed
+	// categories are scored lexically
+	samples := eval.CalibrationSamples(questions, func(text string) []dtos.ResultItem {
+		outcome := batch.Results[text]
+		if !calibratedStrategy(outcome.Strategy) {
+			return nil
+		}
+		return outcome.Results
+	})
+	var calibrator search.Calibrator
+	calibration := &models.ScoreCalibration{
+		SiteCode: siteCode,
+		Model:    search.ScoreModel(impl.embeddingModel(), manifest.Config.Mode, manifest.Config.Reranker),
+		Method:   method,
+		Samples:  len(samples),
+		RunID:    manifest.RunID,
+		FittedAt: time.Now().UTC(),
+	}
+	switch method {
+	case search.CalibrationPlatt:
+		platt, err := search.FitPlatt(samples)
+		if err != nil {
+			return nil, errors.BadRequest(err.Error())
+		}
+		calibration.PlattA, calibration.PlattB = platt.A, platt.B
+		calibrator = platt
+	case search.CalibrationIsotonic:
+		isotonic, err := search.FitIsotonic(samples)
+		if err != nil {
+			return nil, errors.BadRequest(err.Error())
+		}
+		calibration.IsotonicScores, calibration.IsotonicConfidences = isotonic.Scores, isotonic.Confidences
+		calibrator = isotonic
+	}
+	for _, sample := range samples {
+		if sample.Relevant {
+			calibration.Relevant++
+		}
+	}
+	calibration.BrierScore = search.BrierScore(calibrator, samples)
+
+	if err := impl.calibrationDao.SaveCalibrationDao(ctx, calibration); err != nil {
+		return nil, errors.InternalServerError("Failed to save calibration: " + err.Error())
+	}
+	impl.calibrations.Invalidate(siteCode)
+	return calibration, nil
+}
+
+// GetCalibrationsSvc lists the score calibrations of a site code.
+func (impl *CategorySvcImpl) GetCalibrationsSvc(ctx context.Context, siteCode string) ([]models.ScoreCalibration, *errors.AppError) {
+	calibrations, err := impl.calibrationDao.GetCalibrationsBySiteCodeDao(ctx, siteCode)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load calibrations: " + err.Error())
+	}
+	return calibrations, nil
+}
+
+// siteCalibrator returns the calibrator of a site code's score model, nil
+// when it has none or it fails to load; uncalibrated searches keep their
+// raw scores and are never cut by confidence.
+func (impl *CategorySvcImpl) siteCalibrator(ctx context.Context, siteCode string, model string) search.Calibrator {
+	if calibrator, ok := impl.calibrations.Get(siteCode, model); ok {
+		return calibrator
+	}
+	calibration, err := impl.calibrationDao.GetCalibrationDao(ctx, siteCode, model)
+	if err == dao.ErrCalibrationNotFound {
+		impl.calibrations.Put(siteCode, model, nil)
+		return nil
+	}
+	if err != nil {
+		impl.lgr.Warnw("Failed to load score calibration", "siteCode", siteCode, "model", model, "error", err)
+		return nil
+	}
+	calibrator, err := search.CalibratorOf(calibration)
+	if err != nil {
+		impl.lgr.Warnw("Ignoring invalid score calibration", "siteCode", siteCode, "model", model, "error", err)
+		calibrator = nil
+	}
+	impl.calibrations.Put(siteCode, model, calibrator)
+	return calibrator
+}
+
+// embeddingModel names the model behind query embeddings, when the
+// provider knows it.
+func (impl *CategorySvcImpl) embeddingModel() string {
+	if namer, ok := impl.embedder.(embedding.ModelNamer); ok {
+		return namer.ModelName()
+	}
+	return ""
+}
+
+// applyConfidence sets the confidence of every candidate and keeps those
+// at or above minConfidence, in their order.
+func applyConfidence(calibrator search.Calibrator, candidates []dtos.ResultItem, minConfidence float64) []dtos.ResultItem {
+	confident := make([]dtos.ResultItem, 0, len(candidates))
+	for _, candidate := range candidates {
+		confidence := calibrator.Confidence(float64(candidate.Score))
+		if confidence < minConfidence {
+			continue
+		}
+		value := float32(confidence)
+		candidate.Confidence = &value
+		confident = append(confident, candidate)
+	}
+	return confident
+}
+
+// calibratedStrategy reports whether the results of a route strategy are
+// scored by retrieval, the only scores calibrations are fitted on.
+func calibratedStrategy(strategy string) bool {
+	return strategy == strategyRetrieval || strategy == strategyFiltered || strategy == strategyDiversified
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/types/consts"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// diagnoseMisses labels every expected product the scored questions of a
+// run missed. The run's mapping tells whether the product is mapped, the
+// campaign store whether its campaign is active with a processed
+// experience, and the questions searched again MaxTopK deep, without score
+// or confidence cutoffs, whether it was retrieved below the cut.
+func (impl *CategorySvcImpl) diagnoseMisses(ctx context.Context, siteCode string, manifest *eval.RunManifest, results []eval.QuestionResult) (*eval.MissReport, *errors.AppError) {
+	shortCodesByName := make(map[string][]string)
+	for _, m := range manifest.Mapping.Mappings {
+		name := eval.NormalizeName(m.Name)
+		shortCodesByName[name] = append(shortCodesByName[name], m.ShortCode)
+	}
+
+	products := make(map[string]eval.ProductState)
+	var shortCodes []string
+	var questions []string
+	for _, result := range results {
+		if result.Error != "" || len(result.Misses) == 0 {
+			continue
+		}
+		questions = append(questions, result.Question)
+		for _, product := range result.Misses {
+			name := eval.NormalizeName(product)
+			if _, ok := products[name]; ok {
+				continue
+			}
+			products[name] = eval.ProductState{ShortCodes: shortCodesByName[name]}
+			shortCodes = append(shortCodes, shortCodesByName[name]...)
+		}
+	}
+	if len(questions) == 0 {
+		return eval.DiagnoseMisses(results, products, consts.MaxTopK, nil), nil
+	}
+
+	campaigns, err := impl.campaignDao.GetCampaignStatesByShortCodesDao(ctx, shortCodes)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load campaign states: " + err.Error())
+	}
+	for name, state := range products {
+		state.Campaigns = campaigns
+		products[name] = state
+	}
+
+	noCutoff := 0.0
+	deep, appErr := impl.searchBatch(ctx, siteCode, &dtos.BatchSearchRequestDto{
+		Queries: questions,
+		SearchParamsDto: dtos.SearchParamsDto{
+			MappingVersion: manifest.Config.MappingVersion,
+			Mode:           manifest.Config.Mode,
+			Reranker:       manifest.Config.Reranker,
+			TopK:           consts.MaxTopK,
+			Limit:          consts.MaxTopK,
+			MinScore:       &noCutoff,
+			MinConfidence:  &noCutoff,
+		},
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+	return eval.DiagnoseMisses(results, products, consts.MaxTopK, func(question string) ([]dtos.ResultItem, error) {
+		outcome := deep.Results[question]
+		if outcome.Error != "" {
+			return nil, fmt.Errorf("%s", outcome.Error)
+		}
+		return outcome.Results, nil
+	}), nil
+}
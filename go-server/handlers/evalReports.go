@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// GetEvalReportSvc returns the scored report of an evaluated run of a site
+// code.
+func (impl *CategorySvcImpl) GetEvalReportSvc(siteCode string, runID string) (*eval.Report, *errors.AppError) {
+	run, appErr := impl.GetEvalRunSvc(runID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if run.Manifest.SiteCode != siteCode {
+		return nil, errors.BadRequest(fmt.Sprintf("evaluation run %s belongs to site code %s", runID, run.Manifest.SiteCode))
+	}
+	report, err := impl.evalRuns.GetReport(runID)
+	if err == eval.ErrReportNotFound {
+		return nil, &errors.AppError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("evaluation run %s was not evaluated", runID)}
+	}
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	return report, nil
+}
+
+// CompareEvalRunsSvc scores the report of a head run against a base run of
+// the same site code, question by question.
+func (impl *CategorySvcImpl) CompareEvalRunsSvc(siteCode string, baseRunID string, headRunID string) (*eval.Comparison, *errors.AppError) {
+	base, appErr := impl.GetEvalReportSvc(siteCode, baseRunID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	head, appErr := impl.GetEvalReportSvc(siteCode, headRunID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return eval.CompareReports(base, head), nil
+}
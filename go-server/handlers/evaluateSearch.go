@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// CreateEvalRunSvc starts a new evaluation run for a site code, snapshotting
+// the mapping it will be scored against.
+func (impl *CategorySvcImpl) CreateEvalRunSvc(ctx context.Context, siteCode string, config eval.RunConfig) (*eval.RunManifest, *errors.AppError) {
+	mapping, appErr := impl.LoadMappingSvc(ctx, siteCode, config.MappingVersion)
+	if appErr != nil {
+		return nil, appErr
+	}
+	mode, err := search.ParseMode(config.Mode, impl.searchConfig.DefaultMode)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+	reranker, appErr := impl.resolveReranker(config.Reranker)
+	if appErr != nil {
+		return nil, appErr
+	}
+	// pin the run to the version, mode and reranker that were actually resolved
+	config.MappingVersion = mapping.Version
+	config.Mode = mode
+	config.Reranker = reranker.Name()
+	manifest, err := impl.evalRuns.CreateRun(siteCode, mapping, config)
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	return manifest, nil
+}
+
+// GetEvalRunSvc returns a run's manifest and recorded entries.
+func (impl *CategorySvcImpl) GetEvalRunSvc(runID string) (*eval.Run, *errors.AppError) {
+	run, err := impl.evalRuns.GetRun(runID)
+	if err == eval.ErrRunNotFound {
+		return nil, &errors.AppError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("evaluation run not found: %s", runID)}
+	}
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	snapshot := run.Snapshot()
+	if latest := impl.evalRuns.LatestMappingVersion(snapshot.Manifest.SiteCode); latest > snapshot.Manifest.Config.MappingVersion {
+		snapshot.LatestMappingVersion = latest
+	}
+	return snapshot, nil
+}
+
+// RecordEvalRunSvc upserts the results of a query into an evaluation run.
+func (impl *CategorySvcImpl) RecordEvalRunSvc(runID string, siteCode string, text string, results []dtos.ResultItem) *errors.AppError {
+	run, appErr := impl.GetEvalRunSvc(runID)
+	if appErr != nil {
+		return appErr
+	}
+	if run.Manifest.SiteCode != siteCode {
+		return errors.BadRequest(fmt.Sprintf("evaluation run %s belongs to site code %s", runID, run.Manifest.SiteCode))
+	}
+	if err := impl.evalRuns.Record(runID, text, results); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+	return nil
+}
+
+// EvaluateSearchSvc runs labeled questions through the batch search path
+// inside a new evaluation run and scores the ranked results at k, overall
+// and per intent. Expected products are first resolved to the run's mapped
+// names, and every missed one is labeled with its cause. The report is
+// saved in the run's directory as JSON and HTML.
+func (impl *CategorySvcImpl) EvaluateSearchSvc(ctx context.Context, siteCode string, questions []eval.LabeledQuestion, config eval.RunConfig) (*eval.Report, *errors.AppError) {
+	if len(questions) == 0 {
+		return nil, errors.BadRequest("no labeled questions to evaluate")
+	}
+	if config.K <= 0 {
+		return nil, errors.BadRequest("k must be greater than zero")
+	}
+	// creating the run loads the mapping, so a missing mapping fails here
+	// instead of scoring every question as an error
+	manifest, appErr := impl.CreateEvalRunSvc(ctx, siteCode, config)
+	if appErr != nil {
+		return nil, appErr
+	}
+	questions, labels := impl.resolveRunLabels(manifest, questions)
+
+	texts := make([]string, 0, len(questions))
+	for _, question := range questions {
+		if strings.TrimSpace(question.Text) != "" {
+			texts = append(texts, question.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, errors.BadRequest("every labeled question is empty")
+	}
+	batch, appErr := impl.searchBatch(ctx, siteCode, &dtos.BatchSearchRequestDto{
+		Queries: texts,
+		RunID:   manifest.RunID,
+		SearchParamsDto: dtos.SearchParamsDto{
+			MappingVersion: manifest.Config.MappingVersion,
+			Mode:           manifest.Config.Mode,
+			TopK:           config.K,
+			Reranker:       manifest.Config.Reranker,
+		},
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	report := eval.Evaluate(ctx, siteCode, questions, config.K, func(ctx context.Context, text string) ([]dtos.ResultItem, error) {
+		// empty questions are not searched, they fail instead of scoring
+		// as a miss
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("empty question")
+		}
+		outcome, ok := batch.Results[text]
+		if !ok {
+			return nil, fmt.Errorf("question %q was not searched", text)
+		}
+		if outcome.Error != "" {
+			return nil, fmt.Errorf("%s", outcome.Error)
+		}
+		return outcome.Results, nil
+	})
+	report.RunID = manifest.RunID
+	report.Labels = labels
+	report.Misses, appErr = impl.diagnoseMisses(ctx, siteCode, manifest, report.Results)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if err := impl.evalRuns.SaveReport(manifest.RunID, report); err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	return report, nil
+}
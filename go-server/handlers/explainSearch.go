@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/embedding"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/campaign-svc/types/consts"
+)
+
+// Stages of an explained search that drop candidates.
+const (
+	stageResolution = "resolution"
+	stagePrice      = "price_filter"
+	stageAttributes = "attribute_filter"
+	stageTopK       = "top_k"
+	stageConfidence = "confidence"
+	stagePage       = "page"
+	stageCategories = "category_filter"
+)
+
+// Reasons an explained search gives for a dropped candidate.
+const (
+	dropBelowMinScore    = "below_min_score"
+	dropNoActiveCampaign = "no_active_campaign"
+	dropDuplicate        = "duplicate_short_code"
+	dropPrice            = "price_out_of_range"
+	dropAttributes       = "attribute_mismatch"
+	dropBeyondLimit      = "beyond_limit"
+	dropBelowConfidence  = "below_confidence"
+	dropOutsidePage      = "outside_page"
+	dropInactiveCampaign = "inactive_campaign"
+	dropNotProcessed     = "experience_not_processed"
+	dropNotInCategory    = "not_in_category"
+)
+
+// Retrieval strategies an explained route reports.
+const (
+	strategyExactName   = "exact_name"
+	strategyCategories  = "categories"
+	strategyTermMatch   = "term_match"
+	strategyRetrieval   = "retrieval"
+	strategyDiversified = "diversified_retrieval"
+	strategyFiltered    = "filtered_retrieval"
+	strategyAllProducts = "all_products"
+)
+
+// explainQuery records how the query text was read.
+func explainQuery(outcome *dtos.SearchResultDto, text string, queryText string, expandedText string) {
+	if outcome.Explain == nil {
+		return
+	}
+	outcome.Explain.Query = dtos.QueryExplainDto{
+		Text:         text,
+		WithoutPrice: queryText,
+		Expanded:     expandedText,
+		Normalized:   embedding.NormalizeText(expandedText),
+		Tokens:       search.Tokenize(expandedText),
+		Intent:       outcome.Intent,
+		IntentReason: outcome.IntentReason,
+	}
+}
+
+// explainRoute records the strategy the query's intent was routed to.
+func explainRoute(outcome *dtos.SearchResultDto, classification search.Classification, strategy string) {
+	outcome.Strategy = strategy
+	if outcome.Explain == nil {
+		return
+	}
+	outcome.Explain.Route = dtos.RouteExplainDto{
+		Intent:     classification.Intent,
+		Strategy:   strategy,
+		Categories: classification.Categories,
+		ShortCode:  classification.ShortCode,
+	}
+}
+
+// explainEmbedding records the model and dimension a query was embedded with.
+func explainEmbedding(outcome *dtos.SearchResultDto, embedded *dtos.EmbeddingResponse, model string) {
+	if outcome.Explain == nil {
+		return
+	}
+	if embedded.ModelName != "" {
+		model = embedded.ModelName
+	}
+	outcome.Explain.Embedding = &dtos.EmbeddingExplainDto{Model: model, Dimension: len(embedded.Embedding)}
+}
+
+// explainVectorSearch records the vector store query and its raw hits.
+func (impl *CategorySvcImpl) explainVectorSearch(ctx context.Context, scope *searchScope, topK int, filter dtos.VectorFilterDto, hits []dtos.SearchResult, searchErr error, explain *dtos.SearchExplainDto) {
+	vectorSearch := &dtos.VectorSearchExplainDto{TopK: topK, Hits: hits}
+	if vectorSearch.Hits == nil {
+		vectorSearch.Hits = []dtos.SearchResult{}
+	}
+	if searchErr != nil {
+		vectorSearch.Error = searchErr.Error()
+	}
+	query, err := impl.vectorStore.Describe(ctx, scope.siteCode, filter)
+	if err != nil {
+		explain.Errors = append(explain.Errors, "describing the vector search: "+err.Error())
+	} else {
+		vectorSearch.VectorQueryDto = *query
+	}
+	explain.VectorSearch = vectorSearch
+}
+
+// explainHit records how a vector hit resolved to a campaign, and the hit
+// as dropped when it did not.
+func explainHit(explain *dtos.SearchExplainDto, doc dtos.SearchResult, campaign dtos.MilvusCampaignRefDto, found bool, reason string, minScore float32, names map[string]string) {
+	if explain == nil {
+		return
+	}
+	resolution := dtos.HitResolutionDto{ID: doc.ID, Score: doc.Score, Kept: reason == "", Reason: reason}
+	if found {
+		resolution.ShortCode = campaign.ShortCode
+		resolution.CampaignID = campaign.ID.Hex()
+		resolution.Name = campaign.Name
+		_, resolution.InMapping = names[campaign.ShortCode]
+	}
+	explain.Resolution = append(explain.Resolution, resolution)
+	if reason == "" {
+		return
+	}
+
+	dropped := dtos.DroppedCandidateDto{Stage: stageResolution, Reason: reason, Code: resolution.ShortCode, Name: doc.Name, Score: doc.Score}
+	switch reason {
+	case dropBelowMinScore:
+		dropped.Detail = fmt.Sprintf("ref id %s scored below min_score %.4f", doc.ID, minScore)
+	case dropNoActiveCampaign:
+		dropped.Detail = fmt.Sprintf("no active campaign has milvus_ref_id %s", doc.ID)
+	case dropDuplicate:
+		dropped.Detail = fmt.Sprintf("ref id %s resolves to a short code a better hit already has", doc.ID)
+	}
+	explain.Dropped = append(explain.Dropped, dropped)
+}
+
+// snapshot copies the candidates of an explained query before a stage
+// filters them in place.
+func snapshot(outcome *dtos.SearchResultDto, candidates []dtos.ResultItem) []dtos.ResultItem {
+	if outcome.Explain == nil {
+		return nil
+	}
+	return append([]dtos.ResultItem(nil), candidates...)
+}
+
+// explainDropped records the candidates a stage left out of kept, with
+// their 1-based rank before it.
+func explainDropped(outcome *dtos.SearchResultDto, stage string, reason string, before []dtos.ResultItem, kept []dtos.ResultItem, detail func(rank int, item dtos.ResultItem) string) {
+	if outcome.Explain == nil {
+		return
+	}
+	keptCodes := make(map[string]bool, len(kept))
+	for _, item := range kept {
+		keptCodes[item.Code] = true
+	}
+	for i, item := range before {
+		if keptCodes[item.Code] {
+			continue
+		}
+		outcome.Explain.Dropped = append(outcome.Explain.Dropped, dtos.DroppedCandidateDto{
+			Stage:  stage,
+			Reason: reason,
+			Code:   item.Code,
+			Name:   item.Name,
+			Score:  item.Score,
+			Detail: detail(i+1, item),
+		})
+	}
+}
+
+// explainServing records the final results and checks them against the
+// category pipeline the category response applies: a result survives when
+// it is in an active category of the site code, its campaign is active and
+// one of its active experiences is processed. Results that do not are
+// dropped with the first of those that fails.
+func (impl *CategorySvcImpl) explainServing(ctx context.Context, scope *searchScope, text string, outcome *dtos.SearchResultDto) {
+	explain := outcome.Explain
+	explain.Rerank = outcome.Rerank
+	explain.Results = outcome.Results
+	if len(outcome.Results) == 0 {
+		return
+	}
+
+	shortCodes := make([]string, 0, len(outcome.Results))
+	for _, item := range outcome.Results {
+		shortCodes = append(shortCodes, item.Code)
+	}
+	data, err := impl.categoryDao.GetCategoriesBySiteCodeDao(ctx, scope.siteCode, shortCodes, text)
+	if err != nil {
+		explain.Errors = append(explain.Errors, "checking the category pipeline: "+err.Error())
+		return
+	}
+	servable := make(map[string]bool, len(shortCodes))
+	if categories, ok := data.(*dtos.CategorySearchResponseDto); ok && categories != nil {
+		for _, category := range categories.Categories {
+			for _, shortCode := range category.Campaigns {
+				servable[shortCode] = true
+			}
+		}
+	}
+	explain.Servable = make(map[string]bool, len(shortCodes))
+	var unservable []string
+	for _, shortCode := range shortCodes {
+		explain.Servable[shortCode] = servable[shortCode]
+		if !servable[shortCode] {
+			unservable = append(unservable, shortCode)
+		}
+	}
+	if len(unservable) == 0 {
+		return
+	}
+
+	states, err := impl.campaignDao.GetCampaignStatesByShortCodesDao(ctx, unservable)
+	if err != nil {
+		explain.Errors = append(explain.Errors, "loading campaign states: "+err.Error())
+		return
+	}
+	for _, item := range outcome.Results {
+		if servable[item.Code] {
+			continue
+		}
+		state, ok := states[item.Code]
+		reason, detail := unservableReason(state, ok)
+		explain.Dropped = append(explain.Dropped, dtos.DroppedCandidateDto{
+			Stage:  stageCategories,
+			Reason: reason,
+			Code:   item.Code,
+			Name:   item.Name,
+			Score:  item.Score,
+			Detail: detail,
+		})
+	}
+}
+
+// unservableReason is why the category pipeline drops a campaign, in the
+// order GetCategoriesBySiteCodeDao checks it.
+func unservableReason(state dtos.CampaignStateDto, found bool) (string, string) {
+	if !found {
+		return dropInactiveCampaign, "no campaign has this short code"
+	}
+	if !state.IsActive {
+		return dropInactiveCampaign, "campaign is inactive"
+	}
+	for _, status := range state.ExperienceStatuses {
+		if status == consts.Processed {
+			return dropNotInCategory, "not in an active category of the site code"
+		}
+	}
+	if len(state.ExperienceStatuses) == 0 {
+		return dropNotProcessed, "no active experience"
+	}
+	return dropNotProcessed, "experience status " + strings.Join(state.ExperienceStatuses, ", ")
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// GenerateQuestionsSvc generates labeled questions for every intent from
+// the catalogue of a site code's latest mapping. With save they are
+// imported into the golden set; generated ids are stable, so saving again
+// replaces the questions generated before instead of adding to them.
+func (impl *CategorySvcImpl) GenerateQuestionsSvc(ctx context.Context, siteCode string, options eval.GenerateOptions, save bool) (*dtos.GeneratedQuestionsDto, *errors.AppError) {
+	mappingInfo, appErr := impl.LoadMappingSvc(ctx, siteCode, dao.LatestMappingVersion)
+	if appErr != nil {
+		return nil, appErr
+	}
+	vocab, err := impl.vocabularies.For(siteCode)
+	if err != nil {
+		impl.lgr.Warnw("Falling back to the default attribute vocabulary", "siteCode", siteCode, "error", err)
+	}
+	catalogue, appErr := impl.loadCatalogue(ctx, mappingInfo, vocab)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	questions := eval.GenerateQuestions(catalogue, options)
+	if len(questions) == 0 {
+		return nil, errors.BadRequest(fmt.Sprintf("no questions can be generated from the catalogue of %s", siteCode))
+	}
+	generated := &dtos.GeneratedQuestionsDto{
+		SiteCode:       siteCode,
+		MappingVersion: mappingInfo.Version,
+		Count:          len(questions),
+		ByIntent:       make(map[string]int),
+		Questions:      questions,
+	}
+	for _, question := range questions {
+		generated.ByIntent[question.Intent]++
+	}
+	if save {
+		generated.Saved, appErr = impl.ImportGoldenSetSvc(ctx, siteCode, questions, false)
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+	return generated, nil
+}
@@ -4,29 +4,50 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
+	"time"
 	"github.com/homingos/flam-go-common/errors"
 	redisStorage "github.com/homingos/campaign-svc/lib/redis"
 	"go.uber.org/zap"
 	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/homingos/campaign-svc/lib/transaction"
 	"github.com/homingos/flam-go-common/authz"
 	"github.com/homingos/campaign-svc/lib/nats"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/campaign-svc/config"
+	"github.com/homingos/campaign-svc/lib/embedding"
 )
 
 type CategorySvcImpl struct {
-	lgr          *zap.SugaredLogger
-	redisClient  *redisStorage.RedisClient
-	MilvusClient client.Client
-	categoryDao  dao.CategoryDao
-	campaignDao  dao.CampaignDao
-	txManager    transaction.TransactionManager
-	fgaClient    *authz.OpenFGAClient
-	expDao       dao.ExperienceDao
-	templateDao  dao.TemplateDao
-	natsClient   *nats.Client
-	milvusDao    dao.MilvusDao
+	lgr            *zap.SugaredLogger
+	redisClient    *redisStorage.RedisClient
+	MilvusClient   client.Client
+	categoryDao    dao.CategoryDao
+	campaignDao    dao.CampaignDao
+	txManager      transaction.TransactionManager
+	fgaClient      *authz.OpenFGAClient
+	expDao         dao.ExperienceDao
+	templateDao    dao.TemplateDao
+	natsClient     *nats.Client
+	vectorStore    dao.VectorStore
+	evalRuns       *eval.RunStore
+	mappingStore   dao.MappingStore
+	searchConfig   config.SearchConfig
+	catalogues     *search.CatalogueCache
+	vocabularies   *search.VocabularyStore
+	intents        search.IntentClassifier
+	embedder       embedding.Provider
+	rerankers      map[string]search.Reranker
+	synonymDao     dao.SynonymDao
+	synonyms       *search.SynonymCache
+	calibrationDao dao.CalibrationDao
+	calibrations   *search.CalibrationCache
+	goldenSetDao   dao.GoldenSetDao
 }
 
 func NewCategorySvc(
@@ -40,64 +61,129 @@ func NewCategorySvc(
 	natsClient *nats.Client,
 	txManager transaction.TransactionManager,
 	fgaClient *authz.OpenFGAClient,
-	milvusDao dao.MilvusDao,
+	vectorStore dao.VectorStore,
+	evalRuns *eval.RunStore,
+	mappingStore dao.MappingStore,
+	searchConfig config.SearchConfig,
+	vocabularies *search.VocabularyStore,
+	intents search.IntentClassifier,
+	embedder embedding.Provider,
+	synonymDao dao.SynonymDao,
+	calibrationDao dao.CalibrationDao,
+	goldenSetDao dao.GoldenSetDao,
 ) *CategorySvcImpl {
 	return &CategorySvcImpl{
-		lgr:          lgr,
-		categoryDao:  categoryDao,
-		campaignDao:  campaignDao,
-		redisClient:  redisClient,
-		MilvusClient: milvusClient,
-		expDao:       expDao,
-		templateDao:  templateDao,
-		natsClient:   natsClient,
-		txManager:    txManager,
-		fgaClient:    fgaClient,
-		milvusDao:    milvusDao,
+		lgr:            lgr,
+		categoryDao:    categoryDao,
+		campaignDao:    campaignDao,
+		redisClient:    redisClient,
+		MilvusClient:   milvusClient,
+		expDao:         expDao,
+		templateDao:    templateDao,
+		natsClient:     natsClient,
+		txManager:      txManager,
+		fgaClient:      fgaClient,
+		vectorStore:    vectorStore,
+		evalRuns:       evalRuns,
+		mappingStore:   mappingStore,
+		searchConfig:   searchConfig,
+		catalogues:     search.NewCatalogueCache(time.Duration(searchConfig.CatalogueCacheTTLSeconds) * time.Second),
+		vocabularies:   vocabularies,
+		intents:        intents,
+		embedder:       embedder,
+		rerankers:      search.NewRerankers(searchConfig.Rerank),
+		synonymDao:     synonymDao,
+		synonyms:       search.NewSynonymCache(time.Duration(searchConfig.SynonymCacheTTLSeconds) * time.Second),
+		calibrationDao: calibrationDao,
+		calibrations:   search.NewCalibrationCache(time.Duration(searchConfig.CalibrationCacheTTLSeconds) * time.Second),
+		goldenSetDao:   goldenSetDao,
 	}
 }
 
-func (impl *CategorySvcImpl) GetCategoriesBySiteCodeSvc(ctx context.Context, siteCode string, text string) (interface{}, *errors.AppError) {
+func (impl *CategorySvcImpl) GetCategoriesBySiteCodeSvc(ctx context.Context, siteCode string, text string, params dtos.SearchParamsDto) (interface{}, *errors.AppError) {
 	data, err := impl.redisClient.GetCampaignExperiences(ctx, siteCode, false, true)
 	if err != nil {
 		return nil, errors.InternalServerError(err.Error())
 	}
 	var ShortCodes []string
+	var ranked []dtos.ResultItem
+	var intent string
+	var expandedQuery string
+	var noConfidentMatch bool
+	var page *dtos.PageDto
+	searched := false
 	if text != "" || data == nil {
-		//make embeding api call: returns float array
 		if text != "" {
-			embeddings, err := GetEmbeddings(text)
+			// route the query by intent when the site has a mapping
+			outcome, appErr := impl.SearchCampaignsSvc(ctx, siteCode, text, params)
+			if appErr == nil {
+				intent = outcome.Intent
+				expandedQuery = outcome.ExpandedQuery
+				noConfidentMatch = outcome.NoConfidentMatch
+				page = outcome.Page
+				ranked = outcome.Results
+				searched = true
+			} else if appErr.StatusCode != http.StatusNotFound {
+				return nil, appErr
+			}
+		}
+		// a site without a mapping falls back to a plain vector search
+		if text != "" && !searched {
+			window, appErr := impl.newSearchWindow(siteCode, params)
+			if appErr != nil {
+				return nil, appErr
+			}
+			expanded, applied := impl.siteSynonyms(ctx, siteCode).Expand(text)
+			if len(applied) > 0 {
+				expandedQuery = expanded
+			}
+			embeddings, err := impl.embed(ctx, expanded)
 			if err != nil {
 				return nil, errors.InternalServerError(err.Error())
 			}
-			milvusDocs, err := impl.milvusDao.Search(ctx, embeddings, siteCode)
+			milvusDocs, err := impl.vectorStore.Search(ctx, embeddings, siteCode, requestFilter(params), window.topK)
 			if err != nil {
 				return nil, errors.InternalServerError(err.Error())
 			}
 
-			var ids []string
-			for _, doc := range milvusDocs {
-				ids = append(ids, doc.ID)
+			// map hits to campaigns through campaign.milvus_ref_id, keeping rank and score
+			hits, err := impl.resolveVectorHits(ctx, milvusDocs, window.minScore, nil, nil)
+			if err != nil {
+				return nil, errors.InternalServerError(err.Error())
 			}
-
-			// filter campaigns shortcodes from campaigns collection using campaign.milvus_ref_id.
-			if len(ids) > 0 {
-				shortCodes, err := impl.campaignDao.GetShortcodesByMilvusRefID(ids) // todo: change here input
-				if err != nil {
-					return nil, errors.InternalServerError(err.Error())
-				}
-				ShortCodes = shortCodes
+			ranked, page = paginate(hits, window.offset, window.limit)
+			searched = true
+		}
+		if searched {
+			// a search that found nothing must not fall through to every category
+			ShortCodes = []string{}
+			for _, result := range ranked {
+				ShortCodes = append(ShortCodes, result.Code)
 			}
-
 		}
 
 		categoryData, err := impl.categoryDao.GetCategoriesBySiteCodeDao(ctx, siteCode, ShortCodes, text)
 		if err != nil {
 			return nil, errors.InternalServerError(err.Error())
 		}
+		if categoryData == nil && text != "" {
+			// a query that matches nothing is an empty result
+			categoryData = &dtos.CategorySearchResponseDto{
+				SiteCode:        siteCode,
+				Categories:      []dtos.CategoriesSearchResponseDto{},
+				OrderButtonText: "Order",
+			}
+		}
 		if categoryData == nil {
 			return nil, errors.BadRequest(fmt.Sprintf("No categories found for this site code: %s", siteCode))
 		}
+		if searchData, ok := categoryData.(*dtos.CategorySearchResponseDto); ok {
+			searchData.Intent = intent
+			searchData.ExpandedQuery = expandedQuery
+			searchData.NoConfidentMatch = noConfidentMatch
+			searchData.Page = page
+			rankCategories(searchData, ranked)
+		}
 		data = categoryData
 		barr, err := json.Marshal(data)
 		if err != nil {
@@ -117,6 +203,41 @@ func (impl *CategorySvcImpl) GetCategoriesBySiteCodeSvc(ctx context.Context, sit
 	return data, nil
 }
 
+// rankCategories orders a search response by relevance: campaigns by their
+// rank, categories by their best ranked campaign. Results keeps the ranked
+// campaigns the response still holds, with their scores.
+func rankCategories(searchData *dtos.CategorySearchResponseDto, ranked []dtos.ResultItem) {
+	rank := make(map[string]int, len(ranked))
+	for i, result := range ranked {
+		rank[result.Code] = i
+	}
+	present := make(map[string]bool)
+	for _, category := range searchData.Categories {
+		sort.SliceStable(category.Campaigns, func(i, j int) bool {
+			return rank[category.Campaigns[i]] < rank[category.Campaigns[j]]
+		})
+		for _, shortCode := range category.Campaigns {
+			present[shortCode] = true
+		}
+	}
+	bestRank := func(category dtos.CategoriesSearchResponseDto) int {
+		if len(category.Campaigns) == 0 {
+			return len(ranked)
+		}
+		return rank[category.Campaigns[0]]
+	}
+	sort.SliceStable(searchData.Categories, func(i, j int) bool {
+		return bestRank(searchData.Categories[i]) < bestRank(searchData.Categories[j])
+	})
+
+	searchData.Results = []dtos.ResultItem{}
+	for _, result := range ranked {
+		if present[result.Code] {
+			searchData.Results = append(searchData.Results, result)
+		}
+	}
+}
+
 func (impl *CategorySvcImpl) InvalidateCategorySvc(ctx context.Context, siteCode string) (string, *errors.AppError) {
 	data, err := impl.redisClient.GetCampaignExperiences(ctx, siteCode, false, true)
 	if err != nil {
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/flam-go-common/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Changes recorded on golden set versions.
+const (
+	goldenChangeCreate = "create"
+	goldenChangeUpdate = "update"
+	goldenChangeDelete = "delete"
+	goldenChangeImport = "import"
+)
+
+// goldenSaveAttempts bounds how often a change is replayed on a newer
+// version when another writer saved first.
+const goldenSaveAttempts = 3
+
+// goldenEdit changes the questions of the latest golden set and returns
+// them with the question it created or updated, if any.
+type goldenEdit func(questions []models.GoldenQuestion) ([]models.GoldenQuestion, *models.GoldenQuestion, *errors.AppError)
+
+// GetGoldenSetVersionsSvc lists the versions of a site code's golden set,
+// newest first.
+func (impl *CategorySvcImpl) GetGoldenSetVersionsSvc(ctx context.Context, siteCode string) ([]dtos.GoldenSetVersionDto, *errors.AppError) {
+	versions, err := impl.goldenSetDao.GetGoldenSetVersionsDao(ctx, siteCode)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load golden set versions: " + err.Error())
+	}
+	return versions, nil
+}
+
+// GetGoldenSetSvc returns a version of a site code's golden set, or the
+// latest one.
+func (impl *CategorySvcImpl) GetGoldenSetSvc(ctx context.Context, siteCode string, version int) (*models.GoldenSet, *errors.AppError) {
+	set, err := impl.goldenSetDao.GetGoldenSetDao(ctx, siteCode, version)
+	if err == dao.ErrGoldenSetNotFound {
+		message := "No golden set for site code: " + siteCode
+		if version != dao.LatestGoldenSetVersion {
+			message = fmt.Sprintf("Golden set version %d not found for site code: %s", version, siteCode)
+		}
+		return nil, &errors.AppError{StatusCode: http.StatusNotFound, Message: message}
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load golden set: " + err.Error())
+	}
+	return set, nil
+}
+
+// GoldenQuestionsSvc returns the questions of a golden set version to run
+// an evaluation with, and the version they came from.
+func (impl *CategorySvcImpl) GoldenQuestionsSvc(ctx context.Context, siteCode string, version int) ([]eval.LabeledQuestion, int, *errors.AppError) {
+	set, appErr := impl.GetGoldenSetSvc(ctx, siteCode, version)
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+	return eval.GoldenQuestions(set), set.Version, nil
+}
+
+// CreateGoldenQuestionSvc adds a question to a site code's golden set.
+func (impl *CategorySvcImpl) CreateGoldenQuestionSvc(ctx context.Context, siteCode string, questionDto dtos.GoldenQuestionDto) (*dtos.GoldenSetChangeDto, *errors.AppError) {
+	question, appErr := validateGoldenQuestion(questionDto)
+	if appErr != nil {
+		return nil, appErr
+	}
+	question.ID = primitive.NewObjectID().Hex()
+	return impl.changeGoldenSet(ctx, siteCode, goldenChangeCreate, func(questions []models.GoldenQuestion) ([]models.GoldenQuestion, *models.GoldenQuestion, *errors.AppError) {
+		return append(questions, question), &question, nil
+	})
+}
+
+// UpdateGoldenQuestionSvc replaces a question of a site code's golden set,
+// keeping its id.
+func (impl *CategorySvcImpl) UpdateGoldenQuestionSvc(ctx context.Context, siteCode string, id string, questionDto dtos.GoldenQuestionDto) (*dtos.GoldenSetChangeDto, *errors.AppError) {
+	question, appErr := validateGoldenQuestion(questionDto)
+	if appErr != nil {
+		return nil, appErr
+	}
+	question.ID = id
+	return impl.changeGoldenSet(ctx, siteCode, goldenChangeUpdate, func(questions []models.GoldenQuestion) ([]models.GoldenQuestion, *models.GoldenQuestion, *errors.AppError) {
+		for i := range questions {
+			if questions[i].ID == id {
+				questions[i] = question
+				return questions, &question, nil
+			}
+		}
+		return nil, nil, goldenQuestionNotFound(siteCode, id)
+	})
+}
+
+// DeleteGoldenQuestionSvc removes a question from a site code's golden set.
+func (impl *CategorySvcImpl) DeleteGoldenQuestionSvc(ctx context.Context, siteCode string, id string) (*dtos.GoldenSetChangeDto, *errors.AppError) {
+	return impl.changeGoldenSet(ctx, siteCode, goldenChangeDelete, func(questions []models.GoldenQuestion) ([]models.GoldenQuestion, *models.GoldenQuestion, *errors.AppError) {
+		for i := range questions {
+			if questions[i].ID == id {
+				return append(questions[:i], questions[i+1:]...), nil, nil
+			}
+		}
+		return nil, nil, goldenQuestionNotFound(siteCode, id)
+	})
+}
+
+// ImportGoldenSetSvc saves imported questions as the next version of a
+// site code's golden set. With replace the import is the whole set;
+// otherwise questions whose id is already in the set replace it and the
+// others are added.
+func (impl *CategorySvcImpl) ImportGoldenSetSvc(ctx context.Context, siteCode string, questionDtos []dtos.GoldenQuestionDto, replace bool) (*dtos.GoldenSetChangeDto, *errors.AppError) {
+	if len(questionDtos) == 0 {
+		return nil, errors.BadRequest("no golden questions to import")
+	}
+	imported := make([]models.GoldenQuestion, 0, len(questionDtos))
+	seen := make(map[string]bool, len(questionDtos))
+	for i, questionDto := range questionDtos {
+		question, appErr := validateGoldenQuestion(questionDto)
+		if appErr != nil {
+			return nil, errors.BadRequest(fmt.Sprintf("question %d: %s", i+1, appErr.Message))
+		}
+		question.ID = strings.TrimSpace(questionDto.ID)
+		if question.ID == "" {
+			question.ID = primitive.NewObjectID().Hex()
+		}
+		if seen[question.ID] {
+			return nil, errors.BadRequest(fmt.Sprintf("question %d: id %s is imported twice", i+1, question.ID))
+		}
+		seen[question.ID] = true
+		imported = append(imported, question)
+	}
+
+	return impl.changeGoldenSet(ctx, siteCode, goldenChangeImport, func(questions []models.GoldenQuestion) ([]models.GoldenQuestion, *models.GoldenQuestion, *errors.AppError) {
+		if replace {
+			return imported, nil, nil
+		}
+		at := make(map[string]int, len(questions))
+		for i, question := range questions {
+			at[question.ID] = i
+		}
+		for _, question := range imported {
+			if i, ok := at[question.ID]; ok {
+				questions[i] = question
+				continue
+			}
+			questions = append(questions, question)
+		}
+		return questions, nil, nil
+	})
+}
+
+// changeGoldenSet applies edit to the latest golden set of a site code,
+// resolves every expected product against the latest mapping and saves
+// the result as the next version. A version saved meanwhile by another
+// writer replays the edit on it.
+func (impl *CategorySvcImpl) changeGoldenSet(ctx context.Context, siteCode string, change string, edit goldenEdit) (*dtos.GoldenSetChangeDto, *errors.AppError) {
+	resolver, appErr := impl.newGoldenResolver(ctx, siteCode)
+	if appErr != nil {
+		return nil, appErr
+	}
+	for attempt := 0; attempt < goldenSaveAttempts; attempt++ {
+		var current []models.GoldenQuestion
+		version := 1
+		latest, err := impl.goldenSetDao.GetGoldenSetDao(ctx, siteCode, dao.LatestGoldenSetVersion)
+		if err == nil {
+			current = append(current, latest.Questions...)
+			version = latest.Version + 1
+		} else if err != dao.ErrGoldenSetNotFound {
+			return nil, errors.InternalServerError("Failed to load golden set: " + err.Error())
+		}
+
+		questions, changed, appErr := edit(current)
+		if appErr != nil {
+			return nil, appErr
+		}
+		for i := range questions {
+			if appErr := resolver.resolve(&questions[i]); appErr != nil {
+				return nil, appErr
+			}
+		}
+		if changed != nil {
+			resolver.resolve(changed)
+		}
+		if questions == nil {
+			questions = []models.GoldenQuestion{}
+		}
+		set := &models.GoldenSet{
+			SiteCode:       siteCode,
+			Version:        version,
+			MappingVersion: resolver.mappingVersion,
+			Change:         change,
+			Questions:      questions,
+			Count:          len(questions),
+			CreatedAt:      time.Now().UTC(),
+		}
+		err = impl.goldenSetDao.CreateGoldenSetDao(ctx, set)
+		if err == dao.ErrGoldenSetConflict {
+			continue
+		}
+		if err != nil {
+			return nil, errors.InternalServerError("Failed to save golden set: " + err.Error())
+		}
+		return &dtos.GoldenSetChangeDto{
+			GoldenSetVersionDto: dtos.GoldenSetVersionDto{
+				SiteCode:       set.SiteCode,
+				Version:        set.Version,
+				MappingVersion: set.MappingVersion,
+				Change:         set.Change,
+				Count:          set.Count,
+				CreatedAt:      set.CreatedAt,
+			},
+			Question: changed,
+		}, nil
+	}
+	return nil, &errors.AppError{StatusCode: http.StatusConflict, Message: "Golden set of site code " + siteCode + " is being changed concurrently, retry"}
+}
+
+// goldenResolver resolves expected product names to the short codes of
+// one mapping version.
+type goldenResolver struct {
+	mappingVersion int
+	resolver       *eval.NameResolver
+	names          map[string]string
+}
+
+// newGoldenResolver resolves against the latest mapping of a site code; a
+// site without a mapping leaves every product unresolved.
+func (impl *CategorySvcImpl) newGoldenResolver(ctx context.Context, siteCode string) (*goldenResolver, *errors.AppError) {
+	resolver := &goldenResolver{resolver: eval.NewNameResolver(nil), names: make(map[string]string)}
+	mapping, err := impl.mappingStore.Get(ctx, siteCode, dao.LatestMappingVersion)
+	if err == dao.ErrMappingNotFound {
+		return resolver, nil
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load mapping: " + err.Error())
+	}
+	resolver.mappingVersion = mapping.Version
+	resolver.resolver = eval.NewNameResolver(mapping)
+	for _, m := range mapping.Mappings {
+		resolver.names[m.ShortCode] = m.Name
+	}
+	return resolver, nil
+}
+
+// resolve sets the short code of every expected product of a question. A
+// short code that is mapped under the product's name is kept, so a name
+// shared by several campaigns can be pinned; otherwise the name resolves,
+// fuzzily if need be, when exactly one campaign carries the mapped name it
+// resolves to. Products given by short code only
+// take their name from the mapping and fail when it does not have them.
+func (r *goldenResolver) resolve(question *models.GoldenQuestion) *errors.AppError {
+	for i := range question.Expected {
+		expected := &question.Expected[i]
+		if name, ok := r.names[expected.ShortCode]; ok {
+			if expected.Name == "" {
+				expected.Name = name
+			}
+			if eval.CanonicalName(name) == eval.CanonicalName(expected.Name) {
+				continue
+			}
+		}
+		if expected.Name == "" {
+			return errors.BadRequest(fmt.Sprintf("short code %s is not in the latest mapping", expected.ShortCode))
+		}
+		expected.ShortCode = ""
+		if resolution := r.resolver.Resolve(expected.Name); resolution.Resolved() && len(resolution.ShortCodes) == 1 {
+			expected.ShortCode = resolution.ShortCodes[0]
+		}
+	}
+	return nil
+}
+
+// validateGoldenQuestion trims a question and checks it can be scored: it
+// has text and expected products graded within range, each named or
+// given by short code.
+func validateGoldenQuestion(questionDto dtos.GoldenQuestionDto) (models.GoldenQuestion, *errors.AppError) {
+	question := models.GoldenQuestion{
+		Text:     strings.TrimSpace(questionDto.Text),
+		Expected: make([]models.GoldenExpected, 0, len(questionDto.Expected)),
+		Intent:   eval.NormalizeIntent(questionDto.Intent),
+		Tags:     []string{},
+	}
+	if question.Text == "" {
+		return question, errors.BadRequest("a golden question needs text")
+	}
+	if question.Intent == "" {
+		question.Intent = eval.IntentUnknown
+	}
+	for _, expectedDto := range questionDto.Expected {
+		expected := models.GoldenExpected{
+			Name:      strings.TrimSpace(expectedDto.Name),
+			ShortCode: strings.TrimSpace(expectedDto.ShortCode),
+			Relevance: expectedDto.Relevance,
+		}
+		if expected.Name == "" && expected.ShortCode == "" {
+			return question, errors.BadRequest("an expected product needs a name or a short code")
+		}
+		if expected.Relevance == 0 {
+			expected.Relevance = eval.MinRelevance
+		}
+		if expected.Relevance < eval.MinRelevance || expected.Relevance > eval.MaxRelevance {
+			return question, errors.BadRequest(fmt.Sprintf("relevance must be between %d and %d", eval.MinRelevance, eval.MaxRelevance))
+		}
+		question.Expected = append(question.Expected, expected)
+	}
+	if len(question.Expected) == 0 {
+		return question, errors.BadRequest("a golden question needs at least one expected product")
+	}
+	seen := make(map[string]bool, len(questionDto.Tags))
+	for _, tag := range questionDto.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			question.Tags = append(question.Tags, tag)
+		}
+	}
+	return question, nil
+}
+
+func goldenQuestionNotFound(siteCode string, id string) *errors.AppError {
+	return &errors.AppError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("Golden question %s not found for site code: %s", id, siteCode)}
+}
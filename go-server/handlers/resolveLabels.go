@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/homingos/campaign-svc/lib/eval"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// ResolveLabelsSvc resolves the expected products of labeled questions
+// against a mapping version of a site code without searching, so labels
+// that will never be hit are reported before an evaluation runs.
+func (impl *CategorySvcImpl) ResolveLabelsSvc(ctx context.Context, siteCode string, questions []eval.LabeledQuestion, mappingVersion int) (*eval.LabelReport, *errors.AppError) {
+	if len(questions) == 0 {
+		return nil, errors.BadRequest("no labeled questions to resolve")
+	}
+	mapping, appErr := impl.LoadMappingSvc(ctx, siteCode, mappingVersion)
+	if appErr != nil {
+		return nil, appErr
+	}
+	_, report := eval.ResolveLabels(questions, eval.NewNameResolver(mapping), mapping.Version)
+	return report, nil
+}
+
+// resolveRunLabels renames the expected products of labeled questions to
+// the names of a run's mapping, so labels that differ from them in case,
+// punctuation or a typo are still hit.
+func (impl *CategorySvcImpl) resolveRunLabels(manifest *eval.RunManifest, questions []eval.LabeledQuestion) ([]eval.LabeledQuestion, *eval.LabelReport) {
+	resolved, report := eval.ResolveLabels(questions, eval.NewNameResolver(manifest.Mapping), manifest.Config.MappingVersion)
+	if !report.Clean() {
+		impl.lgr.Warnw("Some expected products do not resolve to a mapped name",
+			"runID", manifest.RunID, "ambiguous", len(report.Ambiguous), "unresolved", len(report.Unresolved))
+	}
+	return resolved, report
+}
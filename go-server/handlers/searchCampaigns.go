@@ -0,0 +1,747 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// searchWindow bounds the results of a query: topK results are kept,
+// vector matches below minScore and calibrated results below minConfidence
+// are dropped, offset and limit page through the rest.
+type searchWindow struct {
+	topK          int
+	minScore      float32
+	minConfidence float64
+	offset        int
+	limit         int
+}
+
+// searchScope is everything resolved once per request and shared by all of
+// its queries. The catalogue is loaded on first use.
+type searchScope struct {
+	siteCode string
+	mapping  *models.MappingData
+	names    map[string]string
+	mode     string
+	intent   string
+	vocab    *search.Vocabulary
+	reranker search.Reranker
+	explain  bool
+	synonyms *search.SynonymDictionary
+	// filter is the request's narrowing of retrieval
+	filter dtos.VectorFilterDto
+	// calibrator turns the scores of scoreModel into confidences, nil until
+	// the site's scores for it are calibrated
+	scoreModel string
+	calibrator search.Calibrator
+	searchWindow
+
+	catalogueOnce sync.Once
+	catalogue     *search.Catalogue
+	catalogueErr  *errors.AppError
+}
+
+// SearchCampaignsSvc resolves a free text query to ranked short codes for a
+// site code using the latest mapping, or the pinned mapping version. An
+// empty query returns every mapped campaign, paged by offset and limit.
+func (impl *CategorySvcImpl) SearchCampaignsSvc(ctx context.Context, siteCode string, text string, params dtos.SearchParamsDto) (*dtos.SearchResultDto, *errors.AppError) {
+	scope, appErr := impl.newSearchScope(ctx, siteCode, params)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	outcome := &dtos.SearchResultDto{Results: []dtos.ResultItem{}}
+	if text == "" {
+		for _, m := range scope.mapping.Mappings {
+			outcome.Results = append(outcome.Results, dtos.ResultItem{
+				Code:  m.ShortCode,
+				Name:  m.Name,
+				Score: 0,
+			})
+		}
+		outcome.Results, outcome.Page = paginate(outcome.Results, scope.offset, scope.limit)
+		return outcome, nil
+	}
+	if appErr := impl.searchWithScope(ctx, scope, text, outcome); appErr != nil {
+		return nil, appErr
+	}
+	return outcome, nil
+}
+
+// SearchCampaignsBatchSvc runs many queries against one site code. Queries
+// are embedded and searched by a bounded pool of workers; a failing query
+// reports its error without failing the batch.
+func (impl *CategorySvcImpl) SearchCampaignsBatchSvc(ctx context.Context, siteCode string, req *dtos.BatchSearchRequestDto) (*dtos.BatchSearchResponseDto, *errors.AppError) {
+	if len(req.Queries) > consts.MaxBatchQueries {
+		return nil, errors.BadRequest("too many queries in one batch")
+	}
+	return impl.searchBatch(ctx, siteCode, req)
+}
+
+// searchBatch runs a batch of any size, MaxBatchQueries queries at a time,
+// for evaluations and calibrations whose question sets exceed what one
+// request may send. A query whose results cannot be recorded into the run
+// reports that as its error.
+func (impl *CategorySvcImpl) searchBatch(ctx context.Context, siteCode string, req *dtos.BatchSearchRequestDto) (*dtos.BatchSearchResponseDto, *errors.AppError) {
+	if len(req.Queries) == 0 {
+		return nil, errors.BadRequest("queries must not be empty")
+	}
+	params := req.SearchParamsDto
+	// queries recorded into a run use the mapping and mode the run was created with
+	if req.RunID != "" {
+		run, appErr := impl.GetEvalRunSvc(req.RunID)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if params.MappingVersion == dao.LatestMappingVersion {
+			params.MappingVersion = run.Manifest.Config.MappingVersion
+		}
+		if params.Mode == "" {
+			params.Mode = run.Manifest.Config.Mode
+		}
+		if params.Reranker == "" {
+			params.Reranker = run.Manifest.Config.Reranker
+		}
+	}
+	scope, appErr := impl.newSearchScope(ctx, siteCode, params)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = consts.DefaultBatchConcurrency
+	}
+	if concurrency > consts.MaxBatchConcurrency {
+		concurrency = consts.MaxBatchConcurrency
+	}
+
+	// repeated queries are searched once
+	var queries []string
+	seen := make(map[string]bool)
+	for _, query := range req.Queries {
+		if query == "" || seen[query] {
+			continue
+		}
+		seen[query] = true
+		queries = append(queries, query)
+	}
+
+	start := time.Now()
+	outcomes := make([]dtos.SearchResultDto, len(queries))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for chunkStart := 0; chunkStart < len(queries); chunkStart += consts.MaxBatchQueries {
+		chunkEnd := chunkStart + consts.MaxBatchQueries
+		if chunkEnd > len(queries) {
+			chunkEnd = len(queries)
+		}
+		if scope.mode != search.ModeLexical {
+			impl.prefetchEmbeddings(ctx, scope, queries[chunkStart:chunkEnd])
+		}
+		for i := chunkStart; i < chunkEnd; i++ {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, query string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				var outcome dtos.SearchResultDto
+				queryStart := time.Now()
+				appErr := impl.searchWithScope(ctx, scope, query, &outcome)
+				outcome.Timings.TotalMs = elapsedMs(queryStart)
+				if appErr != nil {
+					outcome.Error = appErr.Message
+				}
+				if outcome.Results == nil {
+					outcome.Results = []dtos.ResultItem{}
+				}
+				outcomes[i] = outcome
+			}(i, queries[i])
+		}
+		wg.Wait()
+	}
+
+	response := &dtos.BatchSearchResponseDto{
+		SiteCode:       siteCode,
+		MappingVersion: scope.mapping.Version,
+		Mode:           scope.mode,
+		Results:        make(map[string]dtos.SearchResultDto, len(queries)),
+	}
+	for i, query := range queries {
+		if outcomes[i].Error == "" && req.RunID != "" {
+			if appErr := impl.RecordEvalRunSvc(req.RunID, siteCode, query, outcomes[i].Results); appErr != nil {
+				outcomes[i].Error = "recording into run " + req.RunID + ": " + appErr.Message
+			}
+		}
+		if outcomes[i].Error != "" {
+			response.Failed++
+		}
+		response.Results[query] = outcomes[i]
+	}
+	response.TotalMs = elapsedMs(start)
+	return response, nil
+}
+
+// prefetchEmbeddings embeds the queries of a batch in one provider call so
+// the searches that follow find them cached. Queries that fail here are
+// embedded again by their own search.
+func (impl *CategorySvcImpl) prefetchEmbeddings(ctx context.Context, scope *searchScope, queries []string) {
+	texts := make([]string, 0, len(queries))
+	for _, query := range queries {
+		if _, text := search.ParsePriceConstraint(query); text != "" {
+			expanded, _ := scope.synonyms.Expand(text)
+			texts = append(texts, expanded)
+		}
+	}
+	if len(texts) == 0 {
+		return
+	}
+	if _, err := impl.embedder.EmbedBatch(ctx, texts); err != nil {
+		impl.lgr.Warnw("Failed to prefetch batch embeddings", "queries", len(texts), "error", err)
+	}
+}
+
+// newSearchScope loads the mapping and validates the retrieval mode, the
+// requested intent, if any, and the result window.
+func (impl *CategorySvcImpl) newSearchScope(ctx context.Context, siteCode string, params dtos.SearchParamsDto) (*searchScope, *errors.AppError) {
+	mode, err := search.ParseMode(params.Mode, impl.searchConfig.DefaultMode)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+	intent, err := search.ParseIntent(params.Intent)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+	window, appErr := impl.newSearchWindow(siteCode, params)
+	if appErr != nil {
+		return nil, appErr
+	}
+	filter := requestFilter(params)
+	if mode == search.ModeLexical && vectorOnly(filter) {
+		return nil, errors.BadRequest("client_id and product_ids filter the vector search, use mode vector or hybrid")
+	}
+	reranker, appErr := impl.resolveReranker(params.Reranker)
+	if appErr != nil {
+		return nil, appErr
+	}
+	mappingInfo, appErr := impl.LoadMappingSvc(ctx, siteCode, params.MappingVersion)
+	if appErr != nil {
+		return nil, appErr
+	}
+	vocab, err := impl.vocabularies.For(siteCode)
+	if err != nil {
+		impl.lgr.Warnw("Falling back to the default attribute vocabulary", "siteCode", siteCode, "error", err)
+	}
+	scoreModel := search.ScoreModel(impl.embeddingModel(), mode, reranker.Name())
+	return &searchScope{
+		siteCode: siteCode,
+		mapping:  mappingInfo,
+		names:    shortCodeNames(mappingInfo),
+		mode:     mode,
+		intent:   intent,
+		vocab:    vocab,
+		reranker: reranker,
+		explain:  params.Explain,
+		synonyms: impl.siteSynonyms(ctx, siteCode),
+		filter:   filter,
+
+		scoreModel: scoreModel,
+		calibrator: impl.siteCalibrator(ctx, siteCode, scoreModel),
+
+		searchWindow: window,
+	}, nil
+}
+
+// requestFilter is the vector filter a request asks for.
+func requestFilter(params dtos.SearchParamsDto) dtos.VectorFilterDto {
+	return dtos.VectorFilterDto{
+		ClientID:   params.ClientID,
+		Categories: params.Categories,
+		ProductIDs: params.ProductIDs,
+	}
+}
+
+// vectorOnly reports whether a filter needs the vector search: client and
+// product ids are only known to the vector store.
+func vectorOnly(filter dtos.VectorFilterDto) bool {
+	return filter.ClientID != "" || len(filter.ProductIDs) > 0
+}
+
+// retrievalFilter narrows the retrieval of a query: the request's filter,
+// the categories a Filter query names when the request sets none, and the
+// query's price range.
+func retrievalFilter(scope *searchScope, classification search.Classification, query search.RerankQuery) dtos.VectorFilterDto {
+	filter := scope.filter
+	if len(filter.Categories) == 0 && classification.Intent == search.IntentFilter {
+		filter.Categories = classification.Categories
+	}
+	if query.PriceConstraint != nil {
+		filter.MinPrice = query.PriceConstraint.Min
+		filter.MaxPrice = query.PriceConstraint.Max
+	}
+	return filter
+}
+
+// resolveReranker returns the reranker of a name, or the configured one.
+func (impl *CategorySvcImpl) resolveReranker(name string) (search.Reranker, *errors.AppError) {
+	if name == "" {
+		name = impl.searchConfig.Rerank.Default
+	}
+	reranker, ok := impl.rerankers[name]
+	if !ok {
+		return nil, errors.BadRequest(fmt.Sprintf("unknown reranker %q, use %s, %s or %s", name, search.RerankerNone, search.RerankerLinear, search.RerankerCrossEncoder))
+	}
+	return reranker, nil
+}
+
+// newSearchWindow validates the requested result window and fills what it
+// leaves out from the site's search defaults.
+func (impl *CategorySvcImpl) newSearchWindow(siteCode string, params dtos.SearchParamsDto) (searchWindow, *errors.AppError) {
+	if params.TopK < 0 || params.TopK > consts.MaxTopK {
+		return searchWindow{}, errors.BadRequest(fmt.Sprintf("top_k must be between 1 and %d", consts.MaxTopK))
+	}
+	if params.Limit < 0 || params.Limit > consts.MaxTopK {
+		return searchWindow{}, errors.BadRequest(fmt.Sprintf("limit must be between 1 and %d", consts.MaxTopK))
+	}
+	if params.Offset < 0 {
+		return searchWindow{}, errors.BadRequest("offset must not be negative")
+	}
+	if params.MinConfidence != nil && (*params.MinConfidence < 0 || *params.MinConfidence > 1) {
+		return searchWindow{}, errors.BadRequest("min_confidence must be between 0 and 1")
+	}
+
+	defaults := impl.searchConfig.DefaultsFor(siteCode)
+	window := searchWindow{topK: defaults.TopK, offset: params.Offset, limit: defaults.Limit}
+	if params.TopK > 0 {
+		window.topK = params.TopK
+	}
+	if defaults.MinScore != nil {
+		window.minScore = float32(*defaults.MinScore)
+	}
+	if params.MinScore != nil {
+		window.minScore = float32(*params.MinScore)
+	}
+	if defaults.MinConfidence != nil {
+		window.minConfidence = *defaults.MinConfidence
+	}
+	if params.MinConfidence != nil {
+		window.minConfidence = *params.MinConfidence
+	}
+	if params.Limit > 0 {
+		window.limit = params.Limit
+	}
+	return window, nil
+}
+
+// scopeCatalogue returns the catalogue of the scope's mapping version,
+// loading it once per scope however many queries share it.
+func (impl *CategorySvcImpl) scopeCatalogue(ctx context.Context, scope *searchScope) (*search.Catalogue, *errors.AppError) {
+	scope.catalogueOnce.Do(func() {
+		scope.catalogue, scope.catalogueErr = impl.loadCatalogue(ctx, scope.mapping, scope.vocab)
+	})
+	return scope.catalogue, scope.catalogueErr
+}
+
+// loadCatalogue returns the catalogue of a mapping version.
+func (impl *CategorySvcImpl) loadCatalogue(ctx context.Context, mappingInfo *models.MappingData, vocab *search.Vocabulary) (*search.Catalogue, *errors.AppError) {
+	if catalogue, ok := impl.catalogues.Get(mappingInfo.SiteCode, mappingInfo.Version); ok {
+		return catalogue, nil
+	}
+	products, err := impl.categoryDao.GetCatalogueProductsBySiteCodeDao(ctx, mappingInfo.SiteCode)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load catalogue products: " + err.Error())
+	}
+	catalogue := search.NewCatalogue(mappingInfo, products, vocab)
+	impl.catalogues.Put(mappingInfo.SiteCode, mappingInfo.Version, catalogue)
+	return catalogue, nil
+}
+
+// searchWithScope answers one query: a price constraint and attributes are
+// parsed out of the text, the query is classified and, expanded with the
+// site's synonyms, routed to the strategy of its intent, then candidates
+// outside the price range are dropped and attributes filter or boost the
+// rest before the top results are kept. Calibrated retrieval results get
+// their confidence and those below the cutoff are dropped before paging;
+// exact names and category browsing are never cut by confidence. An
+// explained query records every stage and every candidate dropped.
+func (impl *CategorySvcImpl) searchWithScope(ctx context.Context, scope *searchScope, text string, outcome *dtos.SearchResultDto) *errors.AppError {
+	if scope.explain {
+		outcome.Explain = &dtos.SearchExplainDto{Results: []dtos.ResultItem{}, Dropped: []dtos.DroppedCandidateDto{}}
+	}
+	constraint, queryText := search.ParsePriceConstraint(text)
+	outcome.PriceConstraint = constraint
+	queryAttributes := scope.vocab.Extract(queryText)
+	if len(queryAttributes) > 0 {
+		outcome.Attributes = scope.vocab.Applied(queryAttributes)
+	}
+
+	catalogue, appErr := impl.scopeCatalogue(ctx, scope)
+	if appErr != nil {
+		return appErr
+	}
+	classification := impl.classifyQuery(scope, catalogue, search.IntentQuery{
+		Text:            queryText,
+		PriceConstraint: constraint,
+		Attributes:      queryAttributes,
+	})
+	outcome.Intent = classification.Intent
+	outcome.IntentReason = classification.Reason
+
+	// synonyms widen retrieval only; the query is classified as written
+	expandedText, applied := scope.synonyms.Expand(queryText)
+	if len(applied) > 0 {
+		outcome.ExpandedQuery = expandedText
+		outcome.Synonyms = applied
+	}
+	explainQuery(outcome, text, queryText, expandedText)
+
+	rerankQuery := search.RerankQuery{
+		Text:            expandedText,
+		PriceConstraint: constraint,
+		Categories:      classification.Categories,
+	}
+	candidates, limit, appErr := impl.routeIntent(ctx, scope, catalogue, classification, rerankQuery, outcome)
+	if appErr != nil {
+		return appErr
+	}
+	if outcome.Explain != nil {
+		outcome.Explain.Route.Limit = limit
+	}
+
+	if constraint != nil {
+		before := snapshot(outcome, candidates)
+		filtered := candidates[:0]
+		for _, candidate := range candidates {
+			if product, ok := catalogue.Products[candidate.Code]; ok && search.PriceMatches(constraint, product) {
+				filtered = append(filtered, candidate)
+			}
+		}
+		candidates = filtered
+		explainDropped(outcome, stagePrice, dropPrice, before, candidates, func(rank int, item dtos.ResultItem) string {
+			if product, ok := catalogue.Products[item.Code]; ok && product.Price != "" {
+				return fmt.Sprintf("price %s %s is outside %s", product.Price, product.Currency, constraint.Text)
+			}
+			return "no catalogue price"
+		})
+	}
+	if len(queryAttributes) > 0 {
+		before := snapshot(outcome, candidates)
+		candidates = scope.vocab.ApplyAttributes(queryAttributes, candidates, catalogue.Attributes)
+		explainDropped(outcome, stageAttributes, dropAttributes, before, candidates, func(rank int, item dtos.ResultItem) string {
+			return fmt.Sprintf("attributes %v", catalogue.Attributes[item.Code])
+		})
+	}
+
+	if len(candidates) > limit {
+		explainDropped(outcome, stageTopK, dropBeyondLimit, candidates, candidates[:limit], func(rank int, item dtos.ResultItem) string {
+			return fmt.Sprintf("rank %d, the top %d are kept", rank, limit)
+		})
+		candidates = candidates[:limit]
+	}
+	if scope.calibrator != nil && calibratedStrategy(outcome.Strategy) {
+		before := candidates
+		candidates = applyConfidence(scope.calibrator, candidates, scope.minConfidence)
+		outcome.NoConfidentMatch = len(candidates) == 0
+		explainDropped(outcome, stageConfidence, dropBelowConfidence, before, candidates, func(rank int, item dtos.ResultItem) string {
+			return fmt.Sprintf("confidence %.3f below %.3f", scope.calibrator.Confidence(float64(item.Score)), scope.minConfidence)
+		})
+	}
+	outcome.Results, outcome.Page = paginate(candidates, scope.offset, scope.limit)
+	if outcome.Explain != nil {
+		explainDropped(outcome, stagePage, dropOutsidePage, candidates, outcome.Results, func(rank int, item dtos.ResultItem) string {
+			return fmt.Sprintf("rank %d, the page starts at offset %d with limit %d", rank, scope.offset, scope.limit)
+		})
+		impl.explainServing(ctx, scope, text, outcome)
+	}
+	return nil
+}
+
+// paginate returns the page of results starting at offset; a limit of 0
+// returns everything after it.
+func paginate(results []dtos.ResultItem, offset int, limit int) ([]dtos.ResultItem, *dtos.PageDto) {
+	page := &dtos.PageDto{Offset: offset, Limit: limit, Total: len(results)}
+	if offset >= len(results) {
+		return []dtos.ResultItem{}, page
+	}
+	results = results[offset:]
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, page
+}
+
+// retrieveRanked retrieves candidates and reorders them with the scope's
+// reranker. A failing reranker keeps the retrieval order.
+func (impl *CategorySvcImpl) retrieveRanked(ctx context.Context, scope *searchScope, catalogue *search.Catalogue, query search.RerankQuery, filter dtos.VectorFilterDto, outcome *dtos.SearchResultDto) ([]dtos.ResultItem, *errors.AppError) {
+	candidates, appErr := impl.retrieve(ctx, scope, query.Text, filter, outcome)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if scope.reranker.Name() == search.RerankerNone && !scope.explain {
+		return candidates, nil
+	}
+
+	stageStart := time.Now()
+	reranked, err := scope.reranker.Rerank(ctx, query, candidates, catalogue)
+	outcome.Timings.RerankMs = elapsedMs(stageStart)
+	if err != nil {
+		impl.lgr.Warnw("Reranking failed, keeping retrieval order", "reranker", scope.reranker.Name(), "error", err)
+		if scope.explain {
+			outcome.Rerank = &dtos.RerankExplainDto{Reranker: scope.reranker.Name(), Error: err.Error(), Candidates: []dtos.RerankedItemDto{}}
+		}
+		return candidates, nil
+	}
+
+	results := make([]dtos.ResultItem, 0, len(reranked))
+	for _, candidate := range reranked {
+		results = append(results, candidate.ResultItem)
+	}
+	if scope.explain {
+		explain := &dtos.RerankExplainDto{Reranker: scope.reranker.Name(), Candidates: make([]dtos.RerankedItemDto, 0, len(reranked))}
+		for i, candidate := range reranked {
+			explain.Candidates = append(explain.Candidates, dtos.RerankedItemDto{
+				Code:          candidate.Code,
+				Name:          candidate.Name,
+				OriginalRank:  candidate.OriginalRank,
+				OriginalScore: candidate.OriginalScore,
+				Rank:          i + 1,
+				Score:         candidate.Score,
+				Features:      candidate.Features,
+			})
+		}
+		outcome.Rerank = explain
+	}
+	return results, nil
+}
+
+// retrieve returns every candidate of the scope's mode in rank order. The
+// vector search is narrowed by filter; lexical hits and vector hits alike
+// are kept to its categories by their catalogue category, and a hybrid
+// search filtered by client or product ids keeps only what the vector
+// search returned.
+func (impl *CategorySvcImpl) retrieve(ctx context.Context, scope *searchScope, text string, filter dtos.VectorFilterDto, outcome *dtos.SearchResultDto) ([]dtos.ResultItem, *errors.AppError) {
+	timings := &outcome.Timings
+	catalogue, appErr := impl.scopeCatalogue(ctx, scope)
+	if appErr != nil {
+		return nil, appErr
+	}
+	var vectorResults []dtos.ResultItem
+	if scope.mode != search.ModeLexical {
+		vectorResults, appErr = impl.vectorSearch(ctx, scope, text, filter, outcome)
+		if appErr != nil {
+			return nil, appErr
+		}
+		vectorResults = inCategories(vectorResults, catalogue, filter.Categories)
+		if scope.mode == search.ModeVector {
+			return vectorResults, nil
+		}
+	}
+
+	stageStart := time.Now()
+	depth := impl.retrievalDepth(scope)
+	if depth < consts.LexicalCandidates {
+		depth = consts.LexicalCandidates
+	}
+	hits := catalogue.Index.Search(text, depth)
+	if len(filter.Categories) > 0 {
+		kept := hits[:0]
+		for _, hit := range hits {
+			if containsFold(filter.Categories, catalogue.CategoryOf(hit.ShortCode)) {
+				kept = append(kept, hit)
+			}
+		}
+		hits = kept
+	}
+	timings.LexicalMs = elapsedMs(stageStart)
+
+	if scope.mode == search.ModeLexical || outcome.Explain != nil {
+		results := make([]dtos.ResultItem, 0, len(hits))
+		for _, hit := range hits {
+			results = append(results, dtos.ResultItem{
+				Code:  hit.ShortCode,
+				Name:  scope.names[hit.ShortCode],
+				Score: float32(hit.Score),
+			})
+		}
+		if outcome.Explain != nil {
+			outcome.Explain.Lexical = results
+		}
+		if scope.mode == search.ModeLexical {
+			return results, nil
+		}
+	}
+
+	stageStart = time.Now()
+	vectorCodes := make([]string, 0, len(vectorResults))
+	vectorScores := make(map[string]*float32, len(vectorResults))
+	for _, result := range vectorResults {
+		vectorCodes = append(vectorCodes, result.Code)
+		vectorScores[result.Code] = result.VectorScore
+	}
+	lexicalCodes := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		lexicalCodes = append(lexicalCodes, hit.ShortCode)
+	}
+	fused := search.FuseRRF(impl.searchConfig.RRFK,
+		search.RankedList{Weight: impl.searchConfig.VectorWeight, ShortCodes: vectorCodes},
+		search.RankedList{Weight: impl.searchConfig.LexicalWeight, ShortCodes: lexicalCodes},
+	)
+	// lexical hits know no client or product id
+	keepVector := vectorOnly(filter)
+	results := make([]dtos.ResultItem, 0, len(fused))
+	for _, candidate := range fused {
+		vectorScore, retrieved := vectorScores[candidate.ShortCode]
+		if keepVector && !retrieved {
+			continue
+		}
+		results = append(results, dtos.ResultItem{
+			Code:        candidate.ShortCode,
+			Name:        scope.names[candidate.ShortCode],
+			Score:       float32(candidate.Score),
+			VectorScore: vectorScore,
+		})
+	}
+	timings.FusionMs = elapsedMs(stageStart)
+	if outcome.Explain != nil {
+		outcome.Explain.Fusion = append([]dtos.ResultItem(nil), results...)
+	}
+	return results, nil
+}
+
+func (impl *CategorySvcImpl) vectorSearch(ctx context.Context, scope *searchScope, text string, filter dtos.VectorFilterDto, outcome *dtos.SearchResultDto) ([]dtos.ResultItem, *errors.AppError) {
+	timings := &outcome.Timings
+	stageStart := time.Now()
+	embedded, err := impl.embedder.Embed(ctx, text)
+	timings.EmbeddingMs = elapsedMs(stageStart)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to get embeddings")
+	}
+	explainEmbedding(outcome, embedded, impl.embeddingModel())
+
+	stageStart = time.Now()
+	depth := impl.retrievalDepth(scope)
+	milvusDocs, err := impl.vectorStore.Search(ctx, embedded.Embedding, scope.siteCode, filter, depth)
+	timings.SearchMs = elapsedMs(stageStart)
+	if outcome.Explain != nil {
+		impl.explainVectorSearch(ctx, scope, depth, filter, milvusDocs, err, outcome.Explain)
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Milvus search failed")
+	}
+
+	// Map vector hits back to short codes and names
+	stageStart = time.Now()
+	results, err := impl.resolveVectorHits(ctx, milvusDocs, scope.minScore, scope.names, outcome.Explain)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to resolve vector hits: " + err.Error())
+	}
+	timings.ResolveMs = elapsedMs(stageStart)
+	return results, nil
+}
+
+// resolveVectorHits maps vector store hits scoring at least minScore to
+// the short codes of their active campaigns with one lookup, keeping rank
+// order and scores. Names come from the mapping when it has them. explain,
+// when set, gets how every hit resolved.
+func (impl *CategorySvcImpl) resolveVectorHits(ctx context.Context, docs []dtos.SearchResult, minScore float32, names map[string]string, explain *dtos.SearchExplainDto) ([]dtos.ResultItem, error) {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if doc.Score >= minScore {
+			ids = append(ids, doc.ID)
+		}
+	}
+	campaigns, err := impl.campaignDao.GetCampaignsByMilvusRefIDsDao(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []dtos.ResultItem{}
+	seen := make(map[string]bool, len(campaigns))
+	for _, doc := range docs {
+		campaign, ok := campaigns[doc.ID]
+		reason := ""
+		switch {
+		case doc.Score < minScore:
+			reason = dropBelowMinScore
+		case !ok:
+			reason = dropNoActiveCampaign
+		case seen[campaign.ShortCode]:
+			reason = dropDuplicate
+		}
+		explainHit(explain, doc, campaign, ok, reason, minScore, names)
+		if reason != "" {
+			continue
+		}
+		seen[campaign.ShortCode] = true
+		name := names[campaign.ShortCode]
+		if name == "" {
+			name = campaign.Name
+		}
+		vectorScore := doc.Score
+		results = append(results, dtos.ResultItem{
+			Code:        campaign.ShortCode,
+			Name:        name,
+			Score:       doc.Score,
+			VectorScore: &vectorScore,
+		})
+	}
+	return results, nil
+}
+
+// retrievalDepth is how many candidates to retrieve to keep topK results
+// once price and attribute filters have dropped theirs, and at least the
+// reranker's candidate pool when one is applied.
+func (impl *CategorySvcImpl) retrievalDepth(scope *searchScope) int {
+	depth := scope.topK * consts.RetrievalDepthFactor
+	if scope.reranker.Name() != search.RerankerNone && depth < impl.searchConfig.Rerank.Candidates {
+		depth = impl.searchConfig.Rerank.Candidates
+	}
+	return depth
+}
+
+// inCategories keeps the results whose catalogue category is one of
+// categories; no categories keeps them all.
+func inCategories(results []dtos.ResultItem, catalogue *search.Catalogue, categories []string) []dtos.ResultItem {
+	if len(categories) == 0 {
+		return results
+	}
+	kept := results[:0]
+	for _, result := range results {
+		if containsFold(categories, catalogue.CategoryOf(result.Code)) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func shortCodeNames(mappingInfo *models.MappingData) map[string]string {
+	shortCodeToName := make(map[string]string, len(mappingInfo.Mappings))
+	for _, mapping := range mappingInfo.Mappings {
+		shortCodeToName[mapping.ShortCode] = mapping.Name
+	}
+	return shortCodeToName
+}
+
+func elapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/campaign-svc/types/consts"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// classifyQuery returns the intent of a query, or the intent the caller
+// asked for.
+func (impl *CategorySvcImpl) classifyQuery(scope *searchScope, catalogue *search.Catalogue, query search.IntentQuery) search.Classification {
+	if scope.intent == "" {
+		return impl.intents.Classify(query, catalogue)
+	}
+	classification := search.Classification{Intent: scope.intent, Reason: "requested"}
+	switch scope.intent {
+	case search.IntentDirect:
+		classification.ShortCode, _ = catalogue.ShortCodeByName(query.Text)
+	case search.IntentBrowse:
+		classification.Categories = catalogue.MatchCategories(query.Text)
+	case search.IntentFilter:
+		classification.Categories = catalogue.NamedCategories(query.Text)
+	}
+	return classification
+}
+
+// routeIntent runs the retrieval strategy of an intent and returns its
+// candidates in rank order with how many of them to keep, top_k unless
+// the intent says otherwise:
+//
+//   - Direct: the one product the query names
+//   - Browse: every product of the categories asked for
+//   - Filter: reranked retrieval in the scope's mode, narrowed to the
+//     categories the query names
+//   - Discovery: reranked retrieval in the scope's mode, spread across
+//     categories
+//
+// Exact names, browsed categories and price only queries are kept to the
+// request's categories. Only the vector search knows client and product
+// ids, so requests filtered by them always go through retrieval.
+func (impl *CategorySvcImpl) routeIntent(ctx context.Context, scope *searchScope, catalogue *search.Catalogue, classification search.Classification, query search.RerankQuery, outcome *dtos.SearchResultDto) ([]dtos.ResultItem, int, *errors.AppError) {
+	text := query.Text
+	timings := &outcome.Timings
+	filter := retrievalFilter(scope, classification, query)
+	routed := !vectorOnly(filter)
+	switch classification.Intent {
+	case search.IntentDirect:
+		if routed && classification.ShortCode != "" && inScopeCategories(scope, catalogue, classification.ShortCode) {
+			// scored like a Browse match so it ranks on the lexical scale
+			explainRoute(outcome, classification, strategyExactName)
+			return []dtos.ResultItem{{
+				Code:  classification.ShortCode,
+				Name:  scope.names[classification.ShortCode],
+				Score: float32(catalogue.LexicalScore(text, classification.ShortCode)),
+			}}, 1, nil
+		}
+		// no exact name, the best retrieved product stands in
+		explainRoute(outcome, classification, strategyRetrieval)
+		candidates, appErr := impl.retrieveRanked(ctx, scope, catalogue, query, filter, outcome)
+		return candidates, 1, appErr
+
+	case search.IntentBrowse:
+		var candidates []dtos.ResultItem
+		if routed {
+			candidates = browseCategories(scope, catalogue, classification.Categories, text, timings)
+		}
+		if len(candidates) > 0 {
+			if len(classification.Categories) > 0 {
+				explainRoute(outcome, classification, strategyCategories)
+			} else {
+				explainRoute(outcome, classification, strategyTermMatch)
+			}
+			return candidates, consts.MaxBrowseResults, nil
+		}
+		explainRoute(outcome, classification, strategyRetrieval)
+		candidates, appErr := impl.retrieveRanked(ctx, scope, catalogue, query, filter, outcome)
+		return candidates, scope.topK, appErr
+
+	case search.IntentFilter:
+		// a query that is nothing but a price lists every product below
+		if text != "" {
+			explainRoute(outcome, classification, strategyFiltered)
+			candidates, appErr := impl.retrieveRanked(ctx, scope, catalogue, query, filter, outcome)
+			return candidates, scope.topK, appErr
+		}
+
+	case search.IntentDiscovery:
+		explainRoute(outcome, classification, strategyDiversified)
+		candidates, appErr := impl.retrieveRanked(ctx, scope, catalogue, query, filter, outcome)
+		if appErr != nil {
+			return nil, 0, appErr
+		}
+		return search.Diversify(candidates, catalogue.CategoryOf), scope.topK, nil
+	}
+
+	if text == "" {
+		if !routed {
+			return nil, 0, errors.BadRequest("client_id and product_ids filter the vector search, which needs more than a price to search for")
+		}
+		// nothing but a price, every mapped product is a candidate
+		explainRoute(outcome, classification, strategyAllProducts)
+		candidates := make([]dtos.ResultItem, 0, len(catalogue.Order))
+		for _, shortCode := range catalogue.Order {
+			if inScopeCategories(scope, catalogue, shortCode) {
+				candidates = append(candidates, dtos.ResultItem{Code: shortCode, Name: scope.names[shortCode]})
+			}
+		}
+		return candidates, scope.topK, nil
+	}
+	explainRoute(outcome, classification, strategyRetrieval)
+	candidates, appErr := impl.retrieveRanked(ctx, scope, catalogue, query, filter, outcome)
+	return candidates, scope.topK, appErr
+}
+
+// browseCategories expands a Browse query to whole categories, ranked by
+// their lexical match with the query and then catalogue order. Without a
+// matching category every product sharing a term with the query is
+// returned instead. Either way only products of the request's categories
+// are kept.
+func browseCategories(scope *searchScope, catalogue *search.Catalogue, categories []string, text string, timings *dtos.SearchTimingsDto) []dtos.ResultItem {
+	stageStart := time.Now()
+	hits := catalogue.Index.Search(text, catalogue.Index.Len())
+	timings.LexicalMs = elapsedMs(stageStart)
+	lexicalScores := make(map[string]float64, len(hits))
+	for _, hit := range hits {
+		lexicalScores[hit.ShortCode] = hit.Score
+	}
+
+	var candidates []dtos.ResultItem
+	if len(categories) == 0 {
+		for _, hit := range hits {
+			if inScopeCategories(scope, catalogue, hit.ShortCode) {
+				candidates = append(candidates, dtos.ResultItem{Code: hit.ShortCode, Name: scope.names[hit.ShortCode], Score: float32(hit.Score)})
+			}
+		}
+		return candidates
+	}
+
+	seen := make(map[string]bool)
+	for _, category := range categories {
+		for _, shortCode := range catalogue.Categories[category] {
+			if seen[shortCode] || !inScopeCategories(scope, catalogue, shortCode) {
+				continue
+			}
+			seen[shortCode] = true
+			candidates = append(candidates, dtos.ResultItem{Code: shortCode, Name: scope.names[shortCode], Score: float32(lexicalScores[shortCode])})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	return candidates
+}
+
+// inScopeCategories reports whether a product is in one of the request's
+// categories, always when the request names none.
+func inScopeCategories(scope *searchScope, catalogue *search.Catalogue, shortCode string) bool {
+	return len(scope.filter.Categories) == 0 || containsFold(scope.filter.Categories, catalogue.CategoryOf(shortCode))
+}
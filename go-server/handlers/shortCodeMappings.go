@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/utils"
+	"github.com/homingos/flam-go-common/errors"
+)
+
+// GenerateMappingsSvc builds the short code to name mapping of a site code
+// from its categories and saves it as a new mapping version.
+func (impl *CategorySvcImpl) GenerateMappingsSvc(ctx context.Context, siteCode string) (*models.MappingData, *errors.AppError) {
+	categoryData, appErr := impl.GetCategoriesBySiteCodeSvc(ctx, siteCode, "", dtos.SearchParamsDto{})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	// category data is either the DTO from Mongo or a generic map from the
+	// Redis cache; round trip through JSON to read both the same way
+	barr, err := json.Marshal(categoryData)
+	if err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+	var category dtos.CategoryAppResponseDto
+	if err := json.Unmarshal(barr, &category); err != nil {
+		return nil, errors.InternalServerError(err.Error())
+	}
+
+	var mappings []models.ShortCodeMapping
+	for _, cat := range category.Categories {
+		for _, camp := range cat.Campaigns {
+			mappings = append(mappings, models.ShortCodeMapping{
+				ShortCode:  camp.ShortCode,
+				Name:       utils.StripMilvusRefNo(camp.Name),
+				CampaignID: camp.ID,
+			})
+		}
+	}
+	if len(mappings) == 0 {
+		return nil, &errors.AppError{
+			StatusCode: http.StatusNotFound,
+			Message:    "No campaigns found for site code: " + siteCode,
+		}
+	}
+
+	saved, err := impl.mappingStore.Save(ctx, &models.MappingData{
+		SiteCode:    siteCode,
+		Mappings:    mappings,
+		LastUpdated: time.Now().Format(time.RFC3339),
+		Count:       len(mappings),
+	})
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to save mappings: " + err.Error())
+	}
+
+	if impl.natsClient != nil {
+		addedShortCodes := make([]string, 0, len(saved.Mappings))
+		for _, mapping := range saved.Mappings {
+			addedShortCodes = append(addedShortCodes, mapping.ShortCode)
+		}
+		err = impl.natsClient.PublishMappingUpdated(&dtos.MappingUpdatedEventDto{
+			SiteCode:        siteCode,
+			Version:         saved.Version,
+			PreviousVersion: saved.Version - 1,
+			AddedShortCodes: addedShortCodes,
+			Source:          "generate-mappings",
+			UpdatedAt:       saved.LastUpdated,
+		})
+		if err != nil {
+			impl.lgr.Errorw("Error publishing mapping updated event", "siteCode", siteCode, "error", err)
+		}
+	}
+	return saved, nil
+}
+
+// LoadMappingSvc returns the short code to name mapping of a site code,
+// either the latest or a pinned version.
+func (impl *CategorySvcImpl) LoadMappingSvc(ctx context.Context, siteCode string, version int) (*models.MappingData, *errors.AppError) {
+	mapping, err := impl.mappingStore.Get(ctx, siteCode, version)
+	if err == dao.ErrMappingNotFound {
+		message := "Mapping not found. Please generate mappings first."
+		if version != dao.LatestMappingVersion {
+			message = "Mapping version not found for site code: " + siteCode
+		}
+		return nil, &errors.AppError{StatusCode: http.StatusNotFound, Message: message}
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load mapping: " + err.Error())
+	}
+	return mapping, nil
+}
+
+// MappingUpdatedSvc reacts to a mapping updated event: the cached latest
+// mapping and the catalogues of the site code are dropped and evaluation
+// runs pinned to an older version are flagged.
+func (impl *CategorySvcImpl) MappingUpdatedSvc(event dtos.MappingUpdatedEventDto) {
+	if cache, ok := impl.mappingStore.(dao.MappingCache); ok {
+		cache.Invalidate(event.SiteCode)
+	}
+	// the products behind the mapping changed too
+	impl.catalogues.Invalidate(event.SiteCode)
+	impl.evalRuns.MappingUpdated(event.SiteCode, event.Version)
+	impl.lgr.Infow("Short code mapping updated", "siteCode", event.SiteCode, "version", event.Version, "source", event.Source)
+}
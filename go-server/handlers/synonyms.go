@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/flam-go-common/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetSynonymsSvc lists the synonym dictionary of a site code.
+func (impl *CategorySvcImpl) GetSynonymsSvc(ctx context.Context, siteCode string) ([]models.Synonym, *errors.AppError) {
+	synonyms, err := impl.synonymDao.GetSynonymsBySiteCodeDao(ctx, siteCode)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to load synonyms: " + err.Error())
+	}
+	return synonyms, nil
+}
+
+// CreateSynonymSvc adds an entry to the synonym dictionary of a site code.
+func (impl *CategorySvcImpl) CreateSynonymSvc(ctx context.Context, siteCode string, synonymDto dtos.SynonymDto) (*models.Synonym, *errors.AppError) {
+	synonymDto, appErr := validateSynonym(synonymDto)
+	if appErr != nil {
+		return nil, appErr
+	}
+	synonym, err := impl.synonymDao.CreateSynonymDao(ctx, siteCode, synonymDto)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to save synonym: " + err.Error())
+	}
+	impl.synonyms.Invalidate(siteCode)
+	return synonym, nil
+}
+
+// UpdateSynonymSvc replaces an entry of the synonym dictionary of a site code.
+func (impl *CategorySvcImpl) UpdateSynonymSvc(ctx context.Context, siteCode string, id string, synonymDto dtos.SynonymDto) (*models.Synonym, *errors.AppError) {
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.BadRequest("invalid synonym id")
+	}
+	synonymDto, appErr := validateSynonym(synonymDto)
+	if appErr != nil {
+		return nil, appErr
+	}
+	synonym, err := impl.synonymDao.UpdateSynonymDao(ctx, siteCode, ID, synonymDto)
+	if err == dao.ErrSynonymNotFound {
+		return nil, &errors.AppError{StatusCode: http.StatusNotFound, Message: "Synonym not found for site code: " + siteCode}
+	}
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to update synonym: " + err.Error())
+	}
+	impl.synonyms.Invalidate(siteCode)
+	return synonym, nil
+}
+
+// DeleteSynonymSvc removes an entry from the synonym dictionary of a site code.
+func (impl *CategorySvcImpl) DeleteSynonymSvc(ctx context.Context, siteCode string, id string) *errors.AppError {
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.BadRequest("invalid synonym id")
+	}
+	err = impl.synonymDao.DeleteSynonymDao(ctx, siteCode, ID)
+	if err == dao.ErrSynonymNotFound {
+		return &errors.AppError{StatusCode: http.StatusNotFound, Message: "Synonym not found for site code: " + siteCode}
+	}
+	if err != nil {
+		return errors.InternalServerError("Failed to delete synonym: " + err.Error())
+	}
+	impl.synonyms.Invalidate(siteCode)
+	return nil
+}
+
+// siteSynonyms returns the synonym dictionary of a site code. A dictionary
+// that fails to load leaves queries unexpanded rather than failing them.
+func (impl *CategorySvcImpl) siteSynonyms(ctx context.Context, siteCode string) *search.SynonymDictionary {
+	if dictionary, ok := impl.synonyms.Get(siteCode); ok {
+		return dictionary
+	}
+	entries, err := impl.synonymDao.GetSynonymsBySiteCodeDao(ctx, siteCode)
+	if err != nil {
+		impl.lgr.Warnw("Failed to load synonyms, searching without expansion", "siteCode", siteCode, "error", err)
+		return nil
+	}
+	dictionary := search.NewSynonymDictionary(entries)
+	impl.synonyms.Put(siteCode, dictionary)
+	return dictionary
+}
+
+// validateSynonym trims an entry and checks it can expand something: a
+// one-way entry needs a term and a synonym, a two-way entry two synonyms.
+func validateSynonym(synonymDto dtos.SynonymDto) (dtos.SynonymDto, *errors.AppError) {
+	synonymDto.Term = strings.TrimSpace(synonymDto.Term)
+	synonyms := make([]string, 0, len(synonymDto.Synonyms))
+	for _, synonym := range synonymDto.Synonyms {
+		if synonym = strings.TrimSpace(synonym); synonym != "" {
+			synonyms = append(synonyms, synonym)
+		}
+	}
+	synonymDto.Synonyms = synonyms
+
+	switch synonymDto.Direction {
+	case search.SynonymOneWay:
+		if synonymDto.Term == "" || len(synonymDto.Synonyms) == 0 {
+			return synonymDto, errors.BadRequest("a one_way synonym needs a term and at least one synonym")
+		}
+	case search.SynonymTwoWay:
+		if len(synonymDto.Synonyms) < 2 {
+			return synonymDto, errors.BadRequest("a two_way synonym needs at least two synonyms")
+		}
+		synonymDto.Term = ""
+	default:
+		return synonymDto, errors.BadRequest("direction must be one_way or two_way")
+	}
+	return synonymDto, nil
+}
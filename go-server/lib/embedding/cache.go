@@ -0,0 +1,235 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+// Store is the shared cache behind the in-process LRU, implemented by the
+// Redis client. Vectors are keyed by model, dimension and a digest of the
+// normalized text.
+type Store interface {
+	GetEmbedding(ctx context.Context, model string, dimension int, textHash string) ([]float32, bool, error)
+	SetEmbedding(ctx context.Context, model string, dimension int, textHash string, vector []float32, ttl time.Duration) error
+}
+
+// Stats counts how embedding lookups were answered since start up.
+type Stats struct {
+	LRUHits     int64   `json:"lru_hits"`
+	StoreHits   int64   `json:"store_hits"`
+	Misses      int64   `json:"misses"`
+	StoreErrors int64   `json:"store_errors"`
+	HitRate     float64 `json:"hit_rate"`
+	Entries     int     `json:"lru_entries"`
+	Model       string  `json:"model,omitempty"`
+	Dimension   int     `json:"dimension,omitempty"`
+	TTLSeconds  int     `json:"ttl_seconds"`
+}
+
+type modelID struct {
+	name      string
+	dimension int
+}
+
+// Cache is a Provider answering lookups from an in-process LRU, then the
+// shared store, and only then the provider it wraps. The model and
+// dimension in the keys are the ones of the latest fetched embedding,
+// seeded from config or else the provider's name, so a model change on
+// the embedding API starts a fresh key space on its first miss.
+type Cache struct {
+	provider Provider
+	store    Store
+	local    *lru
+	ttl      time.Duration
+
+	mu    sync.RWMutex
+	model modelID
+
+	lruHits     atomic.Int64
+	storeHits   atomic.Int64
+	misses      atomic.Int64
+	storeErrors atomic.Int64
+}
+
+// NewCache returns a cache in front of provider holding up to size
+// embeddings in process, each kept for ttl (0 keeps them until evicted).
+// store may be nil; an empty model is taken from the provider and
+// dimension may be 0 until the first embedding is fetched.
+func NewCache(provider Provider, store Store, size int, ttl time.Duration, model string, dimension int) *Cache {
+	if namer, ok := provider.(ModelNamer); ok && model == "" {
+		model = namer.ModelName()
+	}
+	return &Cache{
+		provider: provider,
+		store:    store,
+		local:    newLRU(size),
+		ttl:      ttl,
+		model:    modelID{name: model, dimension: dimension},
+	}
+}
+
+// NormalizeText is the form of a query that is embedded and cached: lower
+// case with surrounding and repeated whitespace removed.
+func NormalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// Embed returns the embedding of text, fetching and caching it on a miss.
+func (c *Cache) Embed(ctx context.Context, text string) (*dtos.EmbeddingResponse, error) {
+	normalized := NormalizeText(text)
+	if response, ok := c.lookup(ctx, normalized); ok {
+		return response, nil
+	}
+	c.misses.Add(1)
+	response, err := c.provider.Embed(ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+	c.remember(ctx, normalized, response)
+	return response, nil
+}
+
+// EmbedBatch answers what it can from the caches and fetches the remaining
+// texts in one batch.
+func (c *Cache) EmbedBatch(ctx context.Context, texts []string) ([]*dtos.EmbeddingResponse, error) {
+	responses := make([]*dtos.EmbeddingResponse, len(texts))
+	var missing []string
+	var missingAt []int
+	for i, text := range texts {
+		normalized := NormalizeText(text)
+		if response, ok := c.lookup(ctx, normalized); ok {
+			responses[i] = response
+			continue
+		}
+		c.misses.Add(1)
+		missing = append(missing, normalized)
+		missingAt = append(missingAt, i)
+	}
+	if len(missing) == 0 {
+		return responses, nil
+	}
+	fetched, err := c.provider.EmbedBatch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, response := range fetched {
+		c.remember(ctx, missing[j], response)
+		responses[missingAt[j]] = response
+	}
+	return responses, nil
+}
+
+// lookup reads a normalized text from the LRU, then the store.
+func (c *Cache) lookup(ctx context.Context, normalized string) (*dtos.EmbeddingResponse, bool) {
+	model := c.currentModel()
+	if model.name == "" || model.dimension <= 0 {
+		return nil, false
+	}
+	digest := textDigest(normalized)
+	if vector, ok := c.local.get(model.key(digest)); ok {
+		c.lruHits.Add(1)
+		return model.response(vector), true
+	}
+	if c.store == nil {
+		return nil, false
+	}
+	vector, ok, err := c.store.GetEmbedding(ctx, model.name, model.dimension, digest)
+	if err != nil {
+		c.storeErrors.Add(1)
+		return nil, false
+	}
+	if !ok || len(vector) != model.dimension {
+		return nil, false
+	}
+	c.storeHits.Add(1)
+	c.local.put(model.key(digest), vector, c.ttl)
+	return model.response(vector), true
+}
+
+// remember caches a fetched embedding under the model that produced it.
+func (c *Cache) remember(ctx context.Context, normalized string, response *dtos.EmbeddingResponse) {
+	model := modelID{name: response.ModelName, dimension: response.Dimension}
+	if model.dimension == 0 {
+		model.dimension = len(response.Embedding)
+	}
+	if model.name == "" {
+		// an API that does not name its model keeps the configured one
+		model.name = c.currentModel().name
+	}
+	if model.name == "" {
+		return
+	}
+	c.setModel(model)
+
+	digest := textDigest(normalized)
+	c.local.put(model.key(digest), response.Embedding, c.ttl)
+	if c.store != nil {
+		if err := c.store.SetEmbedding(ctx, model.name, model.dimension, digest, response.Embedding, c.ttl); err != nil {
+			c.storeErrors.Add(1)
+		}
+	}
+}
+
+// Stats returns the hit and miss counters of the cache.
+func (c *Cache) Stats() Stats {
+	model := c.currentModel()
+	stats := Stats{
+		LRUHits:     c.lruHits.Load(),
+		StoreHits:   c.storeHits.Load(),
+		Misses:      c.misses.Load(),
+		StoreErrors: c.storeErrors.Load(),
+		Entries:     c.local.len(),
+		Model:       model.name,
+		Dimension:   model.dimension,
+		TTLSeconds:  int(c.ttl / time.Second),
+	}
+	if lookups := stats.LRUHits + stats.StoreHits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.LRUHits+stats.StoreHits) / float64(lookups)
+	}
+	return stats
+}
+
+// ModelName is the model of the latest fetched embedding, or the model
+// the wrapped provider names before anything was fetched.
+func (c *Cache) ModelName() string {
+	if name := c.currentModel().name; name != "" {
+		return name
+	}
+	if namer, ok := c.provider.(ModelNamer); ok {
+		return namer.ModelName()
+	}
+	return ""
+}
+
+func (c *Cache) currentModel() modelID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.model
+}
+
+func (c *Cache) setModel(model modelID) {
+	c.mu.Lock()
+	c.model = model
+	c.mu.Unlock()
+}
+
+func (m modelID) response(vector []float32) *dtos.EmbeddingResponse {
+	return &dtos.EmbeddingResponse{Embedding: vector, ModelName: m.name, Dimension: m.dimension}
+}
+
+func (m modelID) key(digest string) string {
+	return m.name + ":" + strconv.Itoa(m.dimension) + ":" + digest
+}
+
+func textDigest(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
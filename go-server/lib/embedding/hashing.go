@@ -0,0 +1,88 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
+)
+
+const (
+	HashingModelName        = "local-hashing"
+	DefaultHashingDimension = 384
+)
+
+// HashingProvider embeds texts offline by hashing their terms, term pairs
+// and character trigrams into a fixed number of signed buckets. The same
+// text always gets the same unit vector and texts sharing words land close
+// together, which is enough to run and test the service without the
+// embedding API.
+type HashingProvider struct {
+	dimension int
+}
+
+func NewHashingProvider(dimension int) *HashingProvider {
+	if dimension <= 0 {
+		dimension = DefaultHashingDimension
+	}
+	return &HashingProvider{dimension: dimension}
+}
+
+func (p *HashingProvider) ModelName() string { return HashingModelName }
+
+func (p *HashingProvider) Embed(ctx context.Context, text string) (*dtos.EmbeddingResponse, error) {
+	vector := make([]float32, p.dimension)
+	tokens := search.Tokenize(text)
+	for i, token := range tokens {
+		p.add(vector, "t:"+token, 1)
+		if i > 0 {
+			p.add(vector, "b:"+tokens[i-1]+" "+token, 0.5)
+		}
+		padded := "^" + token + "$"
+		for j := 0; j+3 <= len(padded); j++ {
+			p.add(vector, "c:"+padded[j:j+3], 0.25)
+		}
+	}
+	normalize(vector)
+	return &dtos.EmbeddingResponse{
+		Embedding: vector,
+		ModelName: HashingModelName,
+		Dimension: p.dimension,
+		DType:     "float32",
+	}, nil
+}
+
+func (p *HashingProvider) EmbedBatch(ctx context.Context, texts []string) ([]*dtos.EmbeddingResponse, error) {
+	responses := make([]*dtos.EmbeddingResponse, 0, len(texts))
+	for _, text := range texts {
+		response, _ := p.Embed(ctx, text)
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+func (p *HashingProvider) add(vector []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	vector[(sum>>1)%uint64(len(vector))] += weight
+}
+
+func normalize(vector []float32) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+}
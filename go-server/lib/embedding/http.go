@@ -0,0 +1,188 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/homingos/campaign-svc/config"
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+const (
+	// maxBatchTexts bounds the texts sent in one batch request
+	maxBatchTexts = 64
+	// maxParallelRequests bounds single requests in flight when the API has
+	// no batch endpoint
+	maxParallelRequests = 8
+)
+
+// HTTPProvider calls the remote embedding API. Every attempt has its own
+// deadline; network errors, 429 and 5xx responses are retried with
+// exponential backoff.
+type HTTPProvider struct {
+	url        string
+	model      string
+	batchURL   string
+	apiKey     string
+	client     *http.Client
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+}
+
+func NewHTTPProvider(conf config.EmbeddingModelConfig) *HTTPProvider {
+	return &HTTPProvider{
+		url:        conf.URL,
+		model:      conf.ModelName,
+		batchURL:   conf.BatchURL,
+		apiKey:     conf.APIKey,
+		client:     &http.Client{},
+		timeout:    time.Duration(conf.TimeoutMs) * time.Millisecond,
+		maxRetries: conf.MaxRetries,
+		backoff:    time.Duration(conf.BackoffMs) * time.Millisecond,
+	}
+}
+
+// ModelName is the configured model, or the provider and its URL when no
+// model is configured, so embeddings of different APIs never share cache
+// keys.
+func (p *HTTPProvider) ModelName() string {
+	if p.model != "" {
+		return p.model
+	}
+	return ProviderHTTP + ":" + p.url
+}
+
+// Embed returns the embedding of one text.
+func (p *HTTPProvider) Embed(ctx context.Context, text string) (*dtos.EmbeddingResponse, error) {
+	var response *dtos.EmbeddingResponse
+	if err := p.post(ctx, p.url, map[string]string{"text": text}, &response); err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("embedding response is nil")
+	}
+	if response.Embedding == nil {
+		return nil, fmt.Errorf("embedding is nil")
+	}
+	return response, nil
+}
+
+// EmbedBatch sends texts to the batch endpoint in chunks when one is
+// configured, otherwise embeds them one request at a time in parallel.
+func (p *HTTPProvider) EmbedBatch(ctx context.Context, texts []string) ([]*dtos.EmbeddingResponse, error) {
+	if p.batchURL == "" {
+		return p.embedEach(ctx, texts)
+	}
+	responses := make([]*dtos.EmbeddingResponse, 0, len(texts))
+	for start := 0; start < len(texts); start += maxBatchTexts {
+		end := start + maxBatchTexts
+		if end > len(texts) {
+			end = len(texts)
+		}
+		var batch dtos.EmbeddingBatchResponse
+		if err := p.post(ctx, p.batchURL, map[string][]string{"texts": texts[start:end]}, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch.Embeddings) != end-start {
+			return nil, fmt.Errorf("embedding batch returned %d embeddings for %d texts", len(batch.Embeddings), end-start)
+		}
+		for _, vector := range batch.Embeddings {
+			responses = append(responses, &dtos.EmbeddingResponse{
+				Embedding: vector,
+				ModelName: batch.ModelName,
+				Dimension: batch.Dimension,
+				DType:     batch.DType,
+			})
+		}
+	}
+	return responses, nil
+}
+
+func (p *HTTPProvider) embedEach(ctx context.Context, texts []string) ([]*dtos.EmbeddingResponse, error) {
+	responses := make([]*dtos.EmbeddingResponse, len(texts))
+	errs := make([]error, len(texts))
+	sem := make(chan struct{}, maxParallelRequests)
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			responses[i], errs[i] = p.Embed(ctx, text)
+		}(i, text)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// post sends payload as JSON and decodes the response into out, retrying
+// failures that may pass on another attempt.
+func (p *HTTPProvider) post(ctx context.Context, url string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("embedding request cancelled after %d attempts: %w", attempt, lastErr)
+			case <-time.After(p.backoff << (attempt - 1)):
+			}
+		}
+		retry, err := p.attempt(ctx, url, body, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return lastErr
+}
+
+// attempt makes one request and reports whether its failure is retryable.
+func (p *HTTPProvider) attempt(ctx context.Context, url string, body []byte, out interface{}) (bool, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", p.apiKey)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return true, fmt.Errorf("embedding API returned %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return false, fmt.Errorf("embedding API returned %d: %s", resp.StatusCode, data)
+	}
+	return false, json.Unmarshal(data, out)
+}
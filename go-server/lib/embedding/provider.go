@@ -0,0 +1,40 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/homingos/campaign-svc/config"
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+const (
+	ProviderHTTP  = "http"
+	ProviderLocal = "local"
+)
+
+// Provider turns texts into embeddings.
+type Provider interface {
+	Embed(ctx context.Context, text string) (*dtos.EmbeddingResponse, error)
+	// EmbedBatch returns one embedding per text, in order.
+	EmbedBatch(ctx context.Context, texts []string) ([]*dtos.EmbeddingResponse, error)
+}
+
+// ModelNamer is implemented by providers that know which model embeds
+// their texts before they are asked for an embedding.
+type ModelNamer interface {
+	ModelName() string
+}
+
+// NewProvider returns the provider selected by conf.Provider: the remote
+// embedding API ("http", the default) or the offline hashing provider
+// ("local").
+func NewProvider(conf config.EmbeddingModelConfig) (Provider, error) {
+	switch conf.Provider {
+	case "", ProviderHTTP:
+		return NewHTTPProvider(conf), nil
+	case ProviderLocal:
+		return NewHashingProvider(conf.Dimension), nil
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q, expected http or local", conf.Provider)
+}
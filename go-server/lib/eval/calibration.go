@@ -0,0 +1,29 @@
+package eval
+
+import (
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
+)
+
+// CalibrationSamples pairs the score of every result returned for a
+// labeled question with whether it is one of the question's expected
+// products. Questions without expected products are skipped.
+func CalibrationSamples(questions []LabeledQuestion, results func(text string) []dtos.ResultItem) []search.CalibrationSample {
+	var samples []search.CalibrationSample
+	for _, question := range questions {
+		if len(question.Expected) == 0 {
+			continue
+		}
+		expected := make(map[string]bool, len(question.Expected))
+		for _, name := range question.Expected {
+			expected[NormalizeName(name)] = true
+		}
+		for _, item := range results(question.Text) {
+			samples = append(samples, search.CalibrationSample{
+				Score:    float64(item.Score),
+				Relevant: expected[NormalizeName(item.Name)],
+			})
+		}
+	}
+	return samples
+}
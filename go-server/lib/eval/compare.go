@@ -0,0 +1,198 @@
+package eval
+
+import "sort"
+
+// How a question scored in the head run of a comparison against the base.
+const (
+	ChangeImproved  = "improved"
+	ChangeRegressed = "regressed"
+	ChangeUnchanged = "unchanged"
+	// ChangeAdded and ChangeRemoved mark questions only one run asked
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+)
+
+// changeOrder lists regressions first, the way a comparison is read.
+var changeOrder = map[string]int{
+	ChangeRegressed: 0,
+	ChangeImproved:  1,
+	ChangeAdded:     2,
+	ChangeRemoved:   3,
+	ChangeUnchanged: 4,
+}
+
+// scoreEpsilon absorbs float noise when metrics are compared.
+const scoreEpsilon = 1e-9
+
+// RunSummary identifies a run of a comparison.
+type RunSummary struct {
+	RunID    string  `json:"run_id,omitempty"`
+	SiteCode string  `json:"site_code"`
+	K        int     `json:"k"`
+	Overall  Metrics `json:"overall"`
+}
+
+// MetricsComparison is the metrics of one intent in both runs.
+type MetricsComparison struct {
+	Intent string  `json:"intent"`
+	Base   Metrics `json:"base"`
+	Head   Metrics `json:"head"`
+}
+
+// QuestionComparison is one question in both runs. Gained and Lost are the
+// expected products the head run found that the base run missed, and the
+// other way around.
+type QuestionComparison struct {
+	Question string          `json:"question"`
+	Intent   string          `json:"intent"`
+	Change   string          `json:"change"`
+	Base     *QuestionResult `json:"base,omitempty"`
+	Head     *QuestionResult `json:"head,omitempty"`
+	Gained   []string        `json:"gained,omitempty"`
+	Lost     []string        `json:"lost,omitempty"`
+}
+
+// Comparison is a head run scored against a base run, overall, per intent
+// and per question, with regressions first.
+type Comparison struct {
+	Base      RunSummary           `json:"base"`
+	Head      RunSummary           `json:"head"`
+	ByIntent  []MetricsComparison  `json:"by_intent"`
+	Questions []QuestionComparison `json:"questions"`
+	Improved  int                  `json:"improved"`
+	Regressed int                  `json:"regressed"`
+	Unchanged int                  `json:"unchanged"`
+	Added     int                  `json:"added"`
+	Removed   int                  `json:"removed"`
+}
+
+// CompareReports matches the questions of two reports by normalized text
+// and labels every one improved, regressed or unchanged in head. A question
+// is judged by nDCG, then recall, then reciprocal rank, so a product moving
+// up the ranking counts even when the same products were found, and then
+// by whether its search failed.
+func CompareReports(base *Report, head *Report) *Comparison {
+	comparison := &Comparison{
+		Base:      summarizeRun(base),
+		Head:      summarizeRun(head),
+		ByIntent:  []MetricsComparison{},
+		Questions: []QuestionComparison{},
+	}
+
+	intents := make(map[string]bool)
+	for intent := range base.ByIntent {
+		intents[intent] = true
+	}
+	for intent := range head.ByIntent {
+		intents[intent] = true
+	}
+	names := make([]string, 0, len(intents))
+	for intent := range intents {
+		names = append(names, intent)
+	}
+	sort.Strings(names)
+	for _, intent := range names {
+		comparison.ByIntent = append(comparison.ByIntent, MetricsComparison{
+			Intent: intent,
+			Base:   base.ByIntent[intent],
+			Head:   head.ByIntent[intent],
+		})
+	}
+	comparison.ByIntent = append(comparison.ByIntent, MetricsComparison{Intent: OverallIntent, Base: base.Overall, Head: head.Overall})
+
+	headIndex := make(map[string]int, len(head.Results))
+	for i, result := range head.Results {
+		headIndex[NormalizeName(result.Question)] = i
+	}
+	matched := make(map[int]bool, len(head.Results))
+	for i := range base.Results {
+		baseResult := &base.Results[i]
+		j, ok := headIndex[NormalizeName(baseResult.Question)]
+		if !ok || matched[j] {
+			comparison.add(QuestionComparison{Question: baseResult.Question, Intent: baseResult.Intent, Change: ChangeRemoved, Base: baseResult})
+			continue
+		}
+		matched[j] = true
+		headResult := &head.Results[j]
+		comparison.add(QuestionComparison{
+			Question: headResult.Question,
+			Intent:   headResult.Intent,
+			Change:   compareResults(baseResult, headResult),
+			Base:     baseResult,
+			Head:     headResult,
+			Gained:   missing(headResult.Hits, baseResult.Hits),
+			Lost:     missing(baseResult.Hits, headResult.Hits),
+		})
+	}
+	for j := range head.Results {
+		if !matched[j] {
+			headResult := &head.Results[j]
+			comparison.add(QuestionComparison{Question: headResult.Question, Intent: headResult.Intent, Change: ChangeAdded, Head: headResult})
+		}
+	}
+
+	sort.SliceStable(comparison.Questions, func(i, j int) bool {
+		return changeOrder[comparison.Questions[i].Change] < changeOrder[comparison.Questions[j].Change]
+	})
+	return comparison
+}
+
+func (c *Comparison) add(question QuestionComparison) {
+	switch question.Change {
+	case ChangeImproved:
+		c.Improved++
+	case ChangeRegressed:
+		c.Regressed++
+	case ChangeUnchanged:
+		c.Unchanged++
+	case ChangeAdded:
+		c.Added++
+	case ChangeRemoved:
+		c.Removed++
+	}
+	c.Questions = append(c.Questions, question)
+}
+
+// compareResults judges head against base by the first metric that moved.
+func compareResults(base *QuestionResult, head *QuestionResult) string {
+	for _, delta := range []float64{
+		head.NDCG - base.NDCG,
+		head.Recall - base.Recall,
+		head.RR - base.RR,
+	} {
+		if delta > scoreEpsilon {
+			return ChangeImproved
+		}
+		if delta < -scoreEpsilon {
+			return ChangeRegressed
+		}
+	}
+	// a search that starts failing is a regression even when nothing was
+	// found before
+	if head.Error != "" && base.Error == "" {
+		return ChangeRegressed
+	}
+	if head.Error == "" && base.Error != "" {
+		return ChangeImproved
+	}
+	return ChangeUnchanged
+}
+
+func summarizeRun(report *Report) RunSummary {
+	return RunSummary{RunID: report.RunID, SiteCode: report.SiteCode, K: report.K, Overall: report.Overall}
+}
+
+// missing returns the names of from that are not in other.
+func missing(from []string, other []string) []string {
+	have := make(map[string]bool, len(other))
+	for _, name := range other {
+		have[NormalizeName(name)] = true
+	}
+	var names []string
+	for _, name := range from {
+		if !have[NormalizeName(name)] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
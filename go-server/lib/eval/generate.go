@@ -0,0 +1,337 @@
+package eval
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
+)
+
+// Templates synthetic questions are generated from, recorded as a tag on
+// every question next to TagSynthetic.
+const (
+	TagSynthetic         = "synthetic"
+	TemplateExactName    = "exact_name"
+	TemplateParaphrase   = "paraphrased_name"
+	TemplateShortName    = "short_name"
+	TemplateCategory     = "category"
+	TemplatePriceUnder   = "category_under_price"
+	TemplatePriceOver    = "category_over_price"
+	TemplateAttribute    = "category_attribute"
+	TemplateCategoryGift = "category_gift"
+)
+
+// GenerateOptions bounds question generation. A question whose answer has
+// more than MaxExpected products is not generated, and every intent keeps
+// at most MaxPerIntent questions, sampled evenly across the catalogue.
+type GenerateOptions struct {
+	MaxExpected  int
+	MaxPerIntent int
+}
+
+// paraphraseFrames wrap a product name the way shoppers ask for one.
+var paraphraseFrames = []string{
+	"do you have the %s",
+	"i want the %s",
+	"%s please",
+	"looking for the %s",
+}
+
+// currencySymbols writes a price bucket the way the price parser reads it.
+var currencySymbols = map[string]string{"INR": "₹", "USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥"}
+
+// GenerateQuestions builds labeled questions for all four intents from a
+// catalogue, with their expected products computed from it:
+//
+//   - Direct: every product name as written, framed as a request, and
+//     shortened to its last words when they still name it alone
+//   - Browse: every category ("show me {category}")
+//   - Filter: every category under and over a round price near its median
+//   - Discovery: "something {attribute} from {category}" for the attribute
+//     values of a category, and gift ideas for the category
+//
+// Expected products are named as the mapping names them. Questions are
+// identified by a digest of their text, so generating again for the same
+// catalogue yields the same ids.
+func GenerateQuestions(catalogue *search.Catalogue, options GenerateOptions) []dtos.GoldenQuestionDto {
+	g := &generator{catalogue: catalogue, options: options, seen: make(map[string]bool)}
+	g.direct()
+	categories := make([]string, 0, len(catalogue.Categories))
+	for category := range catalogue.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		g.browse(category)
+		g.filter(category)
+		g.discovery(category)
+	}
+
+	var questions []dtos.GoldenQuestionDto
+	for _, intent := range []string{IntentDirect, IntentBrowse, IntentFilter, IntentDiscovery} {
+		questions = append(questions, sampleEvenly(g.byIntent[intent], options.MaxPerIntent)...)
+	}
+	return questions
+}
+
+type generator struct {
+	catalogue *search.Catalogue
+	options   GenerateOptions
+	seen      map[string]bool
+	byIntent  map[string][]dtos.GoldenQuestionDto
+}
+
+// add keeps a question unless its text was generated before or its answer
+// is empty or too broad.
+func (g *generator) add(text string, intent string, template string, shortCodes []string, relevance int) {
+	key := NormalizeName(text)
+	if key == "" || g.seen[key] || len(shortCodes) == 0 {
+		return
+	}
+	if g.options.MaxExpected > 0 && len(shortCodes) > g.options.MaxExpected {
+		return
+	}
+	g.seen[key] = true
+	question := dtos.GoldenQuestionDto{
+		ID:       syntheticID(key),
+		Text:     text,
+		Intent:   intent,
+		Tags:     []string{TagSynthetic, template},
+		Expected: make([]dtos.GoldenExpectedDto, 0, len(shortCodes)),
+	}
+	for _, shortCode := range shortCodes {
+		question.Expected = append(question.Expected, dtos.GoldenExpectedDto{
+			Name:      g.catalogue.Names[shortCode],
+			ShortCode: shortCode,
+			Relevance: relevance,
+		})
+	}
+	if g.byIntent == nil {
+		g.byIntent = make(map[string][]dtos.GoldenQuestionDto)
+	}
+	g.byIntent[intent] = append(g.byIntent[intent], question)
+}
+
+// direct asks for every product by name, exactly and paraphrased.
+func (g *generator) direct() {
+	for i, shortCode := range g.catalogue.Order {
+		name := g.catalogue.Names[shortCode]
+		nameTokens := search.Tokenize(name)
+		if len(nameTokens) == 0 {
+			continue
+		}
+		// products sharing the name are all the answer
+		same := g.namedExactly(nameTokens)
+		g.add(name, IntentDirect, TemplateExactName, same, MaxRelevance)
+
+		frame := paraphraseFrames[i%len(paraphraseFrames)]
+		g.add(fmt.Sprintf(frame, strings.ToLower(name)), IntentDirect, TemplateParaphrase, same, MaxRelevance)
+
+		// "Patterned fleece blanket" is still only "fleece blanket" when no
+		// other product shares those words
+		words := strings.Fields(name)
+		if len(words) < 3 {
+			continue
+		}
+		short := strings.Join(words[1:], " ")
+		if named := g.namedBy(search.Tokenize(short)); len(named) == 1 && named[0] == shortCode {
+			g.add(short, IntentDirect, TemplateShortName, named, MaxRelevance)
+		}
+	}
+}
+
+// browse asks for a whole category.
+func (g *generator) browse(category string) {
+	g.add("Show me "+category, IntentBrowse, TemplateCategory, g.catalogue.Categories[category], MinRelevance)
+}
+
+// filter asks for a category under and over a round price near its
+// median. The expected products are read off the catalogue prices against
+// the bucket's amount, not through the query parser the questions are
+// meant to test: at most the amount for under, at least it for over, in
+// the question's currency when it names one.
+func (g *generator) filter(category string) {
+	shortCodes := g.catalogue.Categories[category]
+	var prices []float64
+	priced := make(map[string]float64, len(shortCodes))
+	currency := ""
+	mixed := false
+	for _, shortCode := range shortCodes {
+		product := g.catalogue.Products[shortCode]
+		price, ok := search.ParsePrice(product.Price)
+		if !ok {
+			continue
+		}
+		prices = append(prices, price)
+		priced[shortCode] = price
+		code := search.NormalizeCurrency(product.Currency)
+		if currency == "" {
+			currency = code
+		} else if code != "" && code != currency {
+			mixed = true
+		}
+	}
+	if len(prices) < 2 {
+		return
+	}
+	if mixed {
+		currency = ""
+	}
+	sort.Float64s(prices)
+	median := prices[len(prices)/2]
+
+	for _, bucket := range []struct {
+		template string
+		phrase   string
+		amount   float64
+		under    bool
+	}{
+		{TemplatePriceUnder, "under", niceCeil(median), true},
+		{TemplatePriceOver, "over", niceFloor(median), false},
+	} {
+		if bucket.amount <= 0 {
+			continue
+		}
+		text := fmt.Sprintf("%s %s %s", category, bucket.phrase, formatAmount(bucket.amount, currency))
+		var matched []string
+		for _, shortCode := range shortCodes {
+			price, ok := priced[shortCode]
+			if !ok {
+				continue
+			}
+			if code := search.NormalizeCurrency(g.catalogue.Products[shortCode].Currency); currency != "" && code != "" && code != currency {
+				continue
+			}
+			if (bucket.under && price <= bucket.amount) || (!bucket.under && price >= bucket.amount) {
+				matched = append(matched, shortCode)
+			}
+		}
+		g.add(text, IntentFilter, bucket.template, matched, MinRelevance)
+	}
+}
+
+// discovery asks open ended questions scoped by a category: by the
+// attribute values its products carry, and for gift ideas.
+func (g *generator) discovery(category string) {
+	shortCodes := g.catalogue.Categories[category]
+	withValue := make(map[string][]string)
+	for _, shortCode := range shortCodes {
+		seen := make(map[string]bool)
+		for _, values := range g.catalogue.Attributes[shortCode] {
+			for _, value := range values {
+				if !seen[value] {
+					seen[value] = true
+					withValue[value] = append(withValue[value], shortCode)
+				}
+			}
+		}
+	}
+	values := make([]string, 0, len(withValue))
+	for value := range withValue {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		// a value every product has asks for nothing more than the category
+		if len(withValue[value]) == len(shortCodes) {
+			continue
+		}
+		text := fmt.Sprintf("something %s from %s", value, strings.ToLower(category))
+		g.add(text, IntentDiscovery, TemplateAttribute, withValue[value], MinRelevance)
+	}
+	g.add(fmt.Sprintf("gift ideas from %s", strings.ToLower(category)), IntentDiscovery, TemplateCategoryGift, shortCodes, MinRelevance)
+}
+
+// namedBy returns the products whose mapped name holds every token.
+func (g *generator) namedBy(tokens []string) []string {
+	var named []string
+	for _, shortCode := range g.catalogue.Order {
+		if containsTokens(search.Tokenize(g.catalogue.Names[shortCode]), tokens) {
+			named = append(named, shortCode)
+		}
+	}
+	return named
+}
+
+// namedExactly returns the products whose mapped name has these tokens.
+func (g *generator) namedExactly(tokens []string) []string {
+	key := strings.Join(tokens, " ")
+	var named []string
+	for _, shortCode := range g.catalogue.Order {
+		if strings.Join(search.Tokenize(g.catalogue.Names[shortCode]), " ") == key {
+			named = append(named, shortCode)
+		}
+	}
+	return named
+}
+
+func containsTokens(tokens []string, wanted []string) bool {
+	have := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		have[token] = true
+	}
+	for _, token := range wanted {
+		if !have[token] {
+			return false
+		}
+	}
+	return len(wanted) > 0
+}
+
+// sampleEvenly keeps at most max questions spread evenly over the list; a
+// max of 0 keeps them all.
+func sampleEvenly(questions []dtos.GoldenQuestionDto, max int) []dtos.GoldenQuestionDto {
+	if max <= 0 || len(questions) <= max {
+		return questions
+	}
+	sampled := make([]dtos.GoldenQuestionDto, 0, max)
+	step := float64(len(questions)) / float64(max)
+	for i := 0; i < max; i++ {
+		sampled = append(sampled, questions[int(float64(i)*step)])
+	}
+	return sampled
+}
+
+// syntheticID identifies a generated question by its normalized text.
+func syntheticID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return TagSynthetic + "-" + hex.EncodeToString(sum[:6])
+}
+
+// niceCeil and niceFloor round a price to the nearest 1, 2 or 5 times a
+// power of ten above or below it, the way people name price ranges.
+func niceCeil(value float64) float64 {
+	if value <= 0 {
+		return 0
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if nice := step * magnitude; nice >= value {
+			return nice
+		}
+	}
+	return 10 * magnitude
+}
+
+func niceFloor(value float64) float64 {
+	if value <= 0 {
+		return 0
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{5, 2, 1} {
+		if nice := step * magnitude; nice <= value {
+			return nice
+		}
+	}
+	return magnitude
+}
+
+func formatAmount(amount float64, currency string) string {
+	return currencySymbols[currency] + strconv.FormatFloat(amount, 'f', -1, 64)
+}
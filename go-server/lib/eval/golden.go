@@ -0,0 +1,163 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+)
+
+// Relevance grades of an expected product.
+const (
+	MinRelevance = 1
+	MaxRelevance = 3
+)
+
+// ParseGoldenCSV reads golden questions in the questions.txt format with
+// optional relevance, tags and id columns:
+//
+//	question,"expected 1, expected 2",Intent,"3, 1","tag 1, tag 2",id
+//
+// Relevance grades the expected products in order; products without a
+// grade are relevant (1).
+func ParseGoldenCSV(r io.Reader) ([]dtos.GoldenQuestionDto, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var questions []dtos.GoldenQuestionDto
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected at least question and answer columns, got %d", line, len(record))
+		}
+
+		question := dtos.GoldenQuestionDto{Text: strings.TrimSpace(record[0]), Intent: IntentUnknown}
+		names := splitExpected(record[1])
+		var grades []string
+		if len(record) > 3 {
+			grades = splitExpected(record[3])
+		}
+		if len(grades) > len(names) {
+			return nil, fmt.Errorf("line %d: %d relevance grades for %d expected products", line, len(grades), len(names))
+		}
+		for i, name := range names {
+			expected := dtos.GoldenExpectedDto{Name: name, Relevance: MinRelevance}
+			if i < len(grades) {
+				grade, err := strconv.Atoi(grades[i])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: relevance %q is not a number", line, grades[i])
+				}
+				expected.Relevance = grade
+			}
+			question.Expected = append(question.Expected, expected)
+		}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			question.Intent = NormalizeIntent(record[2])
+		}
+		if len(record) > 4 {
+			question.Tags = splitExpected(record[4])
+		}
+		if len(record) > 5 {
+			question.ID = strings.TrimSpace(record[5])
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// WriteGoldenCSV writes golden questions in the format ParseGoldenCSV
+// reads, which questions.txt readers read as well.
+func WriteGoldenCSV(w io.Writer, questions []models.GoldenQuestion) error {
+	writer := csv.NewWriter(w)
+	for _, question := range questions {
+		names := make([]string, 0, len(question.Expected))
+		grades := make([]string, 0, len(question.Expected))
+		for _, expected := range question.Expected {
+			names = append(names, expected.Name)
+			grades = append(grades, strconv.Itoa(expected.Relevance))
+		}
+		record := []string{
+			question.Text,
+			strings.Join(names, ", "),
+			question.Intent,
+			strings.Join(grades, ", "),
+			strings.Join(question.Tags, ", "),
+			question.ID,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ParseGoldenJSONL reads golden questions as JSON lines, one question per
+// line.
+func ParseGoldenJSONL(r io.Reader) ([]dtos.GoldenQuestionDto, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	var questions []dtos.GoldenQuestionDto
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var question dtos.GoldenQuestionDto
+		if err := json.Unmarshal(text, &question); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		questions = append(questions, question)
+	}
+	return questions, scanner.Err()
+}
+
+// WriteGoldenJSONL writes golden questions as JSON lines.
+func WriteGoldenJSONL(w io.Writer, questions []models.GoldenQuestion) error {
+	encoder := json.NewEncoder(w)
+	for _, question := range questions {
+		if err := encoder.Encode(question); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GoldenQuestions returns the questions of a golden set as labeled
+// questions, graded by the relevance of their expected products.
+func GoldenQuestions(set *models.GoldenSet) []LabeledQuestion {
+	questions := make([]LabeledQuestion, 0, len(set.Questions))
+	for _, golden := range set.Questions {
+		question := LabeledQuestion{
+			Text:     golden.Text,
+			Expected: make([]string, 0, len(golden.Expected)),
+			Intent:   golden.Intent,
+			Grades:   make(map[string]int, len(golden.Expected)),
+		}
+		for _, expected := range golden.Expected {
+			question.Expected = append(question.Expected, expected.Name)
+			question.Grades[NormalizeName(expected.Name)] = expected.Relevance
+		}
+		questions = append(questions, question)
+	}
+	return questions
+}
@@ -0,0 +1,163 @@
+package eval
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+)
+
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplates = template.Must(template.New("report").Funcs(template.FuncMap{
+	"delta":      formatDelta,
+	"deltaClass": deltaClass,
+}).Parse(reportTemplateText))
+
+// htmlMetrics is a row of the metrics table.
+type htmlMetrics struct {
+	Label string
+	Metrics
+}
+
+// htmlProduct is an expected or returned product of a question. A returned
+// product past k is shown but does not count.
+type htmlProduct struct {
+	Rank     int
+	Name     string
+	Score    float32
+	HasScore bool
+	Hit      bool
+	Cut      bool
+	Cause    string
+}
+
+// htmlQuestion is a question with its expected and returned products
+// marked as hit or missed.
+type htmlQuestion struct {
+	*QuestionResult
+	ExpectedProducts []htmlProduct
+	ReturnedProducts []htmlProduct
+}
+
+type htmlReportPage struct {
+	Report    *Report
+	Metrics   []htmlMetrics
+	Questions []htmlQuestion
+	Missed    int
+	Failed    int
+}
+
+// htmlComparedQuestion is a question of a comparison with both runs'
+// products.
+type htmlComparedQuestion struct {
+	QuestionComparison
+	BaseView *htmlQuestion
+	HeadView *htmlQuestion
+}
+
+type htmlComparisonPage struct {
+	Comparison *Comparison
+	Rows       []htmlComparedQuestion
+	KMismatch  bool
+}
+
+// WriteHTMLReport writes a report as a self-contained HTML page: the
+// metrics per intent, the misses by cause and every question with its
+// expected and returned products, missed ones highlighted.
+func WriteHTMLReport(w io.Writer, report *Report) error {
+	page := htmlReportPage{Report: report, Questions: make([]htmlQuestion, 0, len(report.Results))}
+
+	intents := make([]string, 0, len(report.ByIntent))
+	for intent := range report.ByIntent {
+		intents = append(intents, intent)
+	}
+	sort.Strings(intents)
+	for _, intent := range intents {
+		page.Metrics = append(page.Metrics, htmlMetrics{Label: intent, Metrics: report.ByIntent[intent]})
+	}
+	page.Metrics = append(page.Metrics, htmlMetrics{Label: OverallIntent, Metrics: report.Overall})
+
+	for i := range report.Results {
+		result := &report.Results[i]
+		if result.Error != "" {
+			page.Failed++
+		} else if len(result.Misses) > 0 {
+			page.Missed++
+		}
+		page.Questions = append(page.Questions, buildHTMLQuestion(result, report.K))
+	}
+	return reportTemplates.ExecuteTemplate(w, "report", page)
+}
+
+// WriteHTMLComparison writes a comparison as a self-contained HTML page
+// with both runs side by side, regressions first.
+func WriteHTMLComparison(w io.Writer, comparison *Comparison) error {
+	page := htmlComparisonPage{
+		Comparison: comparison,
+		Rows:       make([]htmlComparedQuestion, 0, len(comparison.Questions)),
+		KMismatch:  comparison.Base.K != comparison.Head.K,
+	}
+	for _, question := range comparison.Questions {
+		row := htmlComparedQuestion{QuestionComparison: question}
+		if question.Base != nil {
+			view := buildHTMLQuestion(question.Base, comparison.Base.K)
+			row.BaseView = &view
+		}
+		if question.Head != nil {
+			view := buildHTMLQuestion(question.Head, comparison.Head.K)
+			row.HeadView = &view
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	return reportTemplates.ExecuteTemplate(w, "comparison", page)
+}
+
+func buildHTMLQuestion(result *QuestionResult, k int) htmlQuestion {
+	hits := make(map[string]bool, len(result.Hits))
+	for _, name := range result.Hits {
+		hits[NormalizeName(name)] = true
+	}
+	expected := make(map[string]bool, len(result.Expected))
+	view := htmlQuestion{QuestionResult: result}
+	for _, name := range result.Expected {
+		key := NormalizeName(name)
+		expected[key] = true
+		view.ExpectedProducts = append(view.ExpectedProducts, htmlProduct{
+			Name:  name,
+			Hit:   hits[key],
+			Cause: result.MissCauses[name],
+		})
+	}
+	for i, name := range result.Returned {
+		product := htmlProduct{
+			Rank: i + 1,
+			Name: name,
+			Hit:  i < k && expected[NormalizeName(name)],
+			Cut:  i >= k,
+		}
+		if i < len(result.Scores) {
+			product.Score = result.Scores[i]
+			product.HasScore = true
+		}
+		view.ReturnedProducts = append(view.ReturnedProducts, product)
+	}
+	return view
+}
+
+func formatDelta(base float64, head float64) string {
+	return fmt.Sprintf("%+.3f", head-base)
+}
+
+// deltaClass colours a metric that moved between two runs.
+func deltaClass(base float64, head float64) string {
+	switch {
+	case head-base > scoreEpsilon:
+		return "up"
+	case head-base < -scoreEpsilon:
+		return "down"
+	}
+	return ""
+}
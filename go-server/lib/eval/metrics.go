@@ -0,0 +1,169 @@
+package eval
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Metrics holds ranking quality scores averaged over a set of questions.
+type Metrics struct {
+	Questions int     `json:"questions"`
+	Precision float64 `json:"precision_at_k"`
+	Recall    float64 `json:"recall_at_k"`
+	MRR       float64 `json:"mrr"`
+	NDCG      float64 `json:"ndcg_at_k"`
+}
+
+// QuestionResult is the outcome of a single labeled question.
+type QuestionResult struct {
+	Question  string    `json:"question"`
+	Intent    string    `json:"intent"`
+	Expected  []string  `json:"expected"`
+	Returned  []string  `json:"returned"`
+	Scores    []float32 `json:"scores"`
+	Hits      []string  `json:"hits"`
+	Misses    []string  `json:"misses"`
+	Precision float64   `json:"precision_at_k"`
+	Recall    float64   `json:"recall_at_k"`
+	RR        float64   `json:"reciprocal_rank"`
+	NDCG      float64   `json:"ndcg_at_k"`
+	Error     string    `json:"error,omitempty"`
+	// MissCauses labels every missed expected product with why it was missed
+	MissCauses map[string]string `json:"miss_causes,omitempty"`
+}
+
+// Report is the summary of an evaluation run, overall and per intent.
+type Report struct {
+	RunID    string             `json:"run_id,omitempty"`
+	SiteCode string             `json:"site_code"`
+	K        int                `json:"k"`
+	Overall  Metrics            `json:"overall"`
+	ByIntent map[string]Metrics `json:"by_intent"`
+	Results  []QuestionResult   `json:"results"`
+	Misses   *MissReport        `json:"misses,omitempty"`
+	// Labels is how the expected products resolved to the run's mapped names
+	Labels *LabelReport `json:"labels,omitempty"`
+}
+
+// NormalizeName makes product names comparable: lower case with collapsed
+// whitespace.
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// ScoreQuestion compares the ranked product names returned for a question
+// against its expected products and computes the @k metrics. A returned
+// name is relevant when it matches an expected name; nDCG weighs it by
+// the product's relevance grade, precision, recall and MRR do not.
+func ScoreQuestion(question LabeledQuestion, returned []string, scores []float32, k int) QuestionResult {
+	result := QuestionResult{
+		Question: question.Text,
+		Intent:   question.Intent,
+		Expected: question.Expected,
+		Returned: returned,
+		Scores:   scores,
+		Hits:     []string{},
+		Misses:   []string{},
+	}
+
+	expected := make(map[string]bool, len(question.Expected))
+	for _, name := range question.Expected {
+		expected[NormalizeName(name)] = true
+	}
+	if len(expected) == 0 || k <= 0 {
+		return result
+	}
+
+	found := make(map[string]bool)
+	dcg := 0.0
+	for i, name := range returned {
+		if i >= k {
+			break
+		}
+		key := NormalizeName(name)
+		if !expected[key] || found[key] {
+			continue
+		}
+		found[key] = true
+		result.Hits = append(result.Hits, name)
+		if result.RR == 0 {
+			result.RR = 1 / float64(i+1)
+		}
+		dcg += gain(question.grade(key)) / math.Log2(float64(i+2))
+	}
+	for _, name := range question.Expected {
+		if !found[NormalizeName(name)] {
+			result.Misses = append(result.Misses, name)
+		}
+	}
+
+	grades := make([]int, 0, len(expected))
+	for key := range expected {
+		grades = append(grades, question.grade(key))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(grades)))
+	idcg := 0.0
+	for i := 0; i < len(grades) && i < k; i++ {
+		idcg += gain(grades[i]) / math.Log2(float64(i+2))
+	}
+
+	result.Precision = float64(len(found)) / float64(k)
+	result.Recall = float64(len(found)) / float64(len(expected))
+	if idcg > 0 {
+		result.NDCG = dcg / idcg
+	}
+	return result
+}
+
+// Summarize averages question results overall and per intent.
+func Summarize(siteCode string, k int, results []QuestionResult) *Report {
+	report := &Report{
+		SiteCode: siteCode,
+		K:        k,
+		ByIntent: make(map[string]Metrics),
+		Results:  results,
+	}
+
+	grouped := make(map[string][]QuestionResult)
+	for _, result := range results {
+		grouped[result.Intent] = append(grouped[result.Intent], result)
+	}
+	report.Overall = average(results)
+	for intent, intentResults := range grouped {
+		report.ByIntent[intent] = average(intentResults)
+	}
+	return report
+}
+
+// grade is the relevance of an expected product by normalized name.
+func (q LabeledQuestion) grade(key string) int {
+	if grade, ok := q.Grades[key]; ok && grade > 0 {
+		return grade
+	}
+	return MinRelevance
+}
+
+// gain is the nDCG gain of a relevance grade, 1 for a relevant product.
+func gain(grade int) float64 {
+	return math.Pow(2, float64(grade)) - 1
+}
+
+func average(results []QuestionResult) Metrics {
+	metrics := Metrics{Questions: len(results)}
+	if len(results) == 0 {
+		return metrics
+	}
+	for _, result := range results {
+		metrics.Precision += result.Precision
+		metrics.Recall += result.Recall
+		metrics.MRR += result.RR
+		metrics.NDCG += result.NDCG
+	}
+	n := float64(len(results))
+	metrics.Precision /= n
+	metrics.Recall /= n
+	metrics.MRR /= n
+	metrics.NDCG /= n
+	return metrics
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestScoreQuestion(t *testing.T) {
+	tests := []struct {
+		name      string
+		question  LabeledQuestion
+		returned  []string
+		k         int
+		precision float64
+		recall    float64
+		rr        float64
+		ndcg      float64
+		hits      []string
+		misses    []string
+	}{
+		{
+			name:      "first result expected",
+			question:  LabeledQuestion{Expected: []string{"Red Sofa"}},
+			returned:  []string{"red  sofa", "Chair", "Lamp"},
+			k:         3,
+			precision: 1.0 / 3,
+			recall:    1,
+			rr:        1,
+			ndcg:      1,
+			hits:      []string{"red  sofa"},
+			misses:    []string{},
+		},
+		{
+			// dcg 1/log2(3), idcg 1 + 1/log2(3)
+			name:      "hit at rank two",
+			question:  LabeledQuestion{Expected: []string{"Sofa", "Chair"}},
+			returned:  []string{"Lamp", "Sofa", "Rug"},
+			k:         3,
+			precision: 1.0 / 3,
+			recall:    0.5,
+			rr:        0.5,
+			ndcg:      0.3868528,
+			hits:      []string{"Sofa"},
+			misses:    []string{"Chair"},
+		},
+		{
+			// gains 2^3-1 and 2^1-1: dcg 1 + 7/log2(3), idcg 7 + 1/log2(3)
+			name:      "graded relevance",
+			question:  LabeledQuestion{Expected: []string{"Sofa", "Chair"}, Grades: map[string]int{"sofa": 3}},
+			returned:  []string{"Chair", "Sofa"},
+			k:         2,
+			precision: 1,
+			recall:    1,
+			rr:        1,
+			ndcg:      0.7098097,
+			hits:      []string{"Chair", "Sofa"},
+			misses:    []string{},
+		},
+		{
+			// the repeated sofa counts once: dcg 1 + 1/log2(4)
+			name:      "duplicate returned names",
+			question:  LabeledQuestion{Expected: []string{"Sofa", "Chair"}},
+			returned:  []string{"Sofa", "SOFA", "Chair"},
+			k:         3,
+			precision: 2.0 / 3,
+			recall:    1,
+			rr:        1,
+			ndcg:      0.9197208,
+			hits:      []string{"Sofa", "Chair"},
+			misses:    []string{},
+		},
+		{
+			name:      "k beyond the returned results",
+			question:  LabeledQuestion{Expected: []string{"Sofa"}},
+			returned:  []string{"Sofa"},
+			k:         5,
+			precision: 0.2,
+			recall:    1,
+			rr:        1,
+			ndcg:      1,
+			hits:      []string{"Sofa"},
+			misses:    []string{},
+		},
+		{
+			name:     "expected products below k",
+			question: LabeledQuestion{Expected: []string{"Sofa"}},
+			returned: []string{"Lamp", "Rug", "Sofa"},
+			k:        2,
+			hits:     []string{},
+			misses:   []string{"Sofa"},
+		},
+		{
+			name:     "no expected products",
+			question: LabeledQuestion{},
+			returned: []string{"Sofa", "Chair"},
+			k:        2,
+			hits:     []string{},
+			misses:   []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := ScoreQuestion(test.question, test.returned, nil, test.k)
+			metrics := []struct {
+				name      string
+				got, want float64
+			}{
+				{"precision", result.Precision, test.precision},
+				{"recall", result.Recall, test.recall},
+				{"reciprocal rank", result.RR, test.rr},
+				{"ndcg", result.NDCG, test.ndcg},
+			}
+			for _, metric := range metrics {
+				if math.Abs(metric.got-metric.want) > 1e-6 {
+					t.Errorf("%s = %v, want %v", metric.name, metric.got, metric.want)
+				}
+			}
+			if !reflect.DeepEqual(result.Hits, test.hits) {
+				t.Errorf("hits = %q, want %q", result.Hits, test.hits)
+			}
+			if !reflect.DeepEqual(result.Misses, test.misses) {
+				t.Errorf("misses = %q, want %q", result.Misses, test.misses)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	results := []QuestionResult{
+		{Intent: IntentDirect, Precision: 1, Recall: 1, RR: 1, NDCG: 1},
+		{Intent: IntentDirect, Precision: 0, Recall: 0, RR: 0, NDCG: 0},
+		{Intent: IntentBrowse, Precision: 0.5, Recall: 0.25, RR: 0.5, NDCG: 0.4},
+	}
+	report := Summarize("site", 3, results)
+
+	want := Metrics{Questions: 3, Precision: 0.5, Recall: 1.25 / 3, MRR: 0.5, NDCG: 1.4 / 3}
+	if !closeMetrics(report.Overall, want) {
+		t.Errorf("overall = %+v, want %+v", report.Overall, want)
+	}
+	if got, want := report.ByIntent[IntentDirect], (Metrics{Questions: 2, Precision: 0.5, Recall: 0.5, MRR: 0.5, NDCG: 0.5}); !closeMetrics(got, want) {
+		t.Errorf("Direct = %+v, want %+v", got, want)
+	}
+	if got, want := report.ByIntent[IntentBrowse], (Metrics{Questions: 1, Precision: 0.5, Recall: 0.25, MRR: 0.5, NDCG: 0.4}); !closeMetrics(got, want) {
+		t.Errorf("Browse = %+v, want %+v", got, want)
+	}
+	if empty := Summarize("site", 3, nil).Overall; empty != (Metrics{}) {
+		t.Errorf("empty overall = %+v, want zero", empty)
+	}
+}
+
+func closeMetrics(got, want Metrics) bool {
+	return got.Questions == want.Questions &&
+		math.Abs(got.Precision-want.Precision) < 1e-9 &&
+		math.Abs(got.Recall-want.Recall) < 1e-9 &&
+		math.Abs(got.MRR-want.MRR) < 1e-9 &&
+		math.Abs(got.NDCG-want.NDCG) < 1e-9
+}
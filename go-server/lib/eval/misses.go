@@ -0,0 +1,213 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/types/consts"
+)
+
+// Causes of a missed expected product, in the order they are checked.
+const (
+	MissNotInMapping           = "not_in_mapping"
+	MissInactiveCampaign       = "inactive_campaign"
+	MissExperienceNotProcessed = "experience_not_processed"
+	MissBelowCut               = "below_cut"
+	MissNeverRetrieved         = "never_retrieved"
+	// MissUnknown is left when the deep search of the question failed
+	MissUnknown = "unknown"
+)
+
+// MissCauses lists every cause in report order.
+var MissCauses = []string{
+	MissNotInMapping,
+	MissInactiveCampaign,
+	MissExperienceNotProcessed,
+	MissBelowCut,
+	MissNeverRetrieved,
+	MissUnknown,
+}
+
+// ProductState is what the mapping and the campaign store know of an
+// expected product: the short codes mapped to its name and the state of
+// their campaigns.
+type ProductState struct {
+	ShortCodes []string
+	Campaigns  map[string]dtos.CampaignStateDto
+}
+
+// Miss is an expected product a question did not return in its top k, and
+// why.
+type Miss struct {
+	Question  string `json:"question"`
+	Intent    string `json:"intent"`
+	Product   string `json:"product"`
+	Cause     string `json:"cause"`
+	ShortCode string `json:"short_code,omitempty"`
+	// DeepRank and DeepScore place a product retrieved below the cut in
+	// the deep search
+	DeepRank  int     `json:"deep_rank,omitempty"`
+	DeepScore float32 `json:"deep_score,omitempty"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+// CauseMisses groups the misses of one cause.
+type CauseMisses struct {
+	Cause  string `json:"cause"`
+	Count  int    `json:"count"`
+	Misses []Miss `json:"misses"`
+}
+
+// ProductMisses counts the misses of one expected product by cause.
+type ProductMisses struct {
+	Product   string         `json:"product"`
+	ShortCode string         `json:"short_code,omitempty"`
+	Misses    int            `json:"misses"`
+	Causes    map[string]int `json:"causes"`
+	Questions []string       `json:"questions"`
+}
+
+// MissReport labels every missed expected product of an evaluation,
+// grouped by cause and by product.
+type MissReport struct {
+	DeepK     int             `json:"deep_k"`
+	Total     int             `json:"total"`
+	ByCause   []CauseMisses   `json:"by_cause"`
+	ByProduct []ProductMisses `json:"by_product"`
+}
+
+// DeepSearcher returns the results of a question searched deeper than k,
+// without score or confidence cutoffs.
+type DeepSearcher func(question string) ([]dtos.ResultItem, error)
+
+// DiagnoseMisses labels every missed expected product of the scored
+// questions with the first cause that explains it, records the causes on
+// each question result and returns them grouped. products is keyed by the
+// normalized product name. Questions whose search failed are left out.
+func DiagnoseMisses(results []QuestionResult, products map[string]ProductState, deepK int, deep DeepSearcher) *MissReport {
+	report := &MissReport{DeepK: deepK, ByCause: []CauseMisses{}, ByProduct: []ProductMisses{}}
+	byCause := make(map[string][]Miss)
+	byProduct := make(map[string]*ProductMisses)
+	for i := range results {
+		result := &results[i]
+		if result.Error != "" || len(result.Misses) == 0 {
+			continue
+		}
+		var deepResults []dtos.ResultItem
+		var deepErr error
+		deepDone := false
+		result.MissCauses = make(map[string]string, len(result.Misses))
+		for _, product := range result.Misses {
+			miss := Miss{Question: result.Question, Intent: result.Intent, Product: product}
+			if !classifyState(&miss, products[NormalizeName(product)]) {
+				if !deepDone {
+					deepResults, deepErr = deep(result.Question)
+					deepDone = true
+				}
+				classifyRetrieval(&miss, deepResults, deepErr)
+			}
+			result.MissCauses[product] = miss.Cause
+			byCause[miss.Cause] = append(byCause[miss.Cause], miss)
+			report.Total++
+
+			key := NormalizeName(product)
+			group, ok := byProduct[key]
+			if !ok {
+				group = &ProductMisses{Product: product, ShortCode: miss.ShortCode, Causes: make(map[string]int)}
+				byProduct[key] = group
+			}
+			group.Misses++
+			group.Causes[miss.Cause]++
+			group.Questions = append(group.Questions, result.Question)
+		}
+	}
+
+	for _, cause := range MissCauses {
+		if misses := byCause[cause]; len(misses) > 0 {
+			report.ByCause = append(report.ByCause, CauseMisses{Cause: cause, Count: len(misses), Misses: misses})
+		}
+	}
+	for _, group := range byProduct {
+		report.ByProduct = append(report.ByProduct, *group)
+	}
+	sort.Slice(report.ByProduct, func(i, j int) bool {
+		if report.ByProduct[i].Misses != report.ByProduct[j].Misses {
+			return report.ByProduct[i].Misses > report.ByProduct[j].Misses
+		}
+		return report.ByProduct[i].Product < report.ByProduct[j].Product
+	})
+	return report
+}
+
+// classifyState labels a miss the mapping or the category pipeline
+// explains: the product is not mapped, none of its campaigns is active or
+// none of the active ones has a processed experience. It reports whether
+// it labeled the miss.
+func classifyState(miss *Miss, state ProductState) bool {
+	if len(state.ShortCodes) == 0 {
+		miss.Cause = MissNotInMapping
+		miss.Detail = "no short code is mapped to this name"
+		return true
+	}
+	miss.ShortCode = state.ShortCodes[0]
+
+	var active []dtos.CampaignStateDto
+	for _, shortCode := range state.ShortCodes {
+		if campaign, ok := state.Campaigns[shortCode]; ok && campaign.IsActive {
+			active = append(active, campaign)
+		}
+	}
+	if len(active) == 0 {
+		miss.Cause = MissInactiveCampaign
+		if _, ok := state.Campaigns[miss.ShortCode]; ok {
+			miss.Detail = fmt.Sprintf("campaign %s is inactive", miss.ShortCode)
+		} else {
+			miss.Detail = fmt.Sprintf("no campaign has short code %s", miss.ShortCode)
+		}
+		return true
+	}
+
+	var statuses []string
+	for _, campaign := range active {
+		for _, status := range campaign.ExperienceStatuses {
+			if status == consts.Processed {
+				miss.ShortCode = campaign.ShortCode
+				return false
+			}
+		}
+		statuses = append(statuses, campaign.ExperienceStatuses...)
+	}
+	miss.Cause = MissExperienceNotProcessed
+	miss.ShortCode = active[0].ShortCode
+	if len(statuses) == 0 {
+		miss.Detail = "no active experience"
+	} else {
+		miss.Detail = "experience status " + strings.Join(statuses, ", ")
+	}
+	return true
+}
+
+// classifyRetrieval labels a servable product by where the deep search
+// put it: anywhere in it is below the cut, nowhere is never retrieved.
+func classifyRetrieval(miss *Miss, deepResults []dtos.ResultItem, deepErr error) {
+	if deepErr != nil {
+		miss.Cause = MissUnknown
+		miss.Detail = "deep search failed: " + deepErr.Error()
+		return
+	}
+	name := NormalizeName(miss.Product)
+	for i, item := range deepResults {
+		if item.Code == miss.ShortCode || NormalizeName(item.Name) == name {
+			miss.Cause = MissBelowCut
+			miss.ShortCode = item.Code
+			miss.DeepRank = i + 1
+			miss.DeepScore = item.Score
+			miss.Detail = fmt.Sprintf("rank %d with score %.4f", i+1, item.Score)
+			return
+		}
+	}
+	miss.Cause = MissNeverRetrieved
+	miss.Detail = fmt.Sprintf("not among the %d results of the deep search", len(deepResults))
+}
@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/homingos/campaign-svc/lib/search"
+)
+
+// Intent labels used in the golden question set, shared with the search
+// intent classifier.
+const (
+	IntentDiscovery = search.IntentDiscovery
+	IntentBrowse    = search.IntentBrowse
+	IntentFilter    = search.IntentFilter
+	IntentDirect    = search.IntentDirect
+	IntentUnknown   = "Unknown"
+)
+
+// LabeledQuestion is one <question, expected products, intent> triple.
+type LabeledQuestion struct {
+	Text     string   `json:"text"`
+	Expected []string `json:"expected"`
+	Intent   string   `json:"intent"`
+	// Grades holds the relevance of expected products by normalized name;
+	// products without a grade are relevant (1)
+	Grades map[string]int `json:"grades,omitempty"`
+}
+
+// ParseQuestions reads labeled questions in the questions.txt format:
+//
+//	question,"expected 1, expected 2",Intent
+//
+// Fields follow CSV quoting rules; the expected column is itself a
+// comma separated list of product names. The optional columns of
+// ParseGoldenCSV are read as well, relevance grades included.
+func ParseQuestions(r io.Reader) ([]LabeledQuestion, error) {
+	golden, err := ParseGoldenCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]LabeledQuestion, 0, len(golden))
+	for _, g := range golden {
+		question := LabeledQuestion{Text: g.Text, Intent: g.Intent}
+		for _, expected := range g.Expected {
+			question.Expected = append(question.Expected, expected.Name)
+			if expected.Relevance != MinRelevance {
+				if question.Grades == nil {
+					question.Grades = make(map[string]int)
+				}
+				question.Grades[NormalizeName(expected.Name)] = expected.Relevance
+			}
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// LoadQuestions parses a labeled question file from disk.
+func LoadQuestions(path string) ([]LabeledQuestion, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseQuestions(file)
+}
+
+func splitExpected(field string) []string {
+	var expected []string
+	for _, name := range strings.Split(field, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			expected = append(expected, name)
+		}
+	}
+	return expected
+}
+
+// NormalizeIntent spells a known intent label the canonical way and keeps
+// any other label as written.
+func NormalizeIntent(intent string) string {
+	intent = strings.TrimSpace(intent)
+	for _, known := range []string{IntentDiscovery, IntentBrowse, IntentFilter, IntentDirect} {
+		if strings.EqualFold(intent, known) {
+			return known
+		}
+	}
+	return intent
+}
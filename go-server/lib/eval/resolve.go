@@ -0,0 +1,403 @@
+package eval
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/homingos/campaign-svc/models"
+)
+
+// How an expected product label matched a mapped name.
+const (
+	MatchExact      = "exact"
+	MatchNormalized = "normalized"
+	MatchFuzzy      = "fuzzy"
+	MatchAmbiguous  = "ambiguous"
+	MatchUnresolved = "unresolved"
+)
+
+// Fuzzy matching thresholds: a label resolves to the most similar mapped
+// name when it scores at least FuzzyMatchScore and no other name comes
+// within FuzzyMatchMargin of it. Names scoring CandidateScore or more are
+// suggested for labels that do not resolve.
+const (
+	FuzzyMatchScore  = 0.8
+	FuzzyMatchMargin = 0.05
+	CandidateScore   = 0.5
+	maxCandidates    = 3
+	// a token matched despite a typo counts for less than an equal one
+	typoWeight = 0.9
+)
+
+var (
+	// typographic quotes and dashes are written the ASCII way
+	punctuationReplacer = strings.NewReplacer("‘", "'", "’", "'", "‚", "'", "“", `"`, "”", `"`, "„", `"`, "–", "-", "—", "-", "‐", "-")
+	// "(if within price)" style notes are not part of the name
+	annotationRe = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]`)
+)
+
+// CanonicalName reduces a product name to the words that identify it:
+// lower case, quotes and dashes unified, bracketed annotations dropped,
+// apostrophes removed and any other punctuation read as a word break, so
+// "Cotton double/king duvet cover set" and "cotton double king duvet
+// cover set" are the same name.
+func CanonicalName(name string) string {
+	name = punctuationReplacer.Replace(strings.ToLower(name))
+	name = annotationRe.ReplaceAllString(name, " ")
+	name = strings.ReplaceAll(name, "'", "")
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// nameVariants are the canonical forms a label is matched by: as written
+// and, like mapped names are, without its " - " suffix.
+func nameVariants(label string) []string {
+	variants := []string{CanonicalName(label)}
+	unified := punctuationReplacer.Replace(label)
+	if i := strings.Index(unified, " - "); i > 0 {
+		if stripped := CanonicalName(unified[:i]); stripped != "" && stripped != variants[0] {
+			variants = append(variants, stripped)
+		}
+	}
+	return variants
+}
+
+// NameCandidate is a mapped name a label was compared with.
+type NameCandidate struct {
+	Name       string   `json:"name"`
+	ShortCodes []string `json:"short_codes"`
+	Score      float64  `json:"score"`
+}
+
+// NameResolution is how one expected product label resolved against the
+// mapping. Name and ShortCodes are set when it resolved; Candidates lists
+// the closest names of a fuzzy, ambiguous or unresolved label.
+type NameResolution struct {
+	Label      string          `json:"label"`
+	Match      string          `json:"match"`
+	Name       string          `json:"name,omitempty"`
+	ShortCodes []string        `json:"short_codes,omitempty"`
+	Score      float64         `json:"score,omitempty"`
+	Candidates []NameCandidate `json:"candidates,omitempty"`
+}
+
+// Resolved reports whether the label names exactly one mapped product name.
+func (r NameResolution) Resolved() bool {
+	return r.Match == MatchExact || r.Match == MatchNormalized || r.Match == MatchFuzzy
+}
+
+type mappedName struct {
+	name       string
+	shortCodes []string
+	tokens     []string
+}
+
+// NameResolver resolves expected product labels to the names of a mapping
+// version: exactly, after normalization, or by token and edit distance
+// similarity. Campaigns sharing a name are one mapped name.
+type NameResolver struct {
+	names     []*mappedName
+	exact     map[string]*mappedName
+	canonical map[string][]*mappedName
+}
+
+// NewNameResolver indexes the names of a mapping version.
+func NewNameResolver(mapping *models.MappingData) *NameResolver {
+	r := &NameResolver{exact: make(map[string]*mappedName), canonical: make(map[string][]*mappedName)}
+	if mapping == nil {
+		return r
+	}
+	for _, m := range mapping.Mappings {
+		key := NormalizeName(m.Name)
+		if key == "" {
+			continue
+		}
+		if named, ok := r.exact[key]; ok {
+			named.shortCodes = append(named.shortCodes, m.ShortCode)
+			continue
+		}
+		canonical := CanonicalName(m.Name)
+		named := &mappedName{name: m.Name, shortCodes: []string{m.ShortCode}, tokens: strings.Fields(canonical)}
+		r.names = append(r.names, named)
+		r.exact[key] = named
+		r.canonical[canonical] = append(r.canonical[canonical], named)
+	}
+	return r
+}
+
+// Resolve matches a label exactly, then by its canonical forms, then
+// fuzzily. Several mapped names sharing the label's canonical form,
+// scoring within FuzzyMatchMargin of the best, or each containing every
+// word of the label make it ambiguous: "Cotton duvet cover set" could be
+// the single or the double/king set, however close either scores.
+func (r *NameResolver) Resolve(label string) NameResolution {
+	resolution := NameResolution{Label: label}
+	if named, ok := r.exact[NormalizeName(label)]; ok {
+		return resolvedTo(resolution, MatchExact, named, 1)
+	}
+
+	variants := nameVariants(label)
+	for _, variant := range variants {
+		switch named := r.canonical[variant]; len(named) {
+		case 0:
+			continue
+		case 1:
+			return resolvedTo(resolution, MatchNormalized, named[0], 1)
+		default:
+			resolution.Match = MatchAmbiguous
+			for _, n := range named {
+				resolution.Candidates = append(resolution.Candidates, candidate(n, 1))
+			}
+			return resolution
+		}
+	}
+
+	var scored, containing []NameCandidate
+	var best *mappedName
+	bestScore := 0.0
+	for _, named := range r.names {
+		score := 0.0
+		contained := false
+		for _, variant := range variants {
+			tokens := strings.Fields(variant)
+			if s := tokenSimilarity(tokens, named.tokens); s > score {
+				score = s
+			}
+			contained = contained || containsTokens(named.tokens, tokens)
+		}
+		if score >= CandidateScore {
+			scored = append(scored, candidate(named, score))
+		}
+		if contained {
+			containing = append(containing, candidate(named, score))
+		}
+		if score > bestScore {
+			best, bestScore = named, score
+		}
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+
+	resolution.Match = MatchUnresolved
+	if bestScore >= FuzzyMatchScore {
+		resolution.Match = MatchFuzzy
+		if len(scored) > 1 && scored[1].Score >= FuzzyMatchScore && bestScore-scored[1].Score < FuzzyMatchMargin {
+			resolution.Match = MatchAmbiguous
+		}
+	}
+	if len(containing) > 1 {
+		resolution.Match = MatchAmbiguous
+		sort.SliceStable(containing, func(i, j int) bool { return containing[i].Score > containing[j].Score })
+		scored = containing
+	}
+	if resolution.Match == MatchFuzzy {
+		resolution = resolvedTo(resolution, MatchFuzzy, best, bestScore)
+	}
+	if len(scored) > maxCandidates {
+		scored = scored[:maxCandidates]
+	}
+	resolution.Candidates = scored
+	return resolution
+}
+
+func resolvedTo(resolution NameResolution, match string, named *mappedName, score float64) NameResolution {
+	resolution.Match = match
+	resolution.Name = named.name
+	resolution.ShortCodes = named.shortCodes
+	resolution.Score = score
+	return resolution
+}
+
+func candidate(named *mappedName, score float64) NameCandidate {
+	return NameCandidate{Name: named.name, ShortCodes: named.shortCodes, Score: score}
+}
+
+// tokenSimilarity is the Dice coefficient of two token lists, where a
+// token matches an unused equal token of the other list or, counting
+// typoWeight, the closest one within the edit distance its length allows.
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	used := make([]bool, len(b))
+	matched := 0.0
+	for _, token := range a {
+		match, distance := -1, 0
+		for j, other := range b {
+			if used[j] {
+				continue
+			}
+			if token == other {
+				match, distance = j, 0
+				break
+			}
+			if d := editDistance(token, other); d <= allowedEdits(token, other) && (match < 0 || d < distance) {
+				match, distance = j, d
+			}
+		}
+		if match < 0 {
+			continue
+		}
+		used[match] = true
+		if distance == 0 {
+			matched++
+		} else {
+			matched += typoWeight
+		}
+	}
+	return 2 * matched / float64(len(a)+len(b))
+}
+
+// allowedEdits tolerates a typo in words of four letters or more and two
+// in words of eight or more; shorter words must match exactly.
+func allowedEdits(a, b string) int {
+	n := len([]rune(a))
+	if m := len([]rune(b)); m < n {
+		n = m
+	}
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// editDistance is the Levenshtein distance between two words.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// LabelIssue is a label that did not resolve exactly, with the questions
+// that expect it.
+type LabelIssue struct {
+	NameResolution
+	Questions []string `json:"questions"`
+}
+
+// LabelReport is how the expected product labels of a question set
+// resolved against a mapping version. Fuzzy labels resolved but are worth
+// a look; ambiguous and unresolved labels are scored as written and can
+// never be hit.
+type LabelReport struct {
+	MappingVersion int          `json:"mapping_version"`
+	Labels         int          `json:"labels"`
+	Exact          int          `json:"exact"`
+	Normalized     []LabelIssue `json:"normalized"`
+	Fuzzy          []LabelIssue `json:"fuzzy"`
+	Ambiguous      []LabelIssue `json:"ambiguous"`
+	Unresolved     []LabelIssue `json:"unresolved"`
+}
+
+// Clean reports whether every label resolved to one mapped name.
+func (r *LabelReport) Clean() bool {
+	return len(r.Ambiguous) == 0 && len(r.Unresolved) == 0
+}
+
+// ResolveLabels resolves the expected products of every question and
+// returns the questions with resolved labels renamed to their mapped
+// names, their grades carried over, along with the report of every label.
+// Labels that do not resolve are kept as written.
+func ResolveLabels(questions []LabeledQuestion, resolver *NameResolver, mappingVersion int) ([]LabeledQuestion, *LabelReport) {
+	report := &LabelReport{
+		MappingVersion: mappingVersion,
+		Normalized:     []LabelIssue{},
+		Fuzzy:          []LabelIssue{},
+		Ambiguous:      []LabelIssue{},
+		Unresolved:     []LabelIssue{},
+	}
+	resolutions := make(map[string]NameResolution)
+	issues := make(map[string]*LabelIssue)
+	var order []string
+
+	resolved := make([]LabeledQuestion, 0, len(questions))
+	for _, question := range questions {
+		renamed := LabeledQuestion{Text: question.Text, Intent: question.Intent, Expected: make([]string, 0, len(question.Expected))}
+		seen := make(map[string]bool, len(question.Expected))
+		for _, label := range question.Expected {
+			key := NormalizeName(label)
+			resolution, ok := resolutions[key]
+			if !ok {
+				resolution = resolver.Resolve(label)
+				resolutions[key] = resolution
+				if resolution.Match == MatchExact {
+					report.Exact++
+				} else {
+					issues[key] = &LabelIssue{NameResolution: resolution}
+					order = append(order, key)
+				}
+			}
+			if issue, ok := issues[key]; ok {
+				issue.Questions = append(issue.Questions, question.Text)
+			}
+
+			name := label
+			if resolution.Resolved() {
+				name = resolution.Name
+			}
+			nameKey := NormalizeName(name)
+			if grade, ok := question.Grades[key]; ok {
+				if renamed.Grades == nil {
+					renamed.Grades = make(map[string]int)
+				}
+				if grade > renamed.Grades[nameKey] {
+					renamed.Grades[nameKey] = grade
+				}
+			}
+			// two labels of one question resolving to the same name
+			// expect it once
+			if !seen[nameKey] {
+				seen[nameKey] = true
+				renamed.Expected = append(renamed.Expected, name)
+			}
+		}
+		resolved = append(resolved, renamed)
+	}
+	report.Labels = len(resolutions)
+
+	sort.Strings(order)
+	for _, key := range order {
+		issue := *issues[key]
+		switch issue.Match {
+		case MatchNormalized:
+			report.Normalized = append(report.Normalized, issue)
+		case MatchFuzzy:
+			report.Fuzzy = append(report.Fuzzy, issue)
+		case MatchAmbiguous:
+			report.Ambiguous = append(report.Ambiguous, issue)
+		default:
+			report.Unresolved = append(report.Unresolved, issue)
+		}
+	}
+	return resolved, report
+}
@@ -0,0 +1,40 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/homingos/campaign-svc/models"
+)
+
+func TestNameResolverResolve(t *testing.T) {
+	resolver := NewNameResolver(&models.MappingData{Mappings: []models.ShortCodeMapping{
+		{ShortCode: "a", Name: "Cotton single duvet cover set"},
+		{ShortCode: "b", Name: "Cotton double/king duvet cover set"},
+		{ShortCode: "c", Name: "Linen Fitted Sheet"},
+		{ShortCode: "d", Name: "Women's relaxed jeans"},
+	}})
+	tests := []struct {
+		label string
+		match string
+		name  string
+	}{
+		{label: "Linen Fitted Sheet", match: MatchExact, name: "Linen Fitted Sheet"},
+		{label: "cotton double king duvet cover set", match: MatchNormalized, name: "Cotton double/king duvet cover set"},
+		{label: "Womens relaxed jeans - W123", match: MatchNormalized, name: "Women's relaxed jeans"},
+		{label: "Linen Fited Sheet", match: MatchFuzzy, name: "Linen Fitted Sheet"},
+		{label: "Cotton duvet cover set", match: MatchAmbiguous},
+		{label: "Cotton single duvet cover", match: MatchFuzzy, name: "Cotton single duvet cover set"},
+		{label: "Velvet armchair", match: MatchUnresolved},
+	}
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			resolution := resolver.Resolve(test.label)
+			if resolution.Match != test.match || resolution.Name != test.name {
+				t.Errorf("Resolve = %s %q, want %s %q", resolution.Match, resolution.Name, test.match, test.name)
+			}
+			if test.match == MatchAmbiguous && len(resolution.Candidates) != 2 {
+				t.Errorf("candidates = %+v, want both duvet cover sets", resolution.Candidates)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package eval
+
+import (
+	"context"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+// Searcher runs a single query through the search path under evaluation and
+// returns the ranked results.
+type Searcher func(ctx context.Context, text string) ([]dtos.ResultItem, error)
+
+// Evaluate runs every labeled question with the given searcher and returns
+// the aggregated report. A failed search is recorded on the question and
+// scored as a complete miss instead of aborting the run.
+func Evaluate(ctx context.Context, siteCode string, questions []LabeledQuestion, k int, search Searcher) *Report {
+	results := make([]QuestionResult, 0, len(questions))
+	for _, question := range questions {
+		items, err := search(ctx, question.Text)
+		if err != nil {
+			result := ScoreQuestion(question, nil, nil, k)
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		names := make([]string, 0, len(items))
+		scores := make([]float32, 0, len(items))
+		for _, item := range items {
+			names = append(names, item.Name)
+			scores = append(scores, item.Score)
+		}
+		results = append(results, ScoreQuestion(question, names, scores, k))
+	}
+	return Summarize(siteCode, k, results)
+}
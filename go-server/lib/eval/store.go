@@ -0,0 +1,395 @@
+package eval
+
+import (
+	"container/list"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+	"github.com/homingos/campaign-svc/types/consts"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	manifestFile    = "manifest.json"
+	resultsJSONFile = "results.json"
+	resultsCSVFile  = "results.csv"
+	reportJSONFile  = "report.json"
+	reportHTMLFile  = "report.html"
+	allCampaignsKey = "all_campaigns"
+)
+
+// ErrRunNotFound is returned when a run ID has no manifest on disk.
+var ErrRunNotFound = errors.New("evaluation run not found")
+
+// ErrReportNotFound is returned when a run has no scored report, because
+// it recorded results without being evaluated.
+var ErrReportNotFound = errors.New("evaluation report not found")
+
+// RunConfig records the settings a run was produced with.
+type RunConfig struct {
+	K              int    `json:"k,omitempty"`
+	QuestionsFile  string `json:"questions_file,omitempty"`
+	Source         string `json:"source,omitempty"`
+	MappingVersion int    `json:"mapping_version,omitempty"`
+	Mode           string `json:"mode,omitempty"`
+	Reranker       string `json:"reranker,omitempty"`
+	// GoldenSetVersion is the golden set version the questions came from,
+	// when they did not come from a file or the request body
+	GoldenSetVersion int `json:"golden_set_version,omitempty"`
+}
+
+// RunManifest describes an evaluation run and the mapping it was run against.
+type RunManifest struct {
+	RunID     string              `json:"run_id"`
+	SiteCode  string              `json:"site_code"`
+	CreatedAt string              `json:"created_at"`
+	OutputDir string              `json:"output_dir"`
+	Config    RunConfig           `json:"config"`
+	Mapping   *models.MappingData `json:"mapping"`
+}
+
+// RunEntry is the latest result recorded for one question of a run.
+type RunEntry struct {
+	Question  string            `json:"question"`
+	Results   []dtos.ResultItem `json:"results"`
+	UpdatedAt string            `json:"updated_at"`
+}
+
+// Run is an evaluation run with its recorded entries, one per question.
+type Run struct {
+	Manifest RunManifest `json:"manifest"`
+	Entries  []RunEntry  `json:"entries"`
+	// LatestMappingVersion is set when a newer mapping version than the one
+	// the run is pinned to has been announced for its site code.
+	LatestMappingVersion int `json:"latest_mapping_version,omitempty"`
+
+	mu    sync.RWMutex
+	index map[string]int
+}
+
+// Snapshot returns a copy of the run that is safe to serialize while
+// writes continue.
+func (r *Run) Snapshot() *Run {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]RunEntry, len(r.Entries))
+	copy(entries, r.Entries)
+	return &Run{Manifest: r.Manifest, Entries: entries}
+}
+
+func (r *Run) upsert(question string, results []dtos.ResultItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if results == nil {
+		results = []dtos.ResultItem{}
+	}
+	entry := RunEntry{
+		Question:  question,
+		Results:   results,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+	}
+	key := questionKey(question)
+	if i, ok := r.index[key]; ok {
+		r.Entries[i] = entry
+		return
+	}
+	r.index[key] = len(r.Entries)
+	r.Entries = append(r.Entries, entry)
+}
+
+type writeRequest struct {
+	run      *Run
+	question string
+	results  []dtos.ResultItem
+	done     chan error
+}
+
+// loadedRun is a run held in memory; pending counts the writes in flight,
+// which keep it from being evicted.
+type loadedRun struct {
+	run     *Run
+	pending int
+}
+
+// RunStore keeps every run in its own directory under a base directory.
+// All result writes go through a single writer goroutine so concurrent
+// requests never interleave partial files. Only the most recently used
+// runs stay in memory; the others are read back from disk when needed.
+type RunStore struct {
+	dir      string
+	mu       sync.Mutex
+	order    *list.List
+	runs     map[string]*list.Element
+	mappings map[string]int
+	writes   chan writeRequest
+}
+
+func NewRunStore(dir string) *RunStore {
+	store := &RunStore{
+		dir:      dir,
+		order:    list.New(),
+		runs:     make(map[string]*list.Element),
+		mappings: make(map[string]int),
+		writes:   make(chan writeRequest),
+	}
+	go store.writer()
+	return store
+}
+
+func (s *RunStore) writer() {
+	for req := range s.writes {
+		req.run.upsert(req.question, req.results)
+		req.done <- s.flush(req.run)
+	}
+}
+
+// CreateRun allocates a run ID, creates its output directory and writes
+// the manifest.
+func (s *RunStore) CreateRun(siteCode string, mapping *models.MappingData, config RunConfig) (*RunManifest, error) {
+	runID := primitive.NewObjectID().Hex()
+	runDir := filepath.Join(s.dir, runID)
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return nil, err
+	}
+
+	run := &Run{
+		Manifest: RunManifest{
+			RunID:     runID,
+			SiteCode:  siteCode,
+			CreatedAt: time.Now().Format(time.RFC3339),
+			OutputDir: runDir,
+			Config:    config,
+			Mapping:   mapping,
+		},
+		Entries: []RunEntry{},
+		index:   make(map[string]int),
+	}
+	if err := writeJSONFile(filepath.Join(runDir, manifestFile), run.Manifest); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.keep(run)
+	s.mu.Unlock()
+	return &run.Manifest, nil
+}
+
+// MappingUpdated records that a site code's mapping moved to version, so
+// runs pinned to an older version can report that the catalogue changed.
+func (s *RunStore) MappingUpdated(siteCode string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version > s.mappings[siteCode] {
+		s.mappings[siteCode] = version
+	}
+}
+
+// LatestMappingVersion returns the newest mapping version announced for a
+// site code, or 0 when none was seen.
+func (s *RunStore) LatestMappingVersion(siteCode string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mappings[siteCode]
+}
+
+// GetRun returns a run, loading it from disk when it was created by an
+// earlier process.
+func (s *RunStore) GetRun(runID string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loaded, err := s.load(runID)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.run, nil
+}
+
+// load returns a run from memory or disk and marks it most recently used.
+// The caller holds s.mu.
+func (s *RunStore) load(runID string) (*loadedRun, error) {
+	if element, ok := s.runs[runID]; ok {
+		s.order.MoveToFront(element)
+		return element.Value.(*loadedRun), nil
+	}
+	// run IDs become directory names, never let them escape the base dir
+	if runID == "" || filepath.Base(runID) != runID {
+		return nil, ErrRunNotFound
+	}
+
+	runDir := filepath.Join(s.dir, runID)
+	run := &Run{Entries: []RunEntry{}, index: make(map[string]int)}
+	if err := readJSONFile(filepath.Join(runDir, manifestFile), &run.Manifest); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+	if err := readJSONFile(filepath.Join(runDir, resultsJSONFile), &run.Entries); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for i, entry := range run.Entries {
+		run.index[questionKey(entry.Question)] = i
+	}
+	return s.keep(run), nil
+}
+
+// keep adds a run to memory and evicts the least recently used runs past
+// the limit, skipping those with writes in flight: a run read back while
+// a write to its evicted copy is pending would miss that write. The caller
+// holds s.mu.
+func (s *RunStore) keep(run *Run) *loadedRun {
+	loaded := &loadedRun{run: run}
+	s.runs[run.Manifest.RunID] = s.order.PushFront(loaded)
+	for element := s.order.Back(); element != s.order.Front() && s.order.Len() > consts.MaxCachedEvalRuns; {
+		previous := element.Prev()
+		if evicted := element.Value.(*loadedRun); evicted.pending == 0 {
+			s.order.Remove(element)
+			delete(s.runs, evicted.run.Manifest.RunID)
+		}
+		element = previous
+	}
+	return loaded
+}
+
+// Record upserts the results for a question into a run and rewrites the
+// run's output files.
+func (s *RunStore) Record(runID string, question string, results []dtos.ResultItem) error {
+	s.mu.Lock()
+	loaded, err := s.load(runID)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	loaded.pending++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		loaded.pending--
+		s.mu.Unlock()
+	}()
+
+	done := make(chan error, 1)
+	s.writes <- writeRequest{run: loaded.run, question: question, results: results, done: done}
+	return <-done
+}
+
+// SaveReport writes the scored report of a run next to its results, as
+// JSON and as a self-contained HTML page.
+func (s *RunStore) SaveReport(runID string, report *Report) error {
+	run, err := s.GetRun(runID)
+	if err != nil {
+		return err
+	}
+	runDir := filepath.Join(s.dir, run.Manifest.RunID)
+	if err := writeJSONFile(filepath.Join(runDir, reportJSONFile), report); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(runDir, reportHTMLFile), func(file *os.File) error {
+		return WriteHTMLReport(file, report)
+	})
+}
+
+// GetReport returns the scored report of a run.
+func (s *RunStore) GetReport(runID string) (*Report, error) {
+	run, err := s.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := readJSONFile(filepath.Join(s.dir, run.Manifest.RunID, reportJSONFile), &report); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (s *RunStore) flush(run *Run) error {
+	snapshot := run.Snapshot()
+	runDir := filepath.Join(s.dir, snapshot.Manifest.RunID)
+	if err := writeJSONFile(filepath.Join(runDir, resultsJSONFile), snapshot.Entries); err != nil {
+		return err
+	}
+	return writeResultsCSV(filepath.Join(runDir, resultsCSVFile), snapshot.Entries)
+}
+
+func questionKey(question string) string {
+	if question == "" {
+		return allCampaignsKey
+	}
+	return NormalizeName(question)
+}
+
+func writeResultsCSV(path string, entries []RunEntry) error {
+	return writeFileAtomic(path, func(file *os.File) error {
+		writer := csv.NewWriter(file)
+		writer.Write([]string{"Topic", "Codes", "Names", "Scores", "Confidences"})
+		for _, entry := range entries {
+			var codes, names, scores, confidences []string
+			for _, r := range entry.Results {
+				codes = append(codes, r.Code)
+				names = append(names, r.Name)
+				scores = append(scores, fmt.Sprintf("%.4f", r.Score))
+				// uncalibrated results leave their confidence blank
+				confidence := ""
+				if r.Confidence != nil {
+					confidence = fmt.Sprintf("%.4f", *r.Confidence)
+				}
+				confidences = append(confidences, confidence)
+			}
+			writer.Write([]string{
+				entry.Question,
+				strings.Join(codes, ","),
+				strings.Join(names, ","),
+				strings.Join(scores, ","),
+				strings.Join(confidences, ","),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+func writeJSONFile(path string, data interface{}) error {
+	return writeFileAtomic(path, func(file *os.File) error {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	})
+}
+
+func readJSONFile(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// writeFileAtomic writes to a temporary file and renames it into place so
+// readers never observe a half written file.
+func writeFileAtomic(path string, write func(file *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
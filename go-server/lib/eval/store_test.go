@@ -0,0 +1,38 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/types/consts"
+)
+
+func TestRunStoreEvictsToDisk(t *testing.T) {
+	store := NewRunStore(t.TempDir())
+	var runIDs []string
+	for i := 0; i < consts.MaxCachedEvalRuns+5; i++ {
+		manifest, err := store.CreateRun("site", nil, RunConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Record(manifest.RunID, "red sofa", []dtos.ResultItem{{Code: "a", Name: "Red Sofa"}}); err != nil {
+			t.Fatal(err)
+		}
+		runIDs = append(runIDs, manifest.RunID)
+	}
+	if held := len(store.runs); held != consts.MaxCachedEvalRuns {
+		t.Errorf("runs in memory = %d, want %d", held, consts.MaxCachedEvalRuns)
+	}
+
+	// the first run was evicted and is read back with its results
+	run, err := store.GetRun(runIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := run.Snapshot().Entries; len(entries) != 1 || entries[0].Results[0].Code != "a" {
+		t.Errorf("entries = %+v, want the recorded result", entries)
+	}
+	if _, err := store.GetRun("missing"); err != ErrRunNotFound {
+		t.Errorf("GetRun(missing) error = %v, want ErrRunNotFound", err)
+	}
+}
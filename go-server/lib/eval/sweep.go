@@ -0,0 +1,234 @@
+package eval
+
+import (
+	"math"
+	"sort"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+// OverallIntent labels the metrics of all questions in a sweep.
+const OverallIntent = "Overall"
+
+// QuestionCandidates is a labeled question with the candidates a search
+// returned for it, best first, before any cutoff.
+type QuestionCandidates struct {
+	Question LabeledQuestion
+	Results  []dtos.ResultItem
+	Error    string
+}
+
+// CutoffMetrics scores the results kept by a cutoff. Counts are summed
+// over the questions, so precision is the share of kept results that were
+// expected and recall the share of expected products that were kept.
+type CutoffMetrics struct {
+	Questions int     `json:"questions"`
+	Returned  int     `json:"returned"`
+	Expected  int     `json:"expected"`
+	Hits      int     `json:"hits"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// SweepPoint is one top_k and min_score cutoff and how it scores, overall
+// and per intent.
+type SweepPoint struct {
+	TopK     int                      `json:"top_k"`
+	MinScore float64                  `json:"min_score"`
+	Overall  CutoffMetrics            `json:"overall"`
+	ByIntent map[string]CutoffMetrics `json:"by_intent"`
+}
+
+// Recommendation is the cutoff with the best F1 for a group of questions.
+type Recommendation struct {
+	TopK      int     `json:"top_k"`
+	MinScore  float64 `json:"min_score"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// SweepReport is every cutoff of a grid replayed over one site code's
+// candidates, with the recommended cutoff overall and per intent.
+type SweepReport struct {
+	SiteCode          string                    `json:"site_code"`
+	Mode              string                    `json:"mode,omitempty"`
+	Reranker          string                    `json:"reranker,omitempty"`
+	Pool              int                       `json:"pool"`
+	Questions         int                       `json:"questions"`
+	Failed            int                       `json:"failed"`
+	Intents           []string                  `json:"intents"`
+	Points            []SweepPoint              `json:"points"`
+	Recommended       Recommendation            `json:"recommended"`
+	RecommendedIntent map[string]Recommendation `json:"recommended_by_intent"`
+}
+
+// Sweep replays every top_k × min_score cutoff over candidates retrieved
+// once with a large pool. Like the search, a cutoff applies min_score to
+// the vector score of the candidates the vector search retrieved, whatever
+// their final score after fusion or reranking, and then keeps the first
+// top_k. Candidates only the lexical index or the intent routing found are
+// never cut by min_score; a hybrid candidate both found is, so hybrid
+// recommendations lean strict. The recommendation maximizes F1; ties go to
+// the smaller top_k and then the higher min_score, the cheaper and
+// stricter setting.
+func Sweep(siteCode string, candidates []QuestionCandidates, topKs []int, minScores []float64) *SweepReport {
+	report := &SweepReport{
+		SiteCode:          siteCode,
+		Questions:         len(candidates),
+		Points:            make([]SweepPoint, 0, len(topKs)*len(minScores)),
+		RecommendedIntent: make(map[string]Recommendation),
+	}
+	intents := make(map[string]bool)
+	for _, question := range candidates {
+		if !intents[question.Question.Intent] {
+			intents[question.Question.Intent] = true
+			report.Intents = append(report.Intents, question.Question.Intent)
+		}
+		if question.Error != "" {
+			report.Failed++
+		}
+		if len(question.Results) > report.Pool {
+			report.Pool = len(question.Results)
+		}
+	}
+
+	sort.Strings(report.Intents)
+	topKs = sortedInts(topKs)
+	minScores = sortedFloats(minScores)
+	for _, topK := range topKs {
+		for _, minScore := range minScores {
+			point := SweepPoint{TopK: topK, MinScore: minScore, ByIntent: make(map[string]CutoffMetrics)}
+			for _, question := range candidates {
+				returned, expected, hits := cutoffCounts(question, topK, minScore)
+				point.Overall.add(returned, expected, hits)
+				metrics := point.ByIntent[question.Question.Intent]
+				metrics.add(returned, expected, hits)
+				point.ByIntent[question.Question.Intent] = metrics
+			}
+			point.Overall.finish()
+			for intent, metrics := range point.ByIntent {
+				metrics.finish()
+				point.ByIntent[intent] = metrics
+			}
+			report.Points = append(report.Points, point)
+
+			report.Recommended = better(report.Recommended, point.Overall, point, len(report.Points) == 1)
+			for intent, metrics := range point.ByIntent {
+				current, ok := report.RecommendedIntent[intent]
+				report.RecommendedIntent[intent] = better(current, metrics, point, !ok)
+			}
+		}
+	}
+	return report
+}
+
+// ScoreGrid proposes min_score cutoffs from the vector scores a search
+// returned: zero and every decile of the observed scores, so the grid
+// suits the embedding model's scale.
+func ScoreGrid(candidates []QuestionCandidates) []float64 {
+	var scores []float64
+	for _, question := range candidates {
+		for _, result := range question.Results {
+			if result.VectorScore != nil {
+				scores = append(scores, float64(*result.VectorScore))
+			}
+		}
+	}
+	grid := []float64{0}
+	if len(scores) == 0 {
+		return grid
+	}
+	sort.Float64s(scores)
+	for decile := 1; decile < 10; decile++ {
+		score := scores[decile*(len(scores)-1)/10]
+		// four decimals are enough to copy into config
+		grid = append(grid, math.Floor(score*10000)/10000)
+	}
+	return sortedFloats(grid)
+}
+
+// cutoffCounts applies a cutoff to one question's candidates and counts
+// what it kept. A product returned twice counts once.
+func cutoffCounts(question QuestionCandidates, topK int, minScore float64) (int, int, int) {
+	expected := make(map[string]bool, len(question.Question.Expected))
+	for _, name := range question.Question.Expected {
+		expected[NormalizeName(name)] = true
+	}
+	found := make(map[string]bool)
+	returned := 0
+	for _, result := range question.Results {
+		if returned >= topK {
+			break
+		}
+		if result.VectorScore != nil && float64(*result.VectorScore) < minScore {
+			continue
+		}
+		returned++
+		if key := NormalizeName(result.Name); expected[key] {
+			found[key] = true
+		}
+	}
+	return returned, len(expected), len(found)
+}
+
+func (m *CutoffMetrics) add(returned int, expected int, hits int) {
+	m.Questions++
+	m.Returned += returned
+	m.Expected += expected
+	m.Hits += hits
+}
+
+func (m *CutoffMetrics) finish() {
+	if m.Returned > 0 {
+		m.Precision = float64(m.Hits) / float64(m.Returned)
+	}
+	if m.Expected > 0 {
+		m.Recall = float64(m.Hits) / float64(m.Expected)
+	}
+	if m.Precision+m.Recall > 0 {
+		m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+	}
+}
+
+// better returns the recommendation of point when its metrics beat the
+// current one. Points arrive by ascending top_k, then ascending min_score,
+// so only a strictly better F1 replaces a smaller top_k and an equal F1
+// moves to the higher min_score.
+func better(current Recommendation, metrics CutoffMetrics, point SweepPoint, first bool) Recommendation {
+	if !first && (metrics.F1 < current.F1 || (metrics.F1 == current.F1 && point.TopK != current.TopK)) {
+		return current
+	}
+	return Recommendation{
+		TopK:      point.TopK,
+		MinScore:  point.MinScore,
+		Precision: metrics.Precision,
+		Recall:    metrics.Recall,
+		F1:        metrics.F1,
+	}
+}
+
+func sortedInts(values []int) []int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	deduped := sorted[:0]
+	for i, value := range sorted {
+		if i == 0 || value != sorted[i-1] {
+			deduped = append(deduped, value)
+		}
+	}
+	return deduped
+}
+
+func sortedFloats(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	deduped := sorted[:0]
+	for i, value := range sorted {
+		if i == 0 || value != sorted[i-1] {
+			deduped = append(deduped, value)
+		}
+	}
+	return deduped
+}
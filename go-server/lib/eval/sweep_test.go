@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+func TestCutoffCounts(t *testing.T) {
+	// reranked: the final scores no longer follow the vector scores
+	question := QuestionCandidates{
+		Question: LabeledQuestion{Text: "red sofa", Expected: []string{"Red Sofa", "Blue Sofa"}, Intent: "Discovery"},
+		Results: []dtos.ResultItem{
+			{Code: "a", Name: "Red Sofa", Score: 0.9, VectorScore: score(0.42)},
+			{Code: "b", Name: "Green Chair", Score: 0.8, VectorScore: score(0.71)},
+			{Code: "c", Name: "Blue Sofa", Score: 0.3},
+			{Code: "d", Name: "red sofa", Score: 0.2, VectorScore: score(0.66)},
+		},
+	}
+	tests := []struct {
+		name     string
+		topK     int
+		minScore float64
+		returned int
+		hits     int
+	}{
+		{name: "no cutoff", topK: 10, minScore: 0, returned: 4, hits: 2},
+		{name: "top_k only", topK: 2, minScore: 0, returned: 2, hits: 1},
+		{name: "min_score on vector scores", topK: 10, minScore: 0.5, returned: 3, hits: 2},
+		{name: "min_score then top_k", topK: 1, minScore: 0.5, returned: 1, hits: 0},
+		{name: "lexical candidates are never cut", topK: 10, minScore: 0.8, returned: 1, hits: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			returned, expected, hits := cutoffCounts(question, test.topK, test.minScore)
+			if returned != test.returned || expected != 2 || hits != test.hits {
+				t.Errorf("cutoffCounts = %d, %d, %d, want %d, 2, %d", returned, expected, hits, test.returned, test.hits)
+			}
+		})
+	}
+}
+
+func TestSweepRecommendation(t *testing.T) {
+	candidates := []QuestionCandidates{
+		{
+			Question: LabeledQuestion{Text: "sofa", Expected: []string{"Sofa"}, Intent: "Direct"},
+			Results: []dtos.ResultItem{
+				{Code: "a", Name: "Sofa", Score: 1, VectorScore: score(0.9)},
+				{Code: "b", Name: "Chair", Score: 0.5, VectorScore: score(0.4)},
+			},
+		},
+		{
+			Question: LabeledQuestion{Text: "seating", Expected: []string{"Sofa", "Chair"}, Intent: "Browse"},
+			Results: []dtos.ResultItem{
+				{Code: "a", Name: "Sofa", Score: 1, VectorScore: score(0.8)},
+				{Code: "b", Name: "Chair", Score: 0.9, VectorScore: score(0.7)},
+				{Code: "c", Name: "Lamp", Score: 0.8, VectorScore: score(0.3)},
+			},
+		},
+		{
+			Question: LabeledQuestion{Text: "table", Expected: []string{"Table"}, Intent: "Direct"},
+			Error:    "search failed",
+		},
+	}
+	report := Sweep("site", candidates, []int{3, 1, 3}, []float64{0.5, 0})
+
+	if report.Questions != 3 || report.Failed != 1 || report.Pool != 3 {
+		t.Errorf("questions, failed, pool = %d, %d, %d, want 3, 1, 3", report.Questions, report.Failed, report.Pool)
+	}
+	if !reflect.DeepEqual(report.Intents, []string{"Browse", "Direct"}) {
+		t.Errorf("intents = %v", report.Intents)
+	}
+	if len(report.Points) != 4 {
+		t.Fatalf("points = %d, want the deduplicated 2 × 2 grid", len(report.Points))
+	}
+	want := Recommendation{TopK: 3, MinScore: 0.5, Precision: 1, Recall: 0.75, F1: 6.0 / 7.0}
+	if got := report.Recommended; got.TopK != want.TopK || got.MinScore != want.MinScore || !near(got.F1, want.F1) || !near(got.Recall, want.Recall) {
+		t.Errorf("recommended = %+v, want %+v", got, want)
+	}
+	// top_k 1 and 3 tie on the Direct questions, the smaller one wins
+	if got := report.RecommendedIntent["Direct"]; got.TopK != 1 || got.MinScore != 0.5 {
+		t.Errorf("recommended for Direct = %+v, want top_k 1 and min_score 0.5", got)
+	}
+}
+
+func TestScoreGrid(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []dtos.ResultItem
+		want    []float64
+	}{
+		{name: "no vector scores", results: []dtos.ResultItem{{Score: 3}}, want: []float64{0}},
+		{
+			name: "deciles of vector scores only",
+			results: []dtos.ResultItem{
+				{Score: 12, VectorScore: score(0.5)},
+				{Score: 0.9},
+				{Score: 7, VectorScore: score(0.25)},
+			},
+			want: []float64{0, 0.25},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ScoreGrid([]QuestionCandidates{{Results: test.results}})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ScoreGrid = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func score(value float32) *float32 {
+	return &value
+}
+
+func near(a float64, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,260 @@
+package milvus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// Kind is the type of value a filterable field holds.
+type Kind int
+
+const (
+	KindString Kind = iota + 1
+	KindInt
+	KindFloat
+	KindBool
+)
+
+// Schema maps the fields a collection can be filtered on to their kind.
+type Schema map[string]Kind
+
+// SchemaOf returns the scalar fields of a collection schema. Vector, JSON
+// and array fields cannot be filtered on and are left out.
+func SchemaOf(schema *entity.Schema) Schema {
+	fields := make(Schema)
+	if schema == nil {
+		return fields
+	}
+	for _, field := range schema.Fields {
+		switch field.DataType {
+		case entity.FieldTypeString, entity.FieldTypeVarChar:
+			fields[field.Name] = KindString
+		case entity.FieldTypeInt8, entity.FieldTypeInt16, entity.FieldTypeInt32, entity.FieldTypeInt64:
+			fields[field.Name] = KindInt
+		case entity.FieldTypeFloat, entity.FieldTypeDouble:
+			fields[field.Name] = KindFloat
+		case entity.FieldTypeBool:
+			fields[field.Name] = KindBool
+		}
+	}
+	return fields
+}
+
+// Has reports whether a field can be filtered on.
+func (s Schema) Has(field string) bool {
+	_, ok := s[field]
+	return ok
+}
+
+const (
+	opEq    = "=="
+	opIn    = "in"
+	opRange = "range"
+	opAnd   = "&&"
+	opOr    = "||"
+)
+
+// Filter is a boolean expression over the fields of a collection, built
+// with Eq, In, Range, And and Or. Expr renders it for one schema, so
+// field names are checked against the collection and values are quoted
+// for their field; nothing from a request is pasted into the expression
+// as is. The zero Filter matches everything.
+type Filter struct {
+	op       string
+	field    string
+	values   []interface{}
+	min      *float64
+	max      *float64
+	children []Filter
+}
+
+// Eq matches documents whose field equals value.
+func Eq(field string, value interface{}) Filter {
+	return Filter{op: opEq, field: field, values: []interface{}{value}}
+}
+
+// In matches documents whose field equals any of values.
+func In(field string, values ...interface{}) Filter {
+	return Filter{op: opIn, field: field, values: values}
+}
+
+// Range matches documents whose numeric field lies between min and max,
+// both inclusive. A nil bound leaves that side open.
+func Range(field string, min *float64, max *float64) Filter {
+	return Filter{op: opRange, field: field, min: min, max: max}
+}
+
+// And matches documents matching every filter. Empty filters are skipped.
+func And(filters ...Filter) Filter {
+	return Filter{op: opAnd, children: filters}
+}
+
+// Or matches documents matching any filter, so an empty filter among them
+// makes it match everything, as does Or with no filters.
+func Or(filters ...Filter) Filter {
+	return Filter{op: opOr, children: filters}
+}
+
+// IsEmpty reports whether the filter matches everything.
+func (f Filter) IsEmpty() bool {
+	switch f.op {
+	case "":
+		return true
+	case opRange:
+		return f.min == nil && f.max == nil
+	case opAnd:
+		for _, child := range f.children {
+			if !child.IsEmpty() {
+				return false
+			}
+		}
+		return true
+	case opOr:
+		if len(f.children) == 0 {
+			return true
+		}
+		for _, child := range f.children {
+			if child.IsEmpty() {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Expr renders the filter as a Milvus boolean expression. It fails on a
+// field the schema does not have or a value of the wrong kind; an empty
+// filter renders as "".
+func (f Filter) Expr(schema Schema) (string, error) {
+	if f.IsEmpty() {
+		return "", nil
+	}
+	switch f.op {
+	case opAnd, opOr:
+		var parts []string
+		for _, child := range f.children {
+			// an empty child of an Or makes the whole Or empty, which
+			// returned above
+			if f.op == opAnd && child.IsEmpty() {
+				continue
+			}
+			expr, err := child.Expr(schema)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, expr)
+		}
+		if len(parts) == 1 {
+			return parts[0], nil
+		}
+		return "(" + strings.Join(parts, " "+f.op+" ") + ")", nil
+	}
+
+	kind, ok := schema[f.field]
+	if !ok {
+		return "", fmt.Errorf("unknown filter field %q, expected one of %s", f.field, strings.Join(schema.fields(), ", "))
+	}
+	switch f.op {
+	case opEq:
+		value, err := literal(f.field, kind, f.values[0])
+		if err != nil {
+			return "", err
+		}
+		return f.field + " == " + value, nil
+
+	case opIn:
+		if len(f.values) == 0 {
+			return "", fmt.Errorf("filter on %s needs at least one value", f.field)
+		}
+		values := make([]string, 0, len(f.values))
+		for _, v := range f.values {
+			value, err := literal(f.field, kind, v)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+		return f.field + " in [" + strings.Join(values, ", ") + "]", nil
+
+	case opRange:
+		if kind != KindInt && kind != KindFloat {
+			return "", fmt.Errorf("range filter on non-numeric field %s", f.field)
+		}
+		for _, bound := range []*float64{f.min, f.max} {
+			if bound != nil && (math.IsNaN(*bound) || math.IsInf(*bound, 0)) {
+				return "", fmt.Errorf("invalid bound %v for filter field %s", *bound, f.field)
+			}
+		}
+		var bounds []string
+		if f.min != nil {
+			bounds = append(bounds, f.field+" >= "+formatFloat(*f.min))
+		}
+		if f.max != nil {
+			bounds = append(bounds, f.field+" <= "+formatFloat(*f.max))
+		}
+		if len(bounds) == 1 {
+			return bounds[0], nil
+		}
+		return "(" + strings.Join(bounds, " && ") + ")", nil
+	}
+	return "", fmt.Errorf("unknown filter operator %q", f.op)
+}
+
+// literal renders a value for a field of the given kind.
+func literal(field string, kind Kind, value interface{}) (string, error) {
+	switch kind {
+	case KindString:
+		if s, ok := value.(string); ok {
+			// a Go quoted string is a valid Milvus string literal
+			return strconv.Quote(s), nil
+		}
+	case KindInt:
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case int32:
+			return strconv.FormatInt(int64(v), 10), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		}
+	case KindFloat:
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float32:
+			if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+				return formatFloat(float64(v)), nil
+			}
+		case float64:
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				return formatFloat(v), nil
+			}
+		}
+	case KindBool:
+		if b, ok := value.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	}
+	return "", fmt.Errorf("invalid value %v of type %T for filter field %s", value, value, field)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (s Schema) fields() []string {
+	fields := make([]string, 0, len(s))
+	for field := range s {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
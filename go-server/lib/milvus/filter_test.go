@@ -0,0 +1,101 @@
+package milvus
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+var testSchema = Schema{
+	"site_code":  KindString,
+	"category":   KindString,
+	"product_id": KindString,
+	"price":      KindFloat,
+	"rank":       KindInt,
+	"active":     KindBool,
+}
+
+func TestFilterExpr(t *testing.T) {
+	low, high := 500.0, 1000.0
+	nan := math.NaN()
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    string
+		wantErr bool
+	}{
+		{name: "zero filter", filter: Filter{}, want: ""},
+		{name: "eq string", filter: Eq("site_code", "abc123"), want: `site_code == "abc123"`},
+		{name: "string is quoted", filter: Eq("category", `bed" || site_code != "`), want: `category == "bed\" || site_code != \""`},
+		{name: "eq int", filter: Eq("rank", 3), want: "rank == 3"},
+		{name: "eq bool", filter: Eq("active", true), want: "active == true"},
+		{name: "in", filter: In("product_id", "p1", "p2"), want: `product_id in ["p1", "p2"]`},
+		{name: "in without values", filter: In("product_id"), wantErr: true},
+		{name: "closed range", filter: Range("price", &low, &high), want: "(price >= 500 && price <= 1000)"},
+		{name: "open range", filter: Range("price", nil, &high), want: "price <= 1000"},
+		{name: "empty range", filter: Range("price", nil, nil), want: ""},
+		{name: "range on a string", filter: Range("category", &low, nil), wantErr: true},
+		{name: "NaN bound", filter: Range("price", &nan, nil), wantErr: true},
+		{
+			name:   "and skips empty filters",
+			filter: And(Eq("site_code", "abc123"), Range("price", nil, nil), Or()),
+			want:   `site_code == "abc123"`,
+		},
+		{
+			name:   "or with an empty filter matches everything",
+			filter: Or(Eq("category", "rugs"), And()),
+			want:   "",
+		},
+		{
+			name:   "and keeps an or that matches everything out",
+			filter: And(Eq("site_code", "abc123"), Or(Eq("category", "rugs"), Range("price", nil, nil))),
+			want:   `site_code == "abc123"`,
+		},
+		{
+			name:   "nested",
+			filter: And(Eq("site_code", "abc123"), Or(Eq("category", "rugs"), Eq("category", "lamps"))),
+			want:   `(site_code == "abc123" && (category == "rugs" || category == "lamps"))`,
+		},
+		{name: "unknown field", filter: Eq("name", "sofa"), wantErr: true},
+		{name: "wrong kind", filter: Eq("price", "cheap"), wantErr: true},
+		{name: "int for a float field", filter: Eq("price", 999), want: "price == 999"},
+		{name: "float for an int field", filter: Eq("rank", 1.5), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.filter.Expr(testSchema)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Expr = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Expr = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(&entity.Schema{Fields: []*entity.Field{
+		{Name: "site_code", DataType: entity.FieldTypeVarChar},
+		{Name: "price", DataType: entity.FieldTypeDouble},
+		{Name: "rank", DataType: entity.FieldTypeInt64},
+		{Name: "active", DataType: entity.FieldTypeBool},
+		{Name: "vector", DataType: entity.FieldTypeFloatVector},
+		{Name: "meta", DataType: entity.FieldTypeJSON},
+	}})
+	want := Schema{"site_code": KindString, "price": KindFloat, "rank": KindInt, "active": KindBool}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("SchemaOf = %v, want %v", schema, want)
+	}
+	if len(SchemaOf(nil)) != 0 {
+		t.Error("SchemaOf(nil) is not empty")
+	}
+}
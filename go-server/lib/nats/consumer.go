@@ -11,7 +11,9 @@ import (
 	"syscall"
 	"time"
 
+	dao "github.com/homingos/campaign-svc/daos"
 	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/lib/search"
 	"github.com/homingos/campaign-svc/models"
 	"github.com/homingos/campaign-svc/types/consts"
 	"github.com/homingos/campaign-svc/utils"
@@ -277,6 +279,14 @@ func (cli *Client) processProductCatalogueMessage(ctx context.Context, msg jetst
 
 	expShortCodeMap := make(map[string]string)
 
+	vocab := search.DefaultVocabulary()
+	if cli.vocabularies != nil {
+		var vocabErr error
+		if vocab, vocabErr = cli.vocabularies.For(catalogueDto.SiteCode); vocabErr != nil {
+			cli.lgr.Warnw("Falling back to the default attribute vocabulary", "siteCode", catalogueDto.SiteCode, "error", vocabErr)
+		}
+	}
+
 	campaignGroup, daoErr := cli.campDao.CreateCampaignGroupDao(nil, &sessionCtx, campaignGroupReq)
 	if daoErr != nil {
 		txError = daoErr
@@ -407,6 +417,7 @@ func (cli *Client) processProductCatalogueMessage(ctx context.Context, msg jetst
 				ProductUrl:  product.ProductUrl,
 				Price:       product.Price,
 				ImageURL:    product.ImageUrl,
+				Attributes:  vocab.ExtractProduct(product.Name, product.Category, product.Description),
 			},
 			WorkflowID: workflowID,
 		}
@@ -587,10 +598,67 @@ func (cli *Client) processProductCatalogueMessage(ctx context.Context, msg jetst
 		}
 	}()
 
+	// the catalogue is committed, a failed mapping patch must not redeliver it
+	cli.patchShortCodeMapping(ctx, catalogueDto.SiteCode, catalogueMappingEntries(campaigns))
+
 	cli.lgr.Infow("Product catalogue processed successfully", "siteCode", catalogueDto.SiteCode, "clientID", catalogueDto.ClientID)
 	msg.Ack()
 }
 
+// catalogueMappingEntries names every campaign a catalogue created. Their
+// experiences are still processing, the mapping lists them from the start so
+// a search by name finds them as soon as the media is ready.
+func catalogueMappingEntries(campaigns []*models.Campaign) []models.ShortCodeMapping {
+	entries := make([]models.ShortCodeMapping, 0, len(campaigns))
+	for _, camp := range campaigns {
+		entries = append(entries, models.ShortCodeMapping{
+			ShortCode:  camp.ShortCode,
+			Name:       utils.StripMilvusRefNo(camp.Name),
+			CampaignID: camp.ID.Hex(),
+		})
+	}
+	return entries
+}
+
+// patchShortCodeMapping adds the newly created campaigns to the site code's
+// short code mapping as a new version and announces it.
+func (cli *Client) patchShortCodeMapping(ctx context.Context, siteCode string, entries []models.ShortCodeMapping) {
+	event := cli.mappingPatch(ctx, siteCode, entries)
+	if event == nil {
+		return
+	}
+	if err := cli.PublishMappingUpdated(event); err != nil {
+		cli.lgr.Errorw("Error publishing mapping updated event", "siteCode", siteCode, "error", err)
+	}
+	cli.lgr.Infow("Short code mapping patched", "siteCode", siteCode, "version", event.Version, "added", len(event.AddedShortCodes))
+}
+
+// mappingPatch stores the patched mapping and returns the event announcing
+// it, or nil when nothing was added.
+func (cli *Client) mappingPatch(ctx context.Context, siteCode string, entries []models.ShortCodeMapping) *dtos.MappingUpdatedEventDto {
+	if cli.mappingStore == nil || siteCode == "" || len(entries) == 0 {
+		return nil
+	}
+
+	previousVersion, mapping, added, err := dao.PatchMapping(ctx, cli.mappingStore, siteCode, entries)
+	if err != nil {
+		cli.lgr.Errorw("Error patching short code mapping", "siteCode", siteCode, "error", err)
+		return nil
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	return &dtos.MappingUpdatedEventDto{
+		SiteCode:        siteCode,
+		Version:         mapping.Version,
+		PreviousVersion: previousVersion,
+		AddedShortCodes: added,
+		Source:          ProductCatalogueConsumerName,
+		UpdatedAt:       mapping.LastUpdated,
+	}
+}
+
 // Stop gracefully shuts down the NATS client.
 func (cli *Client) Stop() {
 	log.Println("Closing NATS connection...")
@@ -0,0 +1,54 @@
+package nats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestCatalogueMappingPatch(t *testing.T) {
+	ctx := context.Background()
+	store := dao.NewFileMappingStore(t.TempDir())
+	existing := models.ShortCodeMapping{ShortCode: "old1", Name: "Lamp"}
+	if _, err := store.Save(ctx, &models.MappingData{SiteCode: "s1", Mappings: []models.ShortCodeMapping{existing}}); err != nil {
+		t.Fatal(err)
+	}
+	cli := &Client{mappingStore: store, lgr: zap.NewNop().Sugar()}
+
+	// the campaigns of one catalogue message, their experiences processing
+	sofa := &models.Campaign{ID: primitive.NewObjectID(), Name: "Red Sofa - p1", ShortCode: "sofa1"}
+	chair := &models.Campaign{ID: primitive.NewObjectID(), Name: "Chair - p2", ShortCode: "chair1"}
+	event := cli.mappingPatch(ctx, "s1", catalogueMappingEntries([]*models.Campaign{sofa, chair}))
+	if event == nil {
+		t.Fatal("no mapping updated event, want one for the new campaigns")
+	}
+	if event.SiteCode != "s1" || event.Version != 2 || event.PreviousVersion != 1 || event.Source != ProductCatalogueConsumerName {
+		t.Errorf("event = %+v, want version 2 of s1 after 1", event)
+	}
+	if want := []string{"sofa1", "chair1"}; !reflect.DeepEqual(event.AddedShortCodes, want) {
+		t.Errorf("added = %v, want %v", event.AddedShortCodes, want)
+	}
+
+	mapping, err := store.Get(ctx, "s1", dao.LatestMappingVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ShortCodeMapping{
+		existing,
+		{ShortCode: "sofa1", Name: "Red Sofa", CampaignID: sofa.ID.Hex()},
+		{ShortCode: "chair1", Name: "Chair", CampaignID: chair.ID.Hex()},
+	}
+	if mapping.Version != 2 || !reflect.DeepEqual(mapping.Mappings, want) {
+		t.Errorf("mapping = v%d %+v, want v2 %+v", mapping.Version, mapping.Mappings, want)
+	}
+
+	// a redelivered message adds nothing and announces nothing
+	if again := cli.mappingPatch(ctx, "s1", catalogueMappingEntries([]*models.Campaign{sofa})); again != nil {
+		t.Errorf("redelivery event = %+v, want none", again)
+	}
+}
@@ -2,12 +2,15 @@ package nats
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	redisStorage "github.com/homingos/campaign-svc/lib/redis"
+	"github.com/homingos/campaign-svc/lib/search"
 
 	dao "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
 	"github.com/homingos/campaign-svc/lib/transaction"
 	"github.com/homingos/campaign-svc/types/consts"
 	"github.com/homingos/flam-go-common/authz"
@@ -17,22 +20,24 @@ import (
 )
 
 type Client struct {
-	nc          *nats.Conn
-	js          jetstream.JetStream
-	Stream      jetstream.Stream
-	KV          jetstream.KeyValue
-	expDao      dao.ExperienceDao
-	redisClient *redisStorage.RedisClient
-	campDao     dao.CampaignDao
-	remotionDao dao.RemotionDao
-	categoryDao dao.CategoryDao
-	templateDao dao.TemplateDao
-	txManager   transaction.TransactionManager
-	fgaClient   *authz.OpenFGAClient
-	lgr         *zap.SugaredLogger
+	nc           *nats.Conn
+	js           jetstream.JetStream
+	Stream       jetstream.Stream
+	KV           jetstream.KeyValue
+	expDao       dao.ExperienceDao
+	redisClient  *redisStorage.RedisClient
+	campDao      dao.CampaignDao
+	remotionDao  dao.RemotionDao
+	categoryDao  dao.CategoryDao
+	templateDao  dao.TemplateDao
+	txManager    transaction.TransactionManager
+	fgaClient    *authz.OpenFGAClient
+	mappingStore dao.MappingStore
+	vocabularies *search.VocabularyStore
+	lgr          *zap.SugaredLogger
 }
 
-func NewClient(expDao dao.ExperienceDao, campDao dao.CampaignDao, remDao dao.RemotionDao, redisClient *redisStorage.RedisClient, categoryDao dao.CategoryDao, templateDao dao.TemplateDao, txManager transaction.TransactionManager, fgaClient *authz.OpenFGAClient, lgr *zap.SugaredLogger) (*Client, error) {
+func NewClient(expDao dao.ExperienceDao, campDao dao.CampaignDao, remDao dao.RemotionDao, redisClient *redisStorage.RedisClient, categoryDao dao.CategoryDao, templateDao dao.TemplateDao, txManager transaction.TransactionManager, fgaClient *authz.OpenFGAClient, mappingStore dao.MappingStore, vocabularies *search.VocabularyStore, lgr *zap.SugaredLogger) (*Client, error) {
 	nc, err := nats.Connect(NatsServerURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
@@ -57,19 +62,21 @@ func NewClient(expDao dao.ExperienceDao, campDao dao.CampaignDao, remDao dao.Rem
 	}
 
 	return &Client{
-		nc:          nc,
-		js:          js,
-		Stream:      stream,
-		KV:          kv,
-		expDao:      expDao,
-		campDao:     campDao,
-		remotionDao: remDao,
-		redisClient: redisClient,
-		categoryDao: categoryDao,
-		templateDao: templateDao,
-		txManager:   txManager,
-		fgaClient:   fgaClient,
-		lgr:         lgr,
+		nc:           nc,
+		js:           js,
+		Stream:       stream,
+		KV:           kv,
+		expDao:       expDao,
+		campDao:      campDao,
+		remotionDao:  remDao,
+		redisClient:  redisClient,
+		categoryDao:  categoryDao,
+		templateDao:  templateDao,
+		txManager:    txManager,
+		fgaClient:    fgaClient,
+		mappingStore: mappingStore,
+		vocabularies: vocabularies,
+		lgr:          lgr,
 	}, nil
 }
 
@@ -101,3 +108,29 @@ func (cli *Client) PublishMsg(subject string, payload []byte) error {
 	fmt.Println("Published message to subject:", subject, "at:", time.Now())
 	return nil
 }
+
+// PublishMappingUpdated announces a new short code mapping version.
+func (cli *Client) PublishMappingUpdated(event *dtos.MappingUpdatedEventDto) error {
+	barr, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal mapping updated event: %w", err)
+	}
+	return cli.PublishMsg(consts.MappingUpdatedSubject, barr)
+}
+
+// SubscribeMappingUpdates calls handler for every mapping updated event,
+// including the ones published by this process.
+func (cli *Client) SubscribeMappingUpdates(handler func(event dtos.MappingUpdatedEventDto)) error {
+	_, err := cli.nc.Subscribe(consts.MappingUpdatedSubject, func(msg *nats.Msg) {
+		var event dtos.MappingUpdatedEventDto
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			cli.lgr.Errorw("Error unmarshalling mapping updated event", "error", err)
+			return
+		}
+		handler(event)
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	return nil
+}
@@ -114,3 +114,102 @@ func (redisCli *RedisClient) ExpireCampaignExperiences(campaignID string, univer
 	}
 	return nil
 }
+
+// MappingVersion returns the latest mapping version allocated for a site
+// code, 0 when none was.
+func (redisCli *RedisClient) MappingVersion(ctx context.Context, siteCode string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	version, err := redisCli.cli.Get(ctx, mappingCounterKey(siteCode)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// createMappingScript allocates a mapping version, stores the mapping and
+// marks it as the latest in one step, so a version is never allocated
+// without its mapping. It does nothing when another writer has already
+// allocated the version.
+var createMappingScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[1]) or '0') >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('SET', KEYS[2], ARGV[2])
+redis.call('SET', KEYS[3], ARGV[1])
+return 1
+`)
+
+// CreateMapping stores a mapping as exactly version without expiry and marks
+// it as the latest. It returns false when the version was already allocated.
+func (redisCli *RedisClient) CreateMapping(ctx context.Context, siteCode string, version int, val interface{}) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	barr, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+	keys := []string{mappingCounterKey(siteCode), mappingVersionKey(siteCode, version), mappingLatestKey(siteCode)}
+	created, err := createMappingScript.Run(ctx, redisCli.cli, keys, version, barr).Int()
+	if err != nil {
+		return false, err
+	}
+	return created == 1, nil
+}
+
+// GetMapping decodes a stored mapping version into out. Version 0 reads the
+// latest version. It returns false when the mapping does not exist.
+func (redisCli *RedisClient) GetMapping(ctx context.Context, siteCode string, version int, out interface{}) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if version == 0 {
+		latest, err := redisCli.cli.Get(ctx, mappingLatestKey(siteCode)).Int()
+		if err == redis.Nil {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		version = latest
+	}
+	val, err := redisCli.cli.Get(ctx, mappingVersionKey(siteCode, version)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(val), out)
+}
+
+// SetEmbedding stores the embedding of a text for a model; ttl 0 keeps it
+// without expiry.
+func (redisCli *RedisClient) SetEmbedding(ctx context.Context, model string, dimension int, textHash string, vector []float32, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	barr, err := json.Marshal(vector)
+	if err != nil {
+		return err
+	}
+	return redisCli.cli.Set(ctx, embeddingKey(model, dimension, textHash), barr, ttl).Err()
+}
+
+// GetEmbedding returns the stored embedding of a text for a model, or false
+// when there is none.
+func (redisCli *RedisClient) GetEmbedding(ctx context.Context, model string, dimension int, textHash string) ([]float32, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	val, err := redisCli.cli.Get(ctx, embeddingKey(model, dimension, textHash)).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var vector []float32
+	if err := json.Unmarshal([]byte(val), &vector); err != nil {
+		return nil, false, err
+	}
+	return vector, true, nil
+}
@@ -1,9 +1,13 @@
 package redisStorage
 
+import "strconv"
+
 const (
 	prefix                  = "campaign-svc"
 	universalCampaignPrefix = prefix + ":" + "universal-campaign"
 	categoryPrefix          = prefix + ":" + "category"
+	mappingPrefix           = prefix + ":" + "mapping"
+	embeddingPrefix         = prefix + ":" + "embedding"
 )
 
 func campaignExperiencesKey(campaignID string) string {
@@ -17,3 +21,19 @@ func universalCampaignKey(universalCampaignID string) string {
 func categoryExperiencesKey(categoryID string) string {
 	return categoryPrefix + ":" + categoryID + ":" + "experiences"
 }
+
+func mappingVersionKey(siteCode string, version int) string {
+	return mappingPrefix + ":" + siteCode + ":" + "v" + strconv.Itoa(version)
+}
+
+func mappingLatestKey(siteCode string) string {
+	return mappingPrefix + ":" + siteCode + ":" + "latest"
+}
+
+func mappingCounterKey(siteCode string) string {
+	return mappingPrefix + ":" + siteCode + ":" + "counter"
+}
+
+func embeddingKey(model string, dimension int, textHash string) string {
+	return embeddingPrefix + ":" + model + ":" + strconv.Itoa(dimension) + ":" + textHash
+}
@@ -0,0 +1,275 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+// How a query attribute narrows candidates.
+const (
+	// AttributeFilter drops candidates whose attribute contradicts the query.
+	AttributeFilter = "filter"
+	// AttributeBoost raises candidates whose attribute agrees with the query.
+	AttributeBoost = "boost"
+)
+
+const defaultAttributeBoost = 0.25
+
+// AttributeVocabulary lists the values of one attribute and the phrases
+// that signal each value, e.g. gender "women": ["women", "ladies"].
+type AttributeVocabulary struct {
+	Mode   string              `json:"mode"`
+	Boost  float64             `json:"boost,omitempty"`
+	Values map[string][]string `json:"values"`
+	// Wildcards are product values that agree with every query value, like
+	// "unisex" for gender.
+	Wildcards []string `json:"wildcards,omitempty"`
+}
+
+// Vocabulary is the attribute vocabulary of a site code.
+type Vocabulary struct {
+	Attributes map[string]AttributeVocabulary `json:"attributes"`
+}
+
+// DefaultVocabulary covers the attributes common to apparel and home
+// catalogues. Site vocabularies replace it attribute by attribute.
+func DefaultVocabulary() *Vocabulary {
+	return &Vocabulary{Attributes: map[string]AttributeVocabulary{
+		"gender": {
+			Mode: AttributeFilter,
+			Values: map[string][]string{
+				"men":    {"men", "man", "male", "gents", "boy"},
+				"women":  {"women", "woman", "female", "ladies", "lady", "girl"},
+				"kids":   {"kid", "children", "child", "baby", "toddler"},
+				"unisex": {"unisex"},
+			},
+			Wildcards: []string{"unisex"},
+		},
+		"fit": {
+			Mode:  AttributeBoost,
+			Boost: defaultAttributeBoost,
+			Values: map[string][]string{
+				"relaxed":   {"relaxed", "loose", "baggy"},
+				"slim":      {"slim", "skinny"},
+				"regular":   {"regular fit", "classic fit"},
+				"oversized": {"oversized", "oversize"},
+				"straight":  {"straight"},
+			},
+		},
+		"size": {
+			Mode:  AttributeBoost,
+			Boost: defaultAttributeBoost,
+			Values: map[string][]string{
+				"king":   {"king", "king size"},
+				"queen":  {"queen", "queen size"},
+				"single": {"single", "single bed"},
+				"double": {"double", "double bed"},
+			},
+		},
+		"material": {
+			Mode:  AttributeBoost,
+			Boost: defaultAttributeBoost,
+			Values: map[string][]string{
+				"cotton":    {"cotton"},
+				"linen":     {"linen"},
+				"denim":     {"denim"},
+				"wool":      {"wool", "woollen", "woolen"},
+				"leather":   {"leather"},
+				"silk":      {"silk"},
+				"polyester": {"polyester"},
+				"twill":     {"twill"},
+			},
+		},
+	}}
+}
+
+// Extract returns the attribute values whose phrases appear in text, by
+// attribute, values sorted.
+func (v *Vocabulary) Extract(text string) map[string][]string {
+	tokens := Tokenize(text)
+	found := make(map[string][]string)
+	for attribute, vocab := range v.Attributes {
+		for value, phrases := range vocab.Values {
+			for _, phrase := range phrases {
+				if containsPhrase(tokens, Tokenize(phrase)) {
+					found[attribute] = append(found[attribute], value)
+					break
+				}
+			}
+		}
+		sort.Strings(found[attribute])
+	}
+	for attribute, values := range found {
+		if len(values) == 0 {
+			delete(found, attribute)
+		}
+	}
+	return found
+}
+
+// ExtractProduct returns the attributes of a catalogue product from its
+// name, category and description.
+func (v *Vocabulary) ExtractProduct(name, category, description string) map[string][]string {
+	return v.Extract(strings.Join([]string{name, category, description}, " "))
+}
+
+func containsPhrase(tokens, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		match := true
+		for j := range phrase {
+			if tokens[i+j] != phrase[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Applied lists the query attributes in a stable order with the mode each
+// one is applied with.
+func (v *Vocabulary) Applied(queryAttributes map[string][]string) []dtos.AttributeConstraintDto {
+	applied := make([]dtos.AttributeConstraintDto, 0, len(queryAttributes))
+	for attribute, values := range queryAttributes {
+		applied = append(applied, dtos.AttributeConstraintDto{
+			Attribute: attribute,
+			Values:    values,
+			Mode:      v.Attributes[attribute].Mode,
+		})
+	}
+	sort.Slice(applied, func(i, j int) bool { return applied[i].Attribute < applied[j].Attribute })
+	return applied
+}
+
+// AttributeAgreement compares one query attribute with a product. known is
+// false when the product has no value for the attribute.
+func (v *Vocabulary) AttributeAgreement(attribute string, queryValues []string, productAttributes map[string][]string) (agrees bool, known bool) {
+	productValues := productAttributes[attribute]
+	if len(productValues) == 0 {
+		return false, false
+	}
+	wanted := make(map[string]bool, len(queryValues))
+	for _, value := range queryValues {
+		wanted[value] = true
+	}
+	for _, wildcard := range v.Attributes[attribute].Wildcards {
+		wanted[wildcard] = true
+	}
+	for _, value := range productValues {
+		if wanted[value] {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// VocabularyStore loads site vocabularies from <dir>/<sitecode>.json on
+// first use and keeps them for the life of the process. Sites without a
+// file use the default vocabulary.
+type VocabularyStore struct {
+	dir          string
+	mu           sync.RWMutex
+	vocabularies map[string]*Vocabulary
+}
+
+func NewVocabularyStore(dir string) *VocabularyStore {
+	return &VocabularyStore{dir: dir, vocabularies: make(map[string]*Vocabulary)}
+}
+
+// For returns the vocabulary of a site code. On a broken site file the
+// default vocabulary is returned along with the error.
+func (s *VocabularyStore) For(siteCode string) (*Vocabulary, error) {
+	s.mu.RLock()
+	vocabulary, ok := s.vocabularies[siteCode]
+	s.mu.RUnlock()
+	if ok {
+		return vocabulary, nil
+	}
+
+	vocabulary, err := s.load(siteCode)
+	if err != nil {
+		return DefaultVocabulary(), err
+	}
+	s.mu.Lock()
+	s.vocabularies[siteCode] = vocabulary
+	s.mu.Unlock()
+	return vocabulary, nil
+}
+
+func (s *VocabularyStore) load(siteCode string) (*Vocabulary, error) {
+	vocabulary := DefaultVocabulary()
+	if s.dir == "" || siteCode == "" || filepath.Base(siteCode) != siteCode {
+		return vocabulary, nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, siteCode+".json"))
+	if os.IsNotExist(err) {
+		return vocabulary, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var site Vocabulary
+	if err := json.Unmarshal(data, &site); err != nil {
+		return nil, fmt.Errorf("failed to parse vocabulary of %s: %w", siteCode, err)
+	}
+	for attribute, vocab := range site.Attributes {
+		switch vocab.Mode {
+		case AttributeFilter, AttributeBoost:
+		case "":
+			vocab.Mode = AttributeBoost
+		default:
+			return nil, fmt.Errorf("attribute %s of %s has unknown mode %q", attribute, siteCode, vocab.Mode)
+		}
+		if vocab.Mode == AttributeBoost && vocab.Boost <= 0 {
+			vocab.Boost = defaultAttributeBoost
+		}
+		vocabulary.Attributes[attribute] = vocab
+	}
+	return vocabulary, nil
+}
+
+// ApplyAttributes narrows ranked candidates with the attributes found in a
+// query. Filter attributes drop candidates whose product attribute
+// contradicts the query; products without the attribute are kept. Boost
+// attributes scale the score of agreeing candidates by 1+boost, after which
+// candidates are re-ranked by score.
+func (v *Vocabulary) ApplyAttributes(queryAttributes map[string][]string, candidates []dtos.ResultItem, productAttributes map[string]map[string][]string) []dtos.ResultItem {
+	kept := make([]dtos.ResultItem, 0, len(candidates))
+	boosted := false
+	for _, candidate := range candidates {
+		attributes := productAttributes[candidate.Code]
+		keep := true
+		for attribute, values := range queryAttributes {
+			vocab := v.Attributes[attribute]
+			agrees, known := v.AttributeAgreement(attribute, values, attributes)
+			if vocab.Mode == AttributeFilter && known && !agrees {
+				keep = false
+				break
+			}
+			if vocab.Mode == AttributeBoost && agrees {
+				candidate.Score *= float32(1 + vocab.Boost)
+				boosted = true
+			}
+		}
+		if keep {
+			kept = append(kept, candidate)
+		}
+	}
+	if boosted {
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+	}
+	return kept
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCachedVersions bounds how many mapping versions of one site code keep
+// a catalogue; the oldest is dropped first.
+const maxCachedVersions = 3
+
+type cachedCatalogue struct {
+	catalogue *Catalogue
+	cachedAt  time.Time
+}
+
+// CatalogueCache keeps catalogues per site code and mapping version, so a
+// new mapping version gets a fresh index while runs pinned to an older
+// version keep theirs. Product prices, categories and descriptions change
+// without a new mapping version, so a catalogue is rebuilt once it is
+// older than the TTL.
+type CatalogueCache struct {
+	ttl        time.Duration
+	mu         sync.RWMutex
+	catalogues map[string]map[int]cachedCatalogue
+}
+
+func NewCatalogueCache(ttl time.Duration) *CatalogueCache {
+	return &CatalogueCache{ttl: ttl, catalogues: make(map[string]map[int]cachedCatalogue)}
+}
+
+// Get returns the catalogue built for a site code's mapping version unless
+// it has expired.
+func (c *CatalogueCache) Get(siteCode string, version int) (*Catalogue, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.catalogues[siteCode][version]
+	if !ok || time.Since(cached.cachedAt) >= c.ttl {
+		return nil, false
+	}
+	return cached.catalogue, true
+}
+
+// Put stores the catalogue built for a site code's mapping version.
+func (c *CatalogueCache) Put(siteCode string, version int, catalogue *Catalogue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	versions, ok := c.catalogues[siteCode]
+	if !ok {
+		versions = make(map[int]cachedCatalogue)
+		c.catalogues[siteCode] = versions
+	}
+	versions[version] = cachedCatalogue{catalogue: catalogue, cachedAt: time.Now()}
+	for len(versions) > maxCachedVersions {
+		oldest := version
+		for v := range versions {
+			if v < oldest {
+				oldest = v
+			}
+		}
+		delete(versions, oldest)
+	}
+}
+
+// Invalidate drops every catalogue of a site code.
+func (c *CatalogueCache) Invalidate(siteCode string) {
+	c.mu.Lock()
+	delete(c.catalogues, siteCode)
+	c.mu.Unlock()
+}
@@ -0,0 +1,304 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/homingos/campaign-svc/models"
+)
+
+// Calibration methods.
+const (
+	CalibrationPlatt    = "platt"
+	CalibrationIsotonic = "isotonic"
+)
+
+// CalibrationSample is one retrieved result of a labeled question: its raw
+// score and whether it was one of the expected products.
+type CalibrationSample struct {
+	Score    float64
+	Relevant bool
+}
+
+// Calibrator turns a raw result score into the probability, between 0 and
+// 1, that the result is relevant.
+type Calibrator interface {
+	Confidence(score float64) float64
+}
+
+// ScoreModel names what produced a search's raw scores. Lexical scores do
+// not depend on the embedding model.
+func ScoreModel(embeddingModel string, mode string, reranker string) string {
+	if mode == ModeLexical || embeddingModel == "" {
+		embeddingModel = "-"
+	}
+	return strings.Join([]string{embeddingModel, mode, reranker}, "/")
+}
+
+// PlattCalibrator fits a sigmoid to the scores.
+type PlattCalibrator struct {
+	A float64
+	B float64
+}
+
+func (c PlattCalibrator) Confidence(score float64) float64 {
+	z := c.A*score + c.B
+	if z >= 0 {
+		return math.Exp(-z) / (1 + math.Exp(-z))
+	}
+	return 1 / (1 + math.Exp(z))
+}
+
+// FitPlatt fits Platt scaling by Newton's method with backtracking, on
+// targets smoothed towards the prior as in Lin, Lin and Weng's note on
+// Platt's probabilistic outputs, so a handful of samples cannot produce
+// confidences of exactly 0 or 1.
+func FitPlatt(samples []CalibrationSample) (*PlattCalibrator, error) {
+	positives, negatives := countLabels(samples)
+	if positives == 0 || negatives == 0 {
+		return nil, fmt.Errorf("calibration needs relevant and irrelevant results, got %d and %d", positives, negatives)
+	}
+	const (
+		maxIterations = 100
+		minStep       = 1e-10
+		sigma         = 1e-12
+	)
+	hiTarget := (float64(positives) + 1) / (float64(positives) + 2)
+	loTarget := 1 / (float64(negatives) + 2)
+	targets := make([]float64, len(samples))
+	for i, sample := range samples {
+		targets[i] = loTarget
+		if sample.Relevant {
+			targets[i] = hiTarget
+		}
+	}
+	loss := func(a, b float64) float64 {
+		total := 0.0
+		for i, sample := range samples {
+			z := sample.Score*a + b
+			if z >= 0 {
+				total += targets[i]*z + math.Log1p(math.Exp(-z))
+			} else {
+				total += (targets[i]-1)*z + math.Log1p(math.Exp(z))
+			}
+		}
+		return total
+	}
+
+	a, b := 0.0, math.Log((float64(negatives)+1)/(float64(positives)+1))
+	value := loss(a, b)
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		h11, h22, h21, g1, g2 := sigma, sigma, 0.0, 0.0, 0.0
+		for i, sample := range samples {
+			p := PlattCalibrator{A: a, B: b}.Confidence(sample.Score)
+			d2 := p * (1 - p)
+			h11 += sample.Score * sample.Score * d2
+			h22 += d2
+			h21 += sample.Score * d2
+			d1 := targets[i] - p
+			g1 += sample.Score * d1
+			g2 += d1
+		}
+		if math.Abs(g1) < 1e-5 && math.Abs(g2) < 1e-5 {
+			break
+		}
+		det := h11*h22 - h21*h21
+		dA := -(h22*g1 - h21*g2) / det
+		dB := -(-h21*g1 + h11*g2) / det
+		gd := g1*dA + g2*dB
+
+		step := 1.0
+		for ; step >= minStep; step /= 2 {
+			newA, newB := a+step*dA, b+step*dB
+			if newValue := loss(newA, newB); newValue < value+0.0001*step*gd {
+				a, b, value = newA, newB, newValue
+				break
+			}
+		}
+		if step < minStep {
+			break
+		}
+	}
+	return &PlattCalibrator{A: a, B: b}, nil
+}
+
+// IsotonicCalibrator is a non-decreasing piecewise linear map from score
+// to confidence; scores outside its points take the nearest end.
+type IsotonicCalibrator struct {
+	Scores      []float64
+	Confidences []float64
+}
+
+func (c IsotonicCalibrator) Confidence(score float64) float64 {
+	n := len(c.Scores)
+	if n == 0 {
+		return 0
+	}
+	if score <= c.Scores[0] {
+		return c.Confidences[0]
+	}
+	if score >= c.Scores[n-1] {
+		return c.Confidences[n-1]
+	}
+	i := sort.SearchFloat64s(c.Scores, score)
+	if c.Scores[i] == score {
+		return c.Confidences[i]
+	}
+	x0, x1 := c.Scores[i-1], c.Scores[i]
+	y0, y1 := c.Confidences[i-1], c.Confidences[i]
+	return y0 + (y1-y0)*(score-x0)/(x1-x0)
+}
+
+type isotonicBlock struct {
+	low, high float64
+	sum       float64
+	weight    float64
+}
+
+// FitIsotonic fits isotonic regression with the pool adjacent violators
+// algorithm. Each pooled block becomes a flat segment between its lowest
+// and highest score.
+func FitIsotonic(samples []CalibrationSample) (*IsotonicCalibrator, error) {
+	positives, negatives := countLabels(samples)
+	if positives == 0 || negatives == 0 {
+		return nil, fmt.Errorf("calibration needs relevant and irrelevant results, got %d and %d", positives, negatives)
+	}
+	sorted := make([]CalibrationSample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+
+	// equal scores must get one confidence, so they start pooled, all of
+	// them before any block is pooled with its neighbours
+	var ties []isotonicBlock
+	for _, sample := range sorted {
+		label := 0.0
+		if sample.Relevant {
+			label = 1
+		}
+		if n := len(ties); n > 0 && ties[n-1].high == sample.Score {
+			ties[n-1].sum += label
+			ties[n-1].weight++
+		} else {
+			ties = append(ties, isotonicBlock{low: sample.Score, high: sample.Score, sum: label, weight: 1})
+		}
+	}
+
+	var blocks []isotonicBlock
+	for _, tie := range ties {
+		blocks = append(blocks, tie)
+		for n := len(blocks); n > 1 && blocks[n-2].sum/blocks[n-2].weight >= blocks[n-1].sum/blocks[n-1].weight; n = len(blocks) {
+			last := blocks[n-1]
+			blocks = blocks[:n-1]
+			blocks[n-2].high = last.high
+			blocks[n-2].sum += last.sum
+			blocks[n-2].weight += last.weight
+		}
+	}
+
+	calibrator := &IsotonicCalibrator{}
+	for _, block := range blocks {
+		confidence := block.sum / block.weight
+		calibrator.Scores = append(calibrator.Scores, block.low)
+		calibrator.Confidences = append(calibrator.Confidences, confidence)
+		if block.high > block.low {
+			calibrator.Scores = append(calibrator.Scores, block.high)
+			calibrator.Confidences = append(calibrator.Confidences, confidence)
+		}
+	}
+	return calibrator, nil
+}
+
+// BrierScore is the mean squared difference between a calibrator's
+// confidences and the labels; lower is better.
+func BrierScore(calibrator Calibrator, samples []CalibrationSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, sample := range samples {
+		label := 0.0
+		if sample.Relevant {
+			label = 1
+		}
+		diff := calibrator.Confidence(sample.Score) - label
+		total += diff * diff
+	}
+	return total / float64(len(samples))
+}
+
+// CalibratorOf returns the calibrator a stored calibration describes.
+func CalibratorOf(calibration *models.ScoreCalibration) (Calibrator, error) {
+	switch calibration.Method {
+	case CalibrationPlatt:
+		return PlattCalibrator{A: calibration.PlattA, B: calibration.PlattB}, nil
+	case CalibrationIsotonic:
+		if len(calibration.IsotonicScores) == 0 || len(calibration.IsotonicScores) != len(calibration.IsotonicConfidences) {
+			return nil, fmt.Errorf("isotonic calibration of %s has %d scores and %d confidences", calibration.Model, len(calibration.IsotonicScores), len(calibration.IsotonicConfidences))
+		}
+		return IsotonicCalibrator{Scores: calibration.IsotonicScores, Confidences: calibration.IsotonicConfidences}, nil
+	}
+	return nil, fmt.Errorf("unknown calibration method %q", calibration.Method)
+}
+
+func countLabels(samples []CalibrationSample) (int, int) {
+	positives := 0
+	for _, sample := range samples {
+		if sample.Relevant {
+			positives++
+		}
+	}
+	return positives, len(samples) - positives
+}
+
+type cachedCalibrator struct {
+	calibrator Calibrator
+	cachedAt   time.Time
+}
+
+// CalibrationCache keeps the calibrator of each site code and score model
+// for a TTL. A model without a calibration is cached as nil, so
+// uncalibrated searches do not read the store every time.
+type CalibrationCache struct {
+	ttl         time.Duration
+	mu          sync.RWMutex
+	calibrators map[string]map[string]cachedCalibrator
+}
+
+func NewCalibrationCache(ttl time.Duration) *CalibrationCache {
+	return &CalibrationCache{ttl: ttl, calibrators: make(map[string]map[string]cachedCalibrator)}
+}
+
+// Get returns the calibrator of a site code's score model unless it has
+// expired.
+func (c *CalibrationCache) Get(siteCode string, model string) (Calibrator, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.calibrators[siteCode][model]
+	if !ok || time.Since(cached.cachedAt) >= c.ttl {
+		return nil, false
+	}
+	return cached.calibrator, true
+}
+
+// Put stores the calibrator, possibly nil, of a site code's score model.
+func (c *CalibrationCache) Put(siteCode string, model string, calibrator Calibrator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byModel, ok := c.calibrators[siteCode]
+	if !ok {
+		byModel = make(map[string]cachedCalibrator)
+		c.calibrators[siteCode] = byModel
+	}
+	byModel[model] = cachedCalibrator{calibrator: calibrator, cachedAt: time.Now()}
+}
+
+// Invalidate drops every calibrator of a site code.
+func (c *CalibrationCache) Invalidate(siteCode string) {
+	c.mu.Lock()
+	delete(c.calibrators, siteCode)
+	c.mu.Unlock()
+}
@@ -0,0 +1,148 @@
+package search
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFitPlatt(t *testing.T) {
+	// four samples at each score, targets smoothed to 5/6 and 1/6 average
+	// 1/3 at score 0 and 2/3 at score 1, which the sigmoid fits exactly:
+	// 1/(1+e^B) = 1/3 and 1/(1+e^(A+B)) = 2/3
+	var samples []CalibrationSample
+	for _, relevant := range []bool{true, false, false, false} {
+		samples = append(samples, CalibrationSample{Score: 0, Relevant: relevant})
+	}
+	for _, relevant := range []bool{true, true, true, false} {
+		samples = append(samples, CalibrationSample{Score: 1, Relevant: relevant})
+	}
+	platt, err := FitPlatt(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := -2 * math.Ln2; math.Abs(platt.A-want) > 1e-4 {
+		t.Errorf("A = %v, want %v", platt.A, want)
+	}
+	if want := math.Ln2; math.Abs(platt.B-want) > 1e-4 {
+		t.Errorf("B = %v, want %v", platt.B, want)
+	}
+	if got := platt.Confidence(0); math.Abs(got-1.0/3) > 1e-4 {
+		t.Errorf("Confidence(0) = %v, want 1/3", got)
+	}
+	if got := platt.Confidence(1); math.Abs(got-2.0/3) > 1e-4 {
+		t.Errorf("Confidence(1) = %v, want 2/3", got)
+	}
+}
+
+func TestFitPlattSeparable(t *testing.T) {
+	samples := []CalibrationSample{
+		{Score: 0.1}, {Score: 0.2}, {Score: 0.3},
+		{Score: 0.7, Relevant: true}, {Score: 0.8, Relevant: true}, {Score: 0.9, Relevant: true},
+	}
+	platt, err := FitPlatt(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := 0.0
+	for _, score := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		confidence := platt.Confidence(score)
+		if confidence <= previous || confidence >= 1 {
+			t.Errorf("Confidence(%v) = %v, want increasing and below 1", score, confidence)
+		}
+		previous = confidence
+	}
+}
+
+func TestFitIsotonic(t *testing.T) {
+	tests := []struct {
+		name        string
+		samples     []CalibrationSample
+		scores      []float64
+		confidences []float64
+	}{
+		{
+			// 0.3 violates 0.2 and pools with it; 0.4 and 0.5 pool at 1
+			name: "pooled violators",
+			samples: []CalibrationSample{
+				{Score: 0.5, Relevant: true}, {Score: 0.1}, {Score: 0.4, Relevant: true},
+				{Score: 0.3}, {Score: 0.2, Relevant: true},
+			},
+			scores:      []float64{0.1, 0.2, 0.3, 0.4, 0.5},
+			confidences: []float64{0, 0.5, 0.5, 1, 1},
+		},
+		{
+			// the tie at 0.5 is one block of 2/3 whichever label comes first
+			name: "ties, irrelevant first",
+			samples: []CalibrationSample{
+				{Score: 0.1}, {Score: 0.5}, {Score: 0.5, Relevant: true},
+				{Score: 0.5, Relevant: true}, {Score: 0.9, Relevant: true},
+			},
+			scores:      []float64{0.1, 0.5, 0.9},
+			confidences: []float64{0, 2.0 / 3, 1},
+		},
+		{
+			name: "ties, relevant first",
+			samples: []CalibrationSample{
+				{Score: 0.1}, {Score: 0.5, Relevant: true}, {Score: 0.5, Relevant: true},
+				{Score: 0.5}, {Score: 0.9, Relevant: true},
+			},
+			scores:      []float64{0.1, 0.5, 0.9},
+			confidences: []float64{0, 2.0 / 3, 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isotonic, err := FitIsotonic(test.samples)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(isotonic.Scores, test.scores) || !reflect.DeepEqual(isotonic.Confidences, test.confidences) {
+				t.Errorf("fit = %v -> %v, want %v -> %v", isotonic.Scores, isotonic.Confidences, test.scores, test.confidences)
+			}
+		})
+	}
+}
+
+func TestIsotonicConfidence(t *testing.T) {
+	isotonic := IsotonicCalibrator{
+		Scores:      []float64{0.1, 0.2, 0.3, 0.4, 0.5},
+		Confidences: []float64{0, 0.5, 0.5, 1, 1},
+	}
+	tests := []struct {
+		score float64
+		want  float64
+	}{
+		{0, 0},
+		{0.15, 0.25},
+		{0.3, 0.5},
+		{0.35, 0.75},
+		{0.9, 1},
+	}
+	for _, test := range tests {
+		if got := isotonic.Confidence(test.score); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Confidence(%v) = %v, want %v", test.score, got, test.want)
+		}
+	}
+}
+
+func TestFitNeedsBothLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []CalibrationSample
+	}{
+		{"no samples", nil},
+		{"only relevant", []CalibrationSample{{Score: 0.2, Relevant: true}, {Score: 0.8, Relevant: true}}},
+		{"only irrelevant", []CalibrationSample{{Score: 0.2}, {Score: 0.8}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := FitPlatt(test.samples); err == nil {
+				t.Error("FitPlatt succeeded, want an error")
+			}
+			if _, err := FitIsotonic(test.samples); err == nil {
+				t.Error("FitIsotonic succeeded, want an error")
+			}
+		})
+	}
+}
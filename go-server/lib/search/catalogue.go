@@ -0,0 +1,115 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+)
+
+// Catalogue is the searchable view of one mapping version: the lexical
+// index plus the name, catalogue details, attributes and category of every
+// mapped product that is still active, by short code.
+type Catalogue struct {
+	Index      *LexicalIndex
+	Names      map[string]string
+	Products   map[string]dtos.CatalogueProductDto
+	Attributes map[string]map[string][]string
+	// Categories lists the short codes of each category in mapping order.
+	Categories map[string][]string
+	// Order is every active mapped short code in mapping order.
+	Order []string
+
+	nameKeys map[string]string
+}
+
+// NewCatalogue builds the catalogue of a mapping. Mappings only grow, so
+// short codes missing from the active catalogue products are left out;
+// products ingested before attributes were stored get them extracted here.
+func NewCatalogue(mapping *models.MappingData, products []dtos.CatalogueProductDto, vocab *Vocabulary) *Catalogue {
+	productsByCode := make(map[string]dtos.CatalogueProductDto, len(products))
+	for _, product := range products {
+		productsByCode[product.ShortCode] = product
+	}
+
+	catalogue := &Catalogue{
+		Names:      make(map[string]string, len(mapping.Mappings)),
+		Products:   make(map[string]dtos.CatalogueProductDto, len(mapping.Mappings)),
+		Attributes: make(map[string]map[string][]string, len(mapping.Mappings)),
+		Categories: make(map[string][]string),
+		nameKeys:   make(map[string]string, len(mapping.Mappings)),
+	}
+	documents := make([]Document, 0, len(mapping.Mappings))
+	for _, entry := range mapping.Mappings {
+		product, ok := productsByCode[entry.ShortCode]
+		if !ok {
+			continue
+		}
+		catalogue.Names[entry.ShortCode] = entry.Name
+		catalogue.Order = append(catalogue.Order, entry.ShortCode)
+		if key := NameKey(entry.Name); key != "" {
+			if _, taken := catalogue.nameKeys[key]; !taken {
+				catalogue.nameKeys[key] = entry.ShortCode
+			}
+		}
+
+		document := Document{ShortCode: entry.ShortCode, Name: entry.Name}
+		if product.Name != "" && product.Name != entry.Name {
+			document.Name += " " + product.Name
+		}
+		document.Category = product.Category
+		document.Description = product.Description
+		catalogue.Products[entry.ShortCode] = product
+		if product.Category != "" {
+			catalogue.Categories[product.Category] = append(catalogue.Categories[product.Category], entry.ShortCode)
+		}
+		if len(product.Attributes) > 0 {
+			catalogue.Attributes[entry.ShortCode] = product.Attributes
+		} else {
+			catalogue.Attributes[entry.ShortCode] = vocab.ExtractProduct(document.Name, document.Category, document.Description)
+		}
+		documents = append(documents, document)
+	}
+	catalogue.Index = NewLexicalIndex(documents)
+	return catalogue
+}
+
+// NameKey normalizes a product name or query for exact comparison; browse
+// words like "show me" are ignored on both sides.
+func NameKey(text string) string {
+	core, _ := coreTokens(text)
+	return strings.Join(core, " ")
+}
+
+// ShortCodeByName returns the active product whose name matches text
+// exactly once both are normalized.
+func (c *Catalogue) ShortCodeByName(text string) (string, bool) {
+	key := NameKey(text)
+	if key == "" {
+		return "", false
+	}
+	shortCode, ok := c.nameKeys[key]
+	if !ok {
+		return "", false
+	}
+	if _, active := c.Products[shortCode]; !active {
+		return "", false
+	}
+	return shortCode, true
+}
+
+// LexicalScore returns the BM25 score the lexical index gives a product
+// for text, 0 when they share no term.
+func (c *Catalogue) LexicalScore(text, shortCode string) float64 {
+	for _, hit := range c.Index.Search(text, c.Index.Len()) {
+		if hit.ShortCode == shortCode {
+			return hit.Score
+		}
+	}
+	return 0
+}
+
+// CategoryOf returns the category of a product, or "" when unknown.
+func (c *Catalogue) CategoryOf(shortCode string) string {
+	return c.Products[shortCode].Category
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Retrieval modes accepted by the search endpoints.
+const (
+	ModeVector  = "vector"
+	ModeLexical = "lexical"
+	ModeHybrid  = "hybrid"
+)
+
+// DefaultRRFK is the rank constant from the original reciprocal rank
+// fusion paper; it damps the advantage of the very first ranks.
+const DefaultRRFK = 60
+
+// ParseMode validates a retrieval mode, falling back to def when empty.
+func ParseMode(mode string, def string) (string, error) {
+	if mode == "" {
+		mode = def
+	}
+	switch mode {
+	case ModeVector, ModeLexical, ModeHybrid:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown search mode %q, use %s, %s or %s", mode, ModeVector, ModeLexical, ModeHybrid)
+}
+
+// RankedList is the ranked short codes of one retriever and how much its
+// ranks count in the fusion.
+type RankedList struct {
+	Weight     float64
+	ShortCodes []string
+}
+
+// Fused is a short code with its fused score.
+type Fused struct {
+	ShortCode string
+	Score     float64
+}
+
+// FuseRRF merges ranked lists with weighted reciprocal rank fusion: every
+// list adds weight / (k + rank) to the short codes it returned. Ties keep
+// the order in which short codes were first seen.
+func FuseRRF(k int, lists ...RankedList) []Fused {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	scores := make(map[string]float64)
+	var order []string
+	for _, list := range lists {
+		for rank, shortCode := range list.ShortCodes {
+			if _, ok := scores[shortCode]; !ok {
+				order = append(order, shortCode)
+			}
+			scores[shortCode] += list.Weight / float64(k+rank+1)
+		}
+	}
+
+	fused := make([]Fused, len(order))
+	for i, shortCode := range order {
+		fused[i] = Fused{ShortCode: shortCode, Score: scores[shortCode]}
+	}
+	sort.SliceStable(fused, func(a, b int) bool {
+		return fused[a].Score > fused[b].Score
+	})
+	return fused
+}
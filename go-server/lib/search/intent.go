@@ -0,0 +1,223 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+// Query intents, the same labels the golden question set uses.
+const (
+	IntentDiscovery = "Discovery"
+	IntentBrowse    = "Browse"
+	IntentFilter    = "Filter"
+	IntentDirect    = "Direct"
+)
+
+// ParseIntent validates a caller supplied intent, case insensitively. An
+// empty intent is returned as is and means "classify".
+func ParseIntent(intent string) (string, error) {
+	for _, known := range []string{IntentDiscovery, IntentBrowse, IntentFilter, IntentDirect} {
+		if strings.EqualFold(intent, known) {
+			return known, nil
+		}
+	}
+	if intent == "" {
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown intent %q, use %s, %s, %s or %s", intent, IntentDirect, IntentBrowse, IntentFilter, IntentDiscovery)
+}
+
+// IntentQuery is what a classifier sees of a query once price and
+// attributes have been parsed out.
+type IntentQuery struct {
+	// Text is the query with any price phrase removed.
+	Text            string
+	PriceConstraint *dtos.PriceConstraintDto
+	Attributes      map[string][]string
+}
+
+// Classification is the intent of a query with what the classifier found
+// to route it.
+type Classification struct {
+	Intent string
+	Reason string
+	// ShortCode is the product a Direct query names.
+	ShortCode string
+	// Categories are the categories a Browse query asks for, or those a
+	// Filter query names to narrow it to.
+	Categories []string
+}
+
+// IntentClassifier decides how a query should be answered.
+type IntentClassifier interface {
+	Classify(query IntentQuery, catalogue *Catalogue) Classification
+}
+
+// browseWords only frame a request ("show me all jeans") and are ignored
+// when matching the rest of the query against names and categories.
+var browseWords = map[string]bool{
+	"show": true, "browse": true, "all": true, "list": true, "see": true,
+	"view": true, "find": true, "looking": true, "get": true, "give": true,
+}
+
+// discoveryWords mark open ended requests that name no product or category.
+var discoveryWords = map[string]bool{
+	"something": true, "anything": true, "idea": true, "gift": true,
+	"recommend": true, "suggest": true, "suggestion": true, "what": true,
+	"inspire": true, "inspiration": true,
+}
+
+// maxFilterTokens is the longest query, in tokens, that the rule based
+// classifier still treats as a product description rather than discovery.
+const maxFilterTokens = 3
+
+// RuleClassifier classifies queries with keyword rules, in order:
+//
+//   - Direct: the query is exactly a product name
+//   - Discovery: the query is open ended ("something cozy for winter")
+//   - Filter: the query carries a price constraint
+//   - Browse: the query names a category, or is a short "show me ..."
+//   - Filter: the query carries an attribute constraint
+//   - otherwise Filter for short queries and Discovery for long ones
+//
+// A Filter query is narrowed to the categories it names among its other
+// words ("red sofa" to Sofas).
+type RuleClassifier struct{}
+
+func NewRuleClassifier() *RuleClassifier {
+	return &RuleClassifier{}
+}
+
+func (c *RuleClassifier) Classify(query IntentQuery, catalogue *Catalogue) Classification {
+	core, framed := coreTokens(query.Text)
+
+	if shortCode, ok := catalogue.ShortCodeByName(query.Text); ok {
+		return Classification{Intent: IntentDirect, Reason: "query is a product name", ShortCode: shortCode}
+	}
+	for _, token := range core {
+		if discoveryWords[token] {
+			return Classification{Intent: IntentDiscovery, Reason: fmt.Sprintf("open ended request (%q)", token)}
+		}
+	}
+	if query.PriceConstraint != nil {
+		return Classification{Intent: IntentFilter, Reason: "price constraint", Categories: catalogue.namedCategories(core)}
+	}
+	if categories := catalogue.matchCategories(core); len(categories) > 0 {
+		return Classification{Intent: IntentBrowse, Reason: "query names a category", Categories: categories}
+	}
+	if framed && len(core) > 0 && len(core) <= 2 {
+		return Classification{Intent: IntentBrowse, Reason: "short browse request"}
+	}
+	if len(query.Attributes) > 0 {
+		return Classification{Intent: IntentFilter, Reason: "attribute constraint", Categories: catalogue.namedCategories(core)}
+	}
+	if len(core) <= maxFilterTokens {
+		return Classification{Intent: IntentFilter, Reason: "short product description", Categories: catalogue.namedCategories(core)}
+	}
+	return Classification{Intent: IntentDiscovery, Reason: "long descriptive query"}
+}
+
+// coreTokens tokenizes a query without its browse words and reports
+// whether there were any.
+func coreTokens(text string) ([]string, bool) {
+	var core []string
+	framed := false
+	for _, token := range Tokenize(text) {
+		if browseWords[token] {
+			framed = true
+			continue
+		}
+		core = append(core, token)
+	}
+	return core, framed
+}
+
+// MatchCategories returns the categories a query asks for, ignoring browse
+// words like "show me all".
+func (c *Catalogue) MatchCategories(text string) []string {
+	core, _ := coreTokens(text)
+	return c.matchCategories(core)
+}
+
+// matchCategories returns the categories whose name contains every query
+// token, or the single category the query names exactly.
+func (c *Catalogue) matchCategories(core []string) []string {
+	if len(core) == 0 {
+		return nil
+	}
+	var matched []string
+	for category := range c.Categories {
+		categoryTokens := Tokenize(category)
+		if strings.Join(categoryTokens, " ") == strings.Join(core, " ") {
+			return []string{category}
+		}
+		if containsAll(categoryTokens, core) {
+			matched = append(matched, category)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// NamedCategories returns the categories a Filter query narrows to, those
+// whose whole name appears among its words.
+func (c *Catalogue) NamedCategories(text string) []string {
+	core, _ := coreTokens(text)
+	return c.namedCategories(core)
+}
+
+func (c *Catalogue) namedCategories(core []string) []string {
+	if len(core) == 0 {
+		return nil
+	}
+	var named []string
+	for category := range c.Categories {
+		categoryTokens := Tokenize(category)
+		if len(categoryTokens) > 0 && containsAll(core, categoryTokens) {
+			named = append(named, category)
+		}
+	}
+	sort.Strings(named)
+	return named
+}
+
+func containsAll(tokens, wanted []string) bool {
+	have := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		have[token] = true
+	}
+	for _, token := range wanted {
+		if !have[token] {
+			return false
+		}
+	}
+	return true
+}
+
+// Diversify reorders ranked candidates so consecutive results come from
+// different categories: each round takes the best remaining candidate of
+// every category, categories ordered by their best candidate.
+func Diversify(candidates []dtos.ResultItem, categoryOf func(shortCode string) string) []dtos.ResultItem {
+	var categories []string
+	groups := make(map[string][]dtos.ResultItem)
+	for _, candidate := range candidates {
+		category := categoryOf(candidate.Code)
+		if _, ok := groups[category]; !ok {
+			categories = append(categories, category)
+		}
+		groups[category] = append(groups[category], candidate)
+	}
+
+	diversified := make([]dtos.ResultItem, 0, len(candidates))
+	for round := 0; len(diversified) < len(candidates); round++ {
+		for _, category := range categories {
+			if round < len(groups[category]) {
+				diversified = append(diversified, groups[category][round])
+			}
+		}
+	}
+	return diversified
+}
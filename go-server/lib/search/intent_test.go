@@ -0,0 +1,84 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+)
+
+func testCatalogue() *Catalogue {
+	mapping := &models.MappingData{Mappings: []models.ShortCodeMapping{
+		{ShortCode: "s1", Name: "Chesterfield Sofa"},
+		{ShortCode: "s2", Name: "Oak Dining Table"},
+		{ShortCode: "s3", Name: "Reading Lamp"},
+	}}
+	products := []dtos.CatalogueProductDto{
+		{ShortCode: "s1", Category: "Sofas"},
+		{ShortCode: "s2", Category: "Dining Tables"},
+		{ShortCode: "s3", Category: "Lamps"},
+	}
+	return NewCatalogue(mapping, products, DefaultVocabulary())
+}
+
+func TestRuleClassifier(t *testing.T) {
+	catalogue := testCatalogue()
+	max := 500.0
+	tests := []struct {
+		name       string
+		query      IntentQuery
+		intent     string
+		shortCode  string
+		categories []string
+	}{
+		{name: "product name", query: IntentQuery{Text: "chesterfield sofa"}, intent: IntentDirect, shortCode: "s1"},
+		{name: "open ended", query: IntentQuery{Text: "something for the living room"}, intent: IntentDiscovery},
+		{name: "category", query: IntentQuery{Text: "show me all lamps"}, intent: IntentBrowse, categories: []string{"Lamps"}},
+		{
+			name:       "price narrowed to the category it names",
+			query:      IntentQuery{Text: "sofas", PriceConstraint: &dtos.PriceConstraintDto{Max: &max}},
+			intent:     IntentFilter,
+			categories: []string{"Sofas"},
+		},
+		{
+			name:       "attribute narrowed to the category it names",
+			query:      IntentQuery{Text: "red leather sofa", Attributes: map[string][]string{"color": {"red"}}},
+			intent:     IntentFilter,
+			categories: []string{"Sofas"},
+		},
+		{name: "description naming a whole category", query: IntentQuery{Text: "round dining table"}, intent: IntentFilter, categories: []string{"Dining Tables"}},
+		{name: "description naming part of a category", query: IntentQuery{Text: "walnut table"}, intent: IntentFilter},
+		{name: "long description", query: IntentQuery{Text: "soft velvet armchair with wooden legs"}, intent: IntentDiscovery},
+	}
+	classifier := NewRuleClassifier()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := classifier.Classify(test.query, catalogue)
+			if got.Intent != test.intent || got.ShortCode != test.shortCode {
+				t.Errorf("Classify = %s %q (%s), want %s %q", got.Intent, got.ShortCode, got.Reason, test.intent, test.shortCode)
+			}
+			if !reflect.DeepEqual(got.Categories, test.categories) {
+				t.Errorf("categories = %q, want %q", got.Categories, test.categories)
+			}
+		})
+	}
+}
+
+func TestNamedCategories(t *testing.T) {
+	catalogue := testCatalogue()
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"cheap sofa", []string{"Sofas"}},
+		{"show me a dining table and a lamp", []string{"Dining Tables", "Lamps"}},
+		{"dining chairs", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := catalogue.NamedCategories(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("NamedCategories(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
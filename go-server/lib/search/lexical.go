@@ -0,0 +1,127 @@
+package search
+
+import (
+	"math"
+	"sort"
+)
+
+// BM25 parameters, the usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Field weights: a term in the product name counts more than the same term
+// in its category, which counts more than one in the description.
+const (
+	nameWeight        = 3
+	categoryWeight    = 2
+	descriptionWeight = 1
+)
+
+// Document is one product as seen by the lexical index.
+type Document struct {
+	ShortCode   string
+	Name        string
+	Category    string
+	Description string
+}
+
+// Hit is a document matched by a query with its BM25 score.
+type Hit struct {
+	ShortCode string
+	Score     float64
+}
+
+type indexedDoc struct {
+	shortCode string
+	length    float64
+	terms     map[string]float64
+}
+
+// LexicalIndex is an in-memory BM25 index over product names, categories
+// and descriptions. It is immutable once built and safe for concurrent use.
+type LexicalIndex struct {
+	docs      []indexedDoc
+	postings  map[string][]int
+	avgLength float64
+}
+
+func NewLexicalIndex(documents []Document) *LexicalIndex {
+	index := &LexicalIndex{postings: make(map[string][]int)}
+	total := 0.0
+	for _, document := range documents {
+		doc := indexedDoc{shortCode: document.ShortCode, terms: make(map[string]float64)}
+		addTerms(&doc, document.Name, nameWeight)
+		addTerms(&doc, document.Category, categoryWeight)
+		addTerms(&doc, document.Description, descriptionWeight)
+		if len(doc.terms) == 0 {
+			continue
+		}
+		for term := range doc.terms {
+			index.postings[term] = append(index.postings[term], len(index.docs))
+		}
+		total += doc.length
+		index.docs = append(index.docs, doc)
+	}
+	if len(index.docs) > 0 {
+		index.avgLength = total / float64(len(index.docs))
+	}
+	return index
+}
+
+func addTerms(doc *indexedDoc, text string, weight float64) {
+	for _, token := range Tokenize(text) {
+		doc.terms[token] += weight
+		doc.length += weight
+	}
+}
+
+// Len returns the number of indexed documents.
+func (index *LexicalIndex) Len() int {
+	return len(index.docs)
+}
+
+// Search scores every document sharing a term with the query and returns
+// the best limit hits, highest score first.
+func (index *LexicalIndex) Search(query string, limit int) []Hit {
+	if len(index.docs) == 0 || limit <= 0 {
+		return nil
+	}
+	n := float64(len(index.docs))
+	scores := make(map[int]float64)
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := index.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, i := range postings {
+			doc := index.docs[i]
+			tf := doc.terms[term]
+			norm := tf + bm25K1*(1-bm25B+bm25B*doc.length/index.avgLength)
+			scores[i] += idf * tf * (bm25K1 + 1) / norm
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for i, score := range scores {
+		hits = append(hits, Hit{ShortCode: index.docs[i].shortCode, Score: score})
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].ShortCode < hits[b].ShortCode
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
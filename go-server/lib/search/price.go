@@ -0,0 +1,180 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+const (
+	// currency codes need a word boundary so "trousers" is not "rs"
+	currencyPattern = `(₹|\$|€|£|¥|\b(?:rs\.?|inr|usd|eur|gbp|jpy))`
+	numberPattern   = `(\d[\d,]*(?:\.\d+)?)(?:\s?(k)\b)?`
+)
+
+// amountPattern is an optional currency, a number with an optional "k"
+// suffix and an optional trailing currency: four capture groups.
+var amountPattern = fmt.Sprintf(`(?:%s\s*)?%s(?:\s*%s\b)?`, currencyPattern, numberPattern, currencyPattern)
+
+var (
+	betweenRe = regexp.MustCompile(`(?i)\b(?:between|from)\s+` + amountPattern + `\s*(?:and|to|-)\s*` + amountPattern)
+	rangeRe   = regexp.MustCompile(`(?i)` + amountPattern + `\s*(?:-|to)\s*` + amountPattern)
+	maxRe     = regexp.MustCompile(`(?i)(?:\b(?:under|below|less than|lesser than|cheaper than|within|not more than)|<=?)\s*` + amountPattern)
+	minRe     = regexp.MustCompile(`(?i)(?:\b(?:above|over|more than|greater than|at least|starting at|starting from)|>=?)\s*` + amountPattern)
+	// "Air Max 90" and "up to 50% off" are names and discounts, so these
+	// keywords only make a price with a currency
+	currencyMaxRe = regexp.MustCompile(`(?i)\b(?:up ?to|max|maximum)\s*` + amountPattern)
+	currencyMinRe = regexp.MustCompile(`(?i)\b(?:min|minimum)\s*` + amountPattern)
+)
+
+var currencyCodes = map[string]string{
+	"₹": "INR", "rs": "INR", "rs.": "INR", "inr": "INR",
+	"$": "USD", "usd": "USD",
+	"€": "EUR", "eur": "EUR",
+	"£": "GBP", "gbp": "GBP",
+	"¥": "JPY", "jpy": "JPY",
+}
+
+// NormalizeCurrency maps a currency symbol or code to its ISO code, or ""
+// when it is unknown.
+func NormalizeCurrency(currency string) string {
+	return currencyCodes[strings.ToLower(strings.TrimSpace(currency))]
+}
+
+// ParsePriceConstraint pulls a price constraint such as "under 1000",
+// "above $50", "between 1k and 2.5k" or "₹500-900" out of a query. It
+// returns the constraint, or nil when the query has none, and the query
+// with the price phrase removed.
+func ParsePriceConstraint(query string) (*dtos.PriceConstraintDto, string) {
+	if loc := findPrice(betweenRe, query, false); loc != nil {
+		return rangeConstraint(query, loc)
+	}
+	for _, re := range []*regexp.Regexp{maxRe, currencyMaxRe} {
+		if loc := findPrice(re, query, re == currencyMaxRe); loc != nil {
+			amount, currency := parseAmount(query, loc[2:10])
+			return &dtos.PriceConstraintDto{Max: &amount, Currency: currency, Text: query[loc[0]:loc[1]]}, removeSpan(query, loc)
+		}
+	}
+	for _, re := range []*regexp.Regexp{minRe, currencyMinRe} {
+		if loc := findPrice(re, query, re == currencyMinRe); loc != nil {
+			amount, currency := parseAmount(query, loc[2:10])
+			return &dtos.PriceConstraintDto{Min: &amount, Currency: currency, Text: query[loc[0]:loc[1]]}, removeSpan(query, loc)
+		}
+	}
+	// a bare "32-34" is more likely a size than a price, so ranges without
+	// a keyword need a currency
+	if loc := findPrice(rangeRe, query, true); loc != nil {
+		return rangeConstraint(query, loc)
+	}
+	return nil, query
+}
+
+// findPrice returns the first match of re that is not a percentage and,
+// when needCurrency is set, names a currency in one of its amounts.
+func findPrice(re *regexp.Regexp, query string, needCurrency bool) []int {
+	for _, loc := range re.FindAllStringSubmatchIndex(query, -1) {
+		if strings.HasPrefix(strings.TrimLeft(query[loc[1]:], " "), "%") {
+			continue
+		}
+		if needCurrency && !hasCurrency(query, loc) {
+			continue
+		}
+		return loc
+	}
+	return nil
+}
+
+// hasCurrency reports whether any amount of a match names a currency.
+func hasCurrency(query string, loc []int) bool {
+	for start := 2; start+8 <= len(loc); start += 8 {
+		if _, currency := parseAmount(query, loc[start:start+8]); currency != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func rangeConstraint(query string, loc []int) (*dtos.PriceConstraintDto, string) {
+	low, lowCurrency := parseAmount(query, loc[2:10])
+	high, highCurrency := parseAmount(query, loc[10:18])
+	if low > high {
+		low, high = high, low
+	}
+	currency := lowCurrency
+	if currency == "" {
+		currency = highCurrency
+	}
+	return &dtos.PriceConstraintDto{Min: &low, Max: &high, Currency: currency, Text: query[loc[0]:loc[1]]}, removeSpan(query, loc)
+}
+
+// parseAmount reads the four amount groups: leading currency, number, "k"
+// suffix and trailing currency.
+func parseAmount(query string, groups []int) (float64, string) {
+	group := func(i int) string {
+		if groups[2*i] < 0 {
+			return ""
+		}
+		return query[groups[2*i]:groups[2*i+1]]
+	}
+	amount, _ := strconv.ParseFloat(strings.ReplaceAll(group(1), ",", ""), 64)
+	if group(2) != "" {
+		amount *= 1000
+	}
+	currency := NormalizeCurrency(group(0))
+	if currency == "" {
+		currency = NormalizeCurrency(group(3))
+	}
+	return amount, currency
+}
+
+func removeSpan(query string, loc []int) string {
+	return strings.Join(strings.Fields(query[:loc[0]]+" "+query[loc[1]:]), " ")
+}
+
+// ParsePrice reads a stored catalogue price such as "1,299.00", "₹ 999" or
+// "Rs. 999". A dot before the first digit belongs to the currency, not the
+// number.
+func ParsePrice(price string) (float64, bool) {
+	var digits strings.Builder
+	for _, r := range price {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		} else if r == '.' && digits.Len() > 0 {
+			digits.WriteRune(r)
+		}
+	}
+	number := strings.TrimRight(digits.String(), ".")
+	if number == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// PriceMatches reports whether a product satisfies a price constraint.
+// Bounds are inclusive. Products without a readable price, or priced in a
+// different currency than the one asked for, never match.
+func PriceMatches(constraint *dtos.PriceConstraintDto, product dtos.CatalogueProductDto) bool {
+	price, ok := ParsePrice(product.Price)
+	if !ok {
+		return false
+	}
+	if constraint.Currency != "" {
+		if currency := NormalizeCurrency(product.Currency); currency != "" && currency != constraint.Currency {
+			return false
+		}
+	}
+	if constraint.Min != nil && price < *constraint.Min {
+		return false
+	}
+	if constraint.Max != nil && price > *constraint.Max {
+		return false
+	}
+	return true
+}
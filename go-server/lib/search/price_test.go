@@ -0,0 +1,118 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+func TestParsePriceConstraint(t *testing.T) {
+	tests := []struct {
+		query    string
+		min      float64
+		max      float64
+		currency string
+		rest     string
+		none     bool
+	}{
+		{query: "sofa under 1000", max: 1000, rest: "sofa"},
+		{query: "lamps above $50", min: 50, currency: "USD", rest: "lamps"},
+		{query: "rugs between 1k and 2.5k", min: 1000, max: 2500, rest: "rugs"},
+		{query: "kurta ₹500-900", min: 500, max: 900, currency: "INR", rest: "kurta"},
+		{query: "shoes from 900 to 500", min: 500, max: 900, rest: "shoes"},
+		{query: "bedsheet below Rs. 1,299", max: 1299, currency: "INR", rest: "bedsheet"},
+		{query: "bedsheet under rs.999", max: 999, currency: "INR", rest: "bedsheet"},
+		{query: "mug 300 INR - 500 INR", min: 300, max: 500, currency: "INR", rest: "mug"},
+		{query: "cushions within 800 rs", max: 800, currency: "INR", rest: "cushions"},
+		{query: "trousers 28-32", none: true, rest: "trousers 28-32"},
+		{query: "Trousers 500 to 900", none: true, rest: "Trousers 500 to 900"},
+		{query: "jeans 32-34", none: true, rest: "jeans 32-34"},
+		{query: "red sneakers", none: true, rest: "red sneakers"},
+		{query: "Nike Air Max 90", none: true, rest: "Nike Air Max 90"},
+		{query: "Air Max 270 shoes", none: true, rest: "Air Max 270 shoes"},
+		{query: "up to 50% off jeans", none: true, rest: "up to 50% off jeans"},
+		{query: "under 20 % off kurtas", none: true, rest: "under 20 % off kurtas"},
+		{query: "up to 50% off jeans under 1000", max: 1000, rest: "up to 50% off jeans"},
+		{query: "jeans up to ₹999", max: 999, currency: "INR", rest: "jeans"},
+		{query: "watch max $200", max: 200, currency: "USD", rest: "watch"},
+		{query: "rugs minimum 500 rs", min: 500, currency: "INR", rest: "rugs"},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			constraint, rest := ParsePriceConstraint(test.query)
+			if rest != test.rest {
+				t.Errorf("rest = %q, want %q", rest, test.rest)
+			}
+			if test.none {
+				if constraint != nil {
+					t.Fatalf("constraint = %+v, want none", constraint)
+				}
+				return
+			}
+			if constraint == nil {
+				t.Fatal("constraint = nil")
+			}
+			if got := bound(constraint.Min); got != test.min {
+				t.Errorf("min = %v, want %v", got, test.min)
+			}
+			if got := bound(constraint.Max); got != test.max {
+				t.Errorf("max = %v, want %v", got, test.max)
+			}
+			if constraint.Currency != test.currency {
+				t.Errorf("currency = %q, want %q", constraint.Currency, test.currency)
+			}
+		})
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		price string
+		want  float64
+		ok    bool
+	}{
+		{"1,299.00", 1299, true},
+		{"₹ 999", 999, true},
+		{"Rs. 999", 999, true},
+		{"Rs.1,299", 1299, true},
+		{"Rs.1,299.50", 1299.5, true},
+		{"999 Rs.", 999, true},
+		{"$19.99", 19.99, true},
+		{"", 0, false},
+		{"Rs.", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := ParsePrice(test.price)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ParsePrice(%q) = %v, %v, want %v, %v", test.price, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestPriceMatches(t *testing.T) {
+	low, high := 500.0, 1000.0
+	constraint := &dtos.PriceConstraintDto{Min: &low, Max: &high, Currency: "INR"}
+	tests := []struct {
+		product dtos.CatalogueProductDto
+		want    bool
+	}{
+		{dtos.CatalogueProductDto{Price: "Rs. 999"}, true},
+		{dtos.CatalogueProductDto{Price: "500", Currency: "INR"}, true},
+		{dtos.CatalogueProductDto{Price: "1000.00"}, true},
+		{dtos.CatalogueProductDto{Price: "1,001"}, false},
+		{dtos.CatalogueProductDto{Price: "700", Currency: "USD"}, false},
+		{dtos.CatalogueProductDto{Price: ""}, false},
+	}
+	for _, test := range tests {
+		if got := PriceMatches(constraint, test.product); got != test.want {
+			t.Errorf("PriceMatches(%+v) = %v, want %v", test.product, got, test.want)
+		}
+	}
+}
+
+func bound(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,287 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/homingos/campaign-svc/config"
+	"github.com/homingos/campaign-svc/dtos"
+)
+
+const (
+	RerankerNone         = "none"
+	RerankerLinear       = "linear"
+	RerankerCrossEncoder = "cross-encoder"
+)
+
+// RerankQuery is what a reranker knows of a query.
+type RerankQuery struct {
+	Text            string
+	PriceConstraint *dtos.PriceConstraintDto
+	// Categories are the categories the query asks for, if any.
+	Categories []string
+}
+
+// Reranked is a candidate after reranking with where retrieval had put it.
+type Reranked struct {
+	dtos.ResultItem
+	OriginalRank  int
+	OriginalScore float32
+	Features      map[string]float64
+}
+
+// Reranker reorders retrieved candidates. It returns every candidate, best
+// first, with its new score.
+type Reranker interface {
+	Name() string
+	Rerank(ctx context.Context, query RerankQuery, candidates []dtos.ResultItem, catalogue *Catalogue) ([]Reranked, error)
+}
+
+// NewRerankers returns every reranker by name, configured from conf. The
+// cross-encoder calls conf.URL, or scores locally when it is empty.
+func NewRerankers(conf config.RerankConfig) map[string]Reranker {
+	var encoder CrossEncoder = LocalCrossEncoder{}
+	if conf.URL != "" {
+		encoder = NewHTTPCrossEncoder(conf.URL, conf.APIKey, time.Duration(conf.TimeoutMs)*time.Millisecond)
+	}
+	return map[string]Reranker{
+		RerankerNone:         NoopReranker{},
+		RerankerLinear:       NewLinearReranker(conf.Weights),
+		RerankerCrossEncoder: NewCrossEncoderReranker(encoder),
+	}
+}
+
+// NoopReranker keeps the retrieval order.
+type NoopReranker struct{}
+
+func (NoopReranker) Name() string { return RerankerNone }
+
+func (NoopReranker) Rerank(ctx context.Context, query RerankQuery, candidates []dtos.ResultItem, catalogue *Catalogue) ([]Reranked, error) {
+	reranked := make([]Reranked, 0, len(candidates))
+	for i, candidate := range candidates {
+		reranked = append(reranked, Reranked{ResultItem: candidate, OriginalRank: i + 1, OriginalScore: candidate.Score})
+	}
+	return reranked, nil
+}
+
+// LinearReranker scores candidates by a weighted sum of features: the
+// retrieval score scaled to [0, 1], how much of the query the name covers,
+// how much of the product's category the query names and whether the
+// price fits the query's price range.
+type LinearReranker struct {
+	weights config.RerankWeights
+}
+
+func NewLinearReranker(weights config.RerankWeights) *LinearReranker {
+	return &LinearReranker{weights: weights}
+}
+
+func (r *LinearReranker) Name() string { return RerankerLinear }
+
+func (r *LinearReranker) Rerank(ctx context.Context, query RerankQuery, candidates []dtos.ResultItem, catalogue *Catalogue) ([]Reranked, error) {
+	queryTokens, _ := coreTokens(query.Text)
+	queryTerms := make(map[string]bool, len(queryTokens))
+	for _, token := range queryTokens {
+		queryTerms[token] = true
+	}
+	asked := make(map[string]bool, len(query.Categories))
+	for _, category := range query.Categories {
+		asked[category] = true
+	}
+
+	low, high := scoreRange(candidates)
+	reranked := make([]Reranked, 0, len(candidates))
+	for i, candidate := range candidates {
+		features := map[string]float64{"retrieval": 1}
+		if high > low {
+			features["retrieval"] = (float64(candidate.Score) - low) / (high - low)
+		}
+		features["name_overlap"] = coverage(queryTokens, Tokenize(catalogue.Names[candidate.Code]))
+
+		category := catalogue.CategoryOf(candidate.Code)
+		if asked[category] {
+			features["category_match"] = 1
+		} else if categoryTokens := Tokenize(category); len(categoryTokens) > 0 {
+			features["category_match"] = coverage(categoryTokens, queryTokens)
+		}
+
+		if query.PriceConstraint != nil {
+			if product, ok := catalogue.Products[candidate.Code]; ok && PriceMatches(query.PriceConstraint, product) {
+				features["price_fit"] = 1
+			}
+		}
+
+		score := r.weights.Retrieval*features["retrieval"] +
+			r.weights.NameOverlap*features["name_overlap"] +
+			r.weights.CategoryMatch*features["category_match"] +
+			r.weights.PriceFit*features["price_fit"]
+		reranked = append(reranked, Reranked{
+			ResultItem:    dtos.ResultItem{Code: candidate.Code, Name: candidate.Name, Score: float32(score), VectorScore: candidate.VectorScore},
+			OriginalRank:  i + 1,
+			OriginalScore: candidate.Score,
+			Features:      features,
+		})
+	}
+	sortReranked(reranked)
+	return reranked, nil
+}
+
+// CrossEncoder scores how well each document answers a query, one score
+// per document in order.
+type CrossEncoder interface {
+	Score(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// CrossEncoderReranker orders candidates by a cross-encoder's score of the
+// query against each product's name, category and description.
+type CrossEncoderReranker struct {
+	encoder CrossEncoder
+}
+
+func NewCrossEncoderReranker(encoder CrossEncoder) *CrossEncoderReranker {
+	return &CrossEncoderReranker{encoder: encoder}
+}
+
+func (r *CrossEncoderReranker) Name() string { return RerankerCrossEncoder }
+
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query RerankQuery, candidates []dtos.ResultItem, catalogue *Catalogue) ([]Reranked, error) {
+	if len(candidates) == 0 {
+		return []Reranked{}, nil
+	}
+	documents := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		parts := []string{catalogue.Names[candidate.Code]}
+		if product, ok := catalogue.Products[candidate.Code]; ok {
+			parts = append(parts, product.Category, product.Description)
+		}
+		documents = append(documents, strings.Join(parts, ". "))
+	}
+	scores, err := r.encoder.Score(ctx, query.Text, documents)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(candidates) {
+		return nil, fmt.Errorf("cross-encoder returned %d scores for %d candidates", len(scores), len(candidates))
+	}
+	reranked := make([]Reranked, 0, len(candidates))
+	for i, candidate := range candidates {
+		reranked = append(reranked, Reranked{
+			ResultItem:    dtos.ResultItem{Code: candidate.Code, Name: candidate.Name, Score: float32(scores[i]), VectorScore: candidate.VectorScore},
+			OriginalRank:  i + 1,
+			OriginalScore: candidate.Score,
+		})
+	}
+	sortReranked(reranked)
+	return reranked, nil
+}
+
+// HTTPCrossEncoder posts {"query", "documents"} and reads {"scores"}.
+type HTTPCrossEncoder struct {
+	url     string
+	apiKey  string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func NewHTTPCrossEncoder(url string, apiKey string, timeout time.Duration) *HTTPCrossEncoder {
+	return &HTTPCrossEncoder{url: url, apiKey: apiKey, timeout: timeout, client: &http.Client{}}
+}
+
+func (e *HTTPCrossEncoder) Score(ctx context.Context, query string, documents []string) ([]float64, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	body, err := json.Marshal(map[string]interface{}{"query": query, "documents": documents})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", e.apiKey)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("reranker returned %d: %s", resp.StatusCode, data)
+	}
+	var response struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return response.Scores, nil
+}
+
+// LocalCrossEncoder stands in for a cross-encoder offline: a document
+// scores the share of query terms it contains.
+type LocalCrossEncoder struct{}
+
+func (LocalCrossEncoder) Score(ctx context.Context, query string, documents []string) ([]float64, error) {
+	queryTokens, _ := coreTokens(query)
+	scores := make([]float64, 0, len(documents))
+	for _, document := range documents {
+		scores = append(scores, coverage(queryTokens, Tokenize(document)))
+	}
+	return scores, nil
+}
+
+// coverage is the share of wanted tokens found in tokens.
+func coverage(wanted []string, tokens []string) float64 {
+	if len(wanted) == 0 {
+		return 0
+	}
+	present := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		present[token] = true
+	}
+	found := 0
+	for _, token := range wanted {
+		if present[token] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(wanted))
+}
+
+func scoreRange(candidates []dtos.ResultItem) (float64, float64) {
+	if len(candidates) == 0 {
+		return 0, 0
+	}
+	low, high := float64(candidates[0].Score), float64(candidates[0].Score)
+	for _, candidate := range candidates[1:] {
+		score := float64(candidate.Score)
+		if score < low {
+			low = score
+		}
+		if score > high {
+			high = score
+		}
+	}
+	return low, high
+}
+
+// sortReranked orders by the new score, ties keeping retrieval order.
+func sortReranked(reranked []Reranked) {
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+}
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/homingos/campaign-svc/dtos"
+	"github.com/homingos/campaign-svc/models"
+)
+
+// Synonym directions: a one-way entry expands its term to its synonyms, a
+// two-way entry expands each of its synonyms to all the others.
+const (
+	SynonymOneWay = "one_way"
+	SynonymTwoWay = "two_way"
+)
+
+// expansion adds targets to a query containing the phrase of term.
+type expansion struct {
+	term    string
+	tokens  []string
+	targets []string
+}
+
+// SynonymDictionary expands queries with the synonyms of one site code.
+// Phrases are matched on tokens, so "T-Shirts" in a query matches the
+// dictionary term "t shirt".
+type SynonymDictionary struct {
+	expansions []expansion
+}
+
+func NewSynonymDictionary(entries []models.Synonym) *SynonymDictionary {
+	dictionary := &SynonymDictionary{}
+	for _, entry := range entries {
+		switch entry.Direction {
+		case SynonymOneWay:
+			dictionary.add(entry.Term, entry.Synonyms)
+		case SynonymTwoWay:
+			for i, term := range entry.Synonyms {
+				others := make([]string, 0, len(entry.Synonyms)-1)
+				others = append(others, entry.Synonyms[:i]...)
+				others = append(others, entry.Synonyms[i+1:]...)
+				dictionary.add(term, others)
+			}
+		}
+	}
+	return dictionary
+}
+
+func (d *SynonymDictionary) add(term string, targets []string) {
+	tokens := Tokenize(term)
+	if len(tokens) == 0 {
+		return
+	}
+	d.expansions = append(d.expansions, expansion{term: strings.TrimSpace(term), tokens: tokens, targets: targets})
+}
+
+// Expand appends to text the synonyms of every dictionary phrase it
+// contains. Expansion is a single pass, so added synonyms are not expanded
+// again, and synonyms the query already holds are not added. It returns
+// the expanded text and the phrases that added something; text comes back
+// unchanged when nothing applies.
+func (d *SynonymDictionary) Expand(text string) (string, []dtos.AppliedSynonymDto) {
+	if d == nil || len(d.expansions) == 0 {
+		return text, nil
+	}
+	tokens := Tokenize(text)
+	added := make(map[string]bool)
+	var additions []string
+	var applied []dtos.AppliedSynonymDto
+	for _, e := range d.expansions {
+		if !containsPhrase(tokens, e.tokens) {
+			continue
+		}
+		var expansions []string
+		for _, target := range e.targets {
+			targetTokens := Tokenize(target)
+			key := strings.Join(targetTokens, " ")
+			if len(targetTokens) == 0 || added[key] || containsPhrase(tokens, targetTokens) {
+				continue
+			}
+			added[key] = true
+			expansions = append(expansions, strings.TrimSpace(target))
+		}
+		if len(expansions) > 0 {
+			applied = append(applied, dtos.AppliedSynonymDto{Term: e.term, Expansions: expansions})
+			additions = append(additions, expansions...)
+		}
+	}
+	if len(additions) == 0 {
+		return text, nil
+	}
+	return text + " " + strings.Join(additions, " "), applied
+}
+
+type cachedDictionary struct {
+	dictionary *SynonymDictionary
+	cachedAt   time.Time
+}
+
+// SynonymCache keeps the dictionary of each site code for a TTL, so edits
+// made through another instance are picked up without a restart.
+type SynonymCache struct {
+	ttl          time.Duration
+	mu           sync.RWMutex
+	dictionaries map[string]cachedDictionary
+}
+
+func NewSynonymCache(ttl time.Duration) *SynonymCache {
+	return &SynonymCache{ttl: ttl, dictionaries: make(map[string]cachedDictionary)}
+}
+
+// Get returns the dictionary of a site code unless it has expired.
+func (c *SynonymCache) Get(siteCode string) (*SynonymDictionary, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.dictionaries[siteCode]
+	if !ok || time.Since(cached.cachedAt) >= c.ttl {
+		return nil, false
+	}
+	return cached.dictionary, true
+}
+
+// Put stores the dictionary of a site code.
+func (c *SynonymCache) Put(siteCode string, dictionary *SynonymDictionary) {
+	c.mu.Lock()
+	c.dictionaries[siteCode] = cachedDictionary{dictionary: dictionary, cachedAt: time.Now()}
+	c.mu.Unlock()
+}
+
+// Invalidate drops the dictionary of a site code.
+func (c *SynonymCache) Invalidate(siteCode string) {
+	c.mu.Lock()
+	delete(c.dictionaries, siteCode)
+	c.mu.Unlock()
+}
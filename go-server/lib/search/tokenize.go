@@ -0,0 +1,52 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are dropped from queries and documents; they carry no product
+// signal and would only dilute BM25 scores.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "for": true,
+	"with": true, "in": true, "on": true, "to": true, "me": true, "my": true,
+	"some": true, "any": true, "i": true, "want": true, "need": true,
+}
+
+// Tokenize lower cases text, splits it on anything that is not a letter or
+// a digit and drops stop words. Possessives and simple plurals are folded
+// so "Men's jeans" and "men jean" produce the same tokens. Tokens are only
+// compared with each other, so folded forms need not be real words.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		token := stem(field)
+		if token == "" || stopWords[token] {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func stem(token string) string {
+	// "men's" splits into "men" and "s" on the apostrophe
+	if token == "s" {
+		return ""
+	}
+	switch {
+	// "hoodies" and "hoodie", "accessories" and "accessory" all end in "ie"
+	case len(token) > 4 && strings.HasSuffix(token, "ies"):
+		return token[:len(token)-1]
+	case len(token) > 3 && strings.HasSuffix(token, "y") && !strings.ContainsAny(token[len(token)-2:len(token)-1], "aeiou"):
+		return token[:len(token)-1] + "ie"
+	case len(token) > 3 && strings.HasSuffix(token, "es") && strings.ContainsAny(token[len(token)-3:len(token)-2], "sxz"):
+		return token[:len(token)-2]
+	case len(token) > 3 && strings.HasSuffix(token, "s") && !strings.HasSuffix(token, "ss"):
+		return token[:len(token)-1]
+	}
+	return token
+}
@@ -1,112 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
-	"encoding/csv"
-	"strings"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/homingos/campaign-svc/config"
 	daos "github.com/homingos/campaign-svc/daos"
+	"github.com/homingos/campaign-svc/dtos"
 	"github.com/homingos/campaign-svc/handlers"
+	"github.com/homingos/campaign-svc/lib/embedding"
+	"github.com/homingos/campaign-svc/lib/eval"
 	"github.com/homingos/campaign-svc/lib/nats"
 	redisStorage "github.com/homingos/campaign-svc/lib/redis"
+	"github.com/homingos/campaign-svc/lib/search"
 	"github.com/homingos/campaign-svc/lib/transaction"
+	"github.com/homingos/campaign-svc/types/consts"
+	"github.com/homingos/campaign-svc/utils"
 	"github.com/homingos/flam-go-common/authz"
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"go.uber.org/zap"
 )
 
-type ResultItem struct {
-	Code  string  `json:"code"`
-	Name  string  `json:"name"`
-	Score float32 `json:"score"`
-}
-
-type ShortCodeMapping struct {
-	ShortCode  string `bson:"short_code" json:"short_code"`
-	Name       string `bson:"name" json:"name"`
-	CampaignID string `bson:"_id" json:"campaign_id,omitempty"`
-}
-
-type MappingData struct {
-	SiteCode    string             `json:"site_code"`
-	Mappings    []ShortCodeMapping `json:"mappings"`
-	LastUpdated string             `json:"last_updated"`
-	Count       int                `json:"count"`
-}
-
-func stripMilvusRefNo(name string) string {
-
-	parts := strings.Split(name," - ")
-	if len(parts) > 1 {
-		return parts[0]
-	}
-    return name
-}
-
-func appendToJSON(fileName string, text string, newData []byte) {
-	var existingData map[string]interface{}
-	fileContent, err := os.ReadFile(fileName)
-
-	if err == nil && len(fileContent) > 0 {
-		_ = json.Unmarshal(fileContent, &existingData)
-	}
-    if existingData == nil {
-        existingData = make(map[string]interface{})
-    }
-	existingData[text] = json.RawMessage(newData)
-
-	updatedData, err := json.MarshalIndent(existingData, "", "  ")
-	if err != nil {
-		fmt.Println("Error marshaling updated data:", err)
-		return
-	}
-
-	err = os.WriteFile(fileName, updatedData, 0644)
-	if err != nil {
-		fmt.Println("Error writing to file:", err)
-	}
-}
-
-func appendToCSV(fileName string, text string, results []ResultItem) {
-    file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-    if err != nil {
-        fmt.Println("Error opening CSV file:", err)
-        return
-    }
-    defer file.Close()
-
-    writer := csv.NewWriter(file)
-    defer writer.Flush()
-
-    // Add header if file is new
-    info, err := file.Stat()
-    if err == nil && info.Size() == 0 {
-        writer.Write([]string{"Topic", "Codes", "Names", "Scores"})
-    }
-
-    var codes, names, scores []string
-    for _, r := range results {
-        codes = append(codes, r.Code)
-        names = append(names, r.Name)
-        scores = append(scores, fmt.Sprintf("%.4f", r.Score))
-    }
-
-    writer.Write([]string{
-        text,
-        strings.Join(codes, ","),
-        strings.Join(names, ","),
-        strings.Join(scores, ","),
-    })
-}
-
 func main() {
-	const mappingFile = "mapping.json"
-
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 	lgr := logger.Sugar()
@@ -119,23 +39,42 @@ func main() {
 
 	redisClient := redisStorage.NewRedisClient(lgr)
 
-	// init mlivus client
-	milvusClient := config.ConfigureMilvusDatabase(appConfig.Milvus.Host, appConfig.Milvus.Key)
+	// init vector store, Milvus unless running from an in-memory fixture
+	var milvusClient client.Client
+	var vectorStore daos.VectorStore
+	switch appConfig.VectorStore.Backend {
+	case daos.VectorStoreMemory:
+		memoryStore, err := daos.NewSeededMemoryVectorStore(lgr, appConfig.VectorStore.FixturePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		vectorStore = memoryStore
+	case daos.VectorStoreMilvus, "":
+		milvusClient = config.ConfigureMilvusDatabase(appConfig.Milvus.Host, appConfig.Milvus.Key).Client
+		vectorStore = daos.NewMilvusDao(lgr, milvusClient, appConfig.Milvus.Collection(appConfig.ENV), appConfig.Milvus.SearchCollection())
+	default:
+		log.Fatalf("unknown vector store %q, expected milvus or memory", appConfig.VectorStore.Backend)
+	}
 
 	// init daos
 	campaignDao := daos.NewCampaignDao(lgr, db)
 	categoryDao := daos.NewCategoryDao(lgr, db)
 	experienceDao := daos.NewExperienceDao(lgr, db, redisClient)
 	templateDao := daos.NewTemplateDao(lgr, db)
-	milvusDao := daos.NewMilvusDao(lgr, milvusClient.Client)
+	synonymDao := daos.NewSynonymDao(lgr, db)
+	calibrationDao := daos.NewCalibrationDao(lgr, db)
+	goldenSetDao := daos.NewGoldenSetDao(lgr, db)
+	mappingStore := daos.NewMappingStore(lgr, appConfig.MappingStore.Backend, time.Duration(appConfig.MappingStore.CacheTTLSeconds)*time.Second, db, redisClient)
 
 	// init transaction manager
 	mongoClient := db.Client() // Get the underlying mongo client from database
 	txManager := transaction.NewTransactionManager(mongoClient)
 
+	vocabularies := search.NewVocabularyStore(appConfig.Search.VocabularyDir)
+
 	// init NATS client
 	var natsClient *nats.Client
-	natsClient, err := nats.NewClient(experienceDao, campaignDao, nil, redisClient, categoryDao, templateDao, txManager, nil, lgr)
+	natsClient, err := nats.NewClient(experienceDao, campaignDao, nil, redisClient, categoryDao, templateDao, txManager, nil, mappingStore, vocabularies, lgr)
 	if err != nil {
 		lgr.Warnf("Failed to initialize NATS client: %v", err)
 	} else {
@@ -144,10 +83,23 @@ func main() {
 
 	fgaClient := &authz.OpenFGAClient{} // Configure based on your OpenFGA setup
 
+	embeddingProvider, err := embedding.NewProvider(appConfig.EmbeddingModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	embeddingCache := embedding.NewCache(
+		embeddingProvider,
+		redisClient,
+		appConfig.EmbeddingModel.CacheSize,
+		time.Duration(appConfig.EmbeddingModel.CacheTTLSeconds)*time.Second,
+		appConfig.EmbeddingModel.ModelName,
+		appConfig.EmbeddingModel.Dimension,
+	)
+
 	categorySvc := handlers.NewCategorySvc(
 		lgr,
 		redisClient,
-		milvusClient.Client,
+		milvusClient,
 		categoryDao,
 		campaignDao,
 		experienceDao,
@@ -155,9 +107,25 @@ func main() {
 		natsClient,
 		txManager,
 		fgaClient,
-		milvusDao,
+		vectorStore,
+		eval.NewRunStore(consts.EvalRunsDir),
+		mappingStore,
+		appConfig.Search,
+		vocabularies,
+		search.NewRuleClassifier(),
+		embeddingCache,
+		synonymDao,
+		calibrationDao,
+		goldenSetDao,
 	)
 
+	// keep cached mappings and eval runs in step with catalogue ingestion
+	if natsClient != nil {
+		if err := natsClient.SubscribeMappingUpdates(categorySvc.MappingUpdatedSvc); err != nil {
+			lgr.Warnf("Failed to subscribe to mapping updates: %v", err)
+		}
+	}
+
 	app := fiber.New()
 
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -172,7 +140,7 @@ func main() {
 			})
 		}
 
-		categoryData, appErr := categorySvc.GetCategoriesBySiteCodeSvc(c.Context(), siteCode, "")
+		mappingData, appErr := categorySvc.GenerateMappingsSvc(c.Context(), siteCode)
 		if appErr != nil {
 			return c.Status(appErr.StatusCode).JSON(fiber.Map{
 				"error":   appErr.Message,
@@ -180,151 +148,476 @@ func main() {
 			})
 		}
 
-		var mappings []ShortCodeMapping
-
-		if categoryDataMap, ok := categoryData.(map[string]interface{}); ok {
-			if categories, ok := categoryDataMap["categories"].([]interface{}); ok {
-				for _, cat := range categories {
-					if catMap, ok := cat.(map[string]interface{}); ok {
-						if campaigns, ok := catMap["campaigns"].([]interface{}); ok {
-							for _, camp := range campaigns {
-								if campMap, ok := camp.(map[string]interface{}); ok {
-									mapping := ShortCodeMapping{
-										ShortCode:  fmt.Sprintf("%v", campMap["short_code"]),
-										Name:       fmt.Sprintf("%v", campMap["name"]),
-										CampaignID: fmt.Sprintf("%v", campMap["_id"]),
-									}
-									mappings = append(mappings, mapping)
-								}
-							}
-						}
-					}
-				}
-			}
-		}
+		return c.JSON(fiber.Map{
+			"message":      "Mappings generated successfully",
+			"store":        appConfig.MappingStore.Backend,
+			"site_code":    siteCode,
+			"version":      mappingData.Version,
+			"count":        mappingData.Count,
+			"last_updated": mappingData.LastUpdated,
+		})
+	})
 
-		if len(mappings) == 0 {
-			return c.Status(404).JSON(fiber.Map{
-				"error": "No campaigns found for site code: " + siteCode,
-			})
-		}
-		// print mappings for debugging
-		fmt.Println("Mappings for site code:", siteCode)
-		// update mapping names by stripping Milvus reference numbers
-		for idx, _ := range mappings {
-			mappings[idx].Name = stripMilvusRefNo(mappings[idx].Name)
+	app.Get("/vector-store/state", func(c *fiber.Ctx) error {
+		state, err := vectorStore.LoadState(c.Context())
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
+		return c.JSON(state)
+	})
 
+	app.Get("/embeddings/cache/stats", func(c *fiber.Ctx) error {
+		return c.JSON(embeddingCache.Stats())
+	})
 
-		mappingData := MappingData{
-			SiteCode:    siteCode,
-			Mappings:    mappings,
-			LastUpdated: time.Now().Format(time.RFC3339),
-			Count:       len(mappings),
+	app.Get("/mappings/:sitecode", func(c *fiber.Ctx) error {
+		mappingData, appErr := categorySvc.LoadMappingSvc(c.Context(), c.Params("sitecode"), c.QueryInt("version", daos.LatestMappingVersion))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
 		}
+		return c.JSON(mappingData)
+	})
 
-		mappingFile := "mapping_" + siteCode + ".json"
+	app.Get("/synonyms/:sitecode", func(c *fiber.Ctx) error {
+		synonyms, appErr := categorySvc.GetSynonymsSvc(c.Context(), c.Params("sitecode"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(synonyms)
+	})
 
-		jsonData, err := json.MarshalIndent(mappingData, "", "  ")
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Failed to marshal mappings",
+	app.Post("/synonyms/:sitecode", func(c *fiber.Ctx) error {
+		var synonymDto dtos.SynonymDto
+		if err := c.BodyParser(&synonymDto); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid synonym",
 				"details": err.Error(),
 			})
 		}
+		synonym, appErr := categorySvc.CreateSynonymSvc(c.Context(), c.Params("sitecode"), synonymDto)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.Status(201).JSON(synonym)
+	})
 
-		err = os.WriteFile(mappingFile, jsonData, 0644)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Failed to write mapping file",
+	app.Put("/synonyms/:sitecode/:id", func(c *fiber.Ctx) error {
+		var synonymDto dtos.SynonymDto
+		if err := c.BodyParser(&synonymDto); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid synonym",
 				"details": err.Error(),
 			})
 		}
+		synonym, appErr := categorySvc.UpdateSynonymSvc(c.Context(), c.Params("sitecode"), c.Params("id"), synonymDto)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(synonym)
+	})
 
-		return c.JSON(fiber.Map{
-			"message":      "Mappings generated successfully",
-			"file":         mappingFile,
-			"site_code":    siteCode,
-			"count":        len(mappings),
-			"last_updated": mappingData.LastUpdated,
-		})
+	app.Delete("/synonyms/:sitecode/:id", func(c *fiber.Ctx) error {
+		if appErr := categorySvc.DeleteSynonymSvc(c.Context(), c.Params("sitecode"), c.Params("id")); appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.SendStatus(204)
 	})
 
 	app.Get("/campaigns/:sitecode", func(c *fiber.Ctx) error {
 		siteCode := c.Params("sitecode")
 		text := c.Query("text", "")
-
-		mappingFile := "mapping_" + siteCode + ".json"
-		mappingData, err := os.ReadFile(mappingFile)
-		if err != nil {
-			return c.Status(404).JSON(fiber.Map{
-				"error": "Mapping file not found. Please generate mappings first.",
-			})
+		runID := c.Query("run_id")
+		params := dtos.SearchParamsDto{
+			MappingVersion: c.QueryInt("mapping_version", daos.LatestMappingVersion),
+			Mode:           c.Query("mode"),
+			Intent:         c.Query("intent"),
+			TopK:           c.QueryInt("top_k"),
+			Offset:         c.QueryInt("offset"),
+			Limit:          c.QueryInt("limit"),
+			Reranker:       c.Query("reranker"),
+			Explain:        c.QueryBool("explain"),
+			ClientID:       c.Query("client_id"),
+			Categories:     utils.SplitList(c.Query("categories")),
+			ProductIDs:     utils.SplitList(c.Query("product_ids")),
 		}
-
-		var mappingInfo MappingData
-		if err := json.Unmarshal(mappingData, &mappingInfo); err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to parse mapping file",
-			})
+		if minScore := c.Query("min_score"); minScore != "" {
+			score, err := strconv.ParseFloat(minScore, 64)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "min_score must be a number"})
+			}
+			params.MinScore = &score
+		}
+		if minConfidence := c.Query("min_confidence"); minConfidence != "" {
+			confidence, err := strconv.ParseFloat(minConfidence, 64)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "min_confidence must be a number"})
+			}
+			params.MinConfidence = &confidence
 		}
 
-		shortCodeToName := make(map[string]string)
-		for _, mapping := range mappingInfo.Mappings {
-			shortCodeToName[mapping.ShortCode] = mapping.Name
+		// queries recorded into a run use the mapping and mode the run was created with
+		if runID != "" {
+			run, appErr := categorySvc.GetEvalRunSvc(runID)
+			if appErr != nil {
+				return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+			}
+			if params.MappingVersion == daos.LatestMappingVersion {
+				params.MappingVersion = run.Manifest.Config.MappingVersion
+			}
+			if params.Mode == "" {
+				params.Mode = run.Manifest.Config.Mode
+			}
+			if params.Reranker == "" {
+				params.Reranker = run.Manifest.Config.Reranker
+			}
 		}
 
-		var results []ResultItem
 		queryKey := text
 		if queryKey == "" {
 			queryKey = "all_campaigns"
 		}
 
-		if text != "" {
-			embeddings, err := handlers.GetEmbeddings(text)
-			if err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Failed to get embeddings"})
+		outcome, appErr := categorySvc.SearchCampaignsSvc(c.Context(), siteCode, text, params)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+
+		if runID != "" {
+			if appErr := categorySvc.RecordEvalRunSvc(runID, siteCode, text, outcome.Results); appErr != nil {
+				return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
 			}
+		}
 
-			milvusDocs, err := milvusDao.Search(c.Context(), embeddings, siteCode)
-			if err != nil {
-				return c.Status(500).JSON(fiber.Map{"error": "Milvus search failed"})
+		response := fiber.Map{queryKey: outcome.Results}
+		if outcome.PriceConstraint != nil {
+			response["price_constraint"] = outcome.PriceConstraint
+		}
+		if outcome.Intent != "" {
+			response["intent"] = outcome.Intent
+		}
+		if outcome.ExpandedQuery != "" {
+			response["expanded_query"] = outcome.ExpandedQuery
+			response["synonyms"] = outcome.Synonyms
+		}
+		if len(outcome.Attributes) > 0 {
+			response["attributes"] = outcome.Attributes
+		}
+		if outcome.Page != nil {
+			response["page"] = outcome.Page
+		}
+		if outcome.Rerank != nil {
+			response["rerank"] = outcome.Rerank
+		}
+		if outcome.Explain != nil {
+			response["explain"] = outcome.Explain
+		}
+		if outcome.NoConfidentMatch {
+			response["no_confident_match"] = true
+		}
+		return c.JSON(response)
+	})
+
+	app.Post("/campaigns/:sitecode/batch", func(c *fiber.Ctx) error {
+		siteCode := c.Params("sitecode")
+
+		var req dtos.BatchSearchRequestDto
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid batch request",
+				"details": err.Error(),
+			})
+		}
+
+		response, appErr := categorySvc.SearchCampaignsBatchSvc(c.Context(), siteCode, &req)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(response)
+	})
+
+	app.Post("/eval-runs/:sitecode", func(c *fiber.Ctx) error {
+		siteCode := c.Params("sitecode")
+
+		var runConfig eval.RunConfig
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&runConfig); err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Invalid run config",
+					"details": err.Error(),
+				})
 			}
+		}
+
+		manifest, appErr := categorySvc.CreateEvalRunSvc(c.Context(), siteCode, runConfig)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(manifest)
+	})
+
+	app.Get("/eval-runs/:sitecode/:runID", func(c *fiber.Ctx) error {
+		run, appErr := categorySvc.GetEvalRunSvc(c.Params("runID"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		if run.Manifest.SiteCode != c.Params("sitecode") {
+			return c.Status(404).JSON(fiber.Map{"error": "Evaluation run not found for site code"})
+		}
+		return c.JSON(run)
+	})
+
+	app.Get("/eval-runs/:sitecode/:runID/report", func(c *fiber.Ctx) error {
+		report, appErr := categorySvc.GetEvalReportSvc(c.Params("sitecode"), c.Params("runID"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		if c.Query("format", "html") == "json" {
+			return c.JSON(report)
+		}
+
+		var body bytes.Buffer
+		if err := eval.WriteHTMLReport(&body, report); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(body.Bytes())
+	})
+
+	app.Get("/eval-runs/:sitecode/:runID/compare/:baseRunID", func(c *fiber.Ctx) error {
+		comparison, appErr := categorySvc.CompareEvalRunsSvc(c.Params("sitecode"), c.Params("baseRunID"), c.Params("runID"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		if c.Query("format", "html") == "json" {
+			return c.JSON(comparison)
+		}
+
+		var body bytes.Buffer
+		if err := eval.WriteHTMLComparison(&body, comparison); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(body.Bytes())
+	})
+
+	app.Get("/golden-sets/:sitecode", func(c *fiber.Ctx) error {
+		set, appErr := categorySvc.GetGoldenSetSvc(c.Context(), c.Params("sitecode"), c.QueryInt("version", daos.LatestGoldenSetVersion))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(set)
+	})
+
+	app.Get("/golden-sets/:sitecode/versions", func(c *fiber.Ctx) error {
+		versions, appErr := categorySvc.GetGoldenSetVersionsSvc(c.Context(), c.Params("sitecode"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(versions)
+	})
+
+	app.Get("/golden-sets/:sitecode/export", func(c *fiber.Ctx) error {
+		siteCode := c.Params("sitecode")
+		set, appErr := categorySvc.GetGoldenSetSvc(c.Context(), siteCode, c.QueryInt("version", daos.LatestGoldenSetVersion))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+
+		var body bytes.Buffer
+		var err error
+		format := c.Query("format", "csv")
+		switch format {
+		case "csv":
+			c.Set(fiber.HeaderContentType, "text/csv")
+			err = eval.WriteGoldenCSV(&body, set.Questions)
+		case "jsonl":
+			c.Set(fiber.HeaderContentType, "application/x-ndjson")
+			err = eval.WriteGoldenJSONL(&body, set.Questions)
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "format must be csv or jsonl"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("golden_%s_v%d.%s", siteCode, set.Version, format)))
+		return c.Send(body.Bytes())
+	})
+
+	app.Post("/golden-sets/:sitecode/import", func(c *fiber.Ctx) error {
+		var questions []dtos.GoldenQuestionDto
+		var err error
+		switch c.Query("format", "csv") {
+		case "csv":
+			questions, err = eval.ParseGoldenCSV(bytes.NewReader(c.Body()))
+		case "jsonl":
+			questions, err = eval.ParseGoldenJSONL(bytes.NewReader(c.Body()))
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "format must be csv or jsonl"})
+		}
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Failed to parse golden questions",
+				"details": err.Error(),
+			})
+		}
+
+		change, appErr := categorySvc.ImportGoldenSetSvc(c.Context(), c.Params("sitecode"), questions, c.QueryBool("replace"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.Status(201).JSON(change)
+	})
+
+	app.Post("/golden-sets/:sitecode/generate", func(c *fiber.Ctx) error {
+		options := eval.GenerateOptions{
+			MaxPerIntent: c.QueryInt("max_per_intent", consts.DefaultGeneratedPerIntent),
+			MaxExpected:  c.QueryInt("max_expected", consts.DefaultGeneratedExpected),
+		}
+		generated, appErr := categorySvc.GenerateQuestionsSvc(c.Context(), c.Params("sitecode"), options, c.QueryBool("save"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		if generated.Saved != nil {
+			return c.Status(201).JSON(generated)
+		}
+		return c.JSON(generated)
+	})
+
+	app.Post("/golden-sets/:sitecode/questions", func(c *fiber.Ctx) error {
+		var questionDto dtos.GoldenQuestionDto
+		if err := c.BodyParser(&questionDto); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid golden question",
+				"details": err.Error(),
+			})
+		}
+		change, appErr := categorySvc.CreateGoldenQuestionSvc(c.Context(), c.Params("sitecode"), questionDto)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.Status(201).JSON(change)
+	})
+
+	app.Put("/golden-sets/:sitecode/questions/:id", func(c *fiber.Ctx) error {
+		var questionDto dtos.GoldenQuestionDto
+		if err := c.BodyParser(&questionDto); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid golden question",
+				"details": err.Error(),
+			})
+		}
+		change, appErr := categorySvc.UpdateGoldenQuestionSvc(c.Context(), c.Params("sitecode"), c.Params("id"), questionDto)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(change)
+	})
+
+	app.Delete("/golden-sets/:sitecode/questions/:id", func(c *fiber.Ctx) error {
+		change, appErr := categorySvc.DeleteGoldenQuestionSvc(c.Context(), c.Params("sitecode"), c.Params("id"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(change)
+	})
 
-			// Map Milvus results back to short codes and names
-			for _, doc := range milvusDocs {
-				// Find short code for this milvus reference
-				scList, err := campaignDao.GetShortcodesByMilvusRefID([]string{doc.ID})
-				if err == nil && len(scList) > 0 {
-					shortCode := scList[0]
-					results = append(results, ResultItem{
-						Code:  shortCode,
-						Name:  shortCodeToName[shortCode],
-						Score: doc.Score,
-					})
+	// requestQuestions reads the labeled questions of an evaluation,
+	// labels or calibration request: a stored golden set version when
+	// golden_set_version is set, else the request body, else the bundled
+	// questions file, recording which in runConfig. When they can't be
+	// read it returns what sends the error response instead.
+	requestQuestions := func(c *fiber.Ctx, siteCode string, runConfig *eval.RunConfig) ([]eval.LabeledQuestion, func() error) {
+		var questions []eval.LabeledQuestion
+		var err error
+		if c.Query("golden_set_version") != "" {
+			goldenQuestions, version, appErr := categorySvc.GoldenQuestionsSvc(c.Context(), siteCode, c.QueryInt("golden_set_version", daos.LatestGoldenSetVersion))
+			if appErr != nil {
+				return nil, func() error {
+					return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
 				}
 			}
+			questions, runConfig.GoldenSetVersion = goldenQuestions, version
+		} else if len(c.Body()) > 0 {
+			questions, err = eval.ParseQuestions(bytes.NewReader(c.Body()))
 		} else {
-			for _, m := range mappingInfo.Mappings {
-				results = append(results, ResultItem{
-					Code:  m.ShortCode,
-					Name:  m.Name,
-					Score: 0,
+			// only the bundled questions file is read from disk, other
+			// question sets come as the body or a golden set
+			runConfig.QuestionsFile = consts.DefaultQuestionsFile
+			questions, err = eval.LoadQuestions(runConfig.QuestionsFile)
+		}
+		if err != nil {
+			return nil, func() error {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Failed to parse labeled questions",
+					"details": err.Error(),
 				})
 			}
 		}
+		return questions, nil
+	}
 
-		resultsJSON, err := json.MarshalIndent(results, "", "  ")
-		if err != nil {
-			lgr.Errorf("Failed to marshal results: %v", err)
-		} else {
-            appendToJSON("short_code_output.json",text,resultsJSON)
-			appendToCSV("short_code_output.csv", text, results)
-        }
+	app.Post("/evaluations/:sitecode", func(c *fiber.Ctx) error {
+		siteCode := c.Params("sitecode")
+		runConfig := eval.RunConfig{
+			K:        c.QueryInt("k", consts.DefaultEvalK),
+			Source:   "evaluations",
+			Mode:     c.Query("mode"),
+			Reranker: c.Query("reranker"),
+		}
 
-		return c.JSON(map[string][]ResultItem{
-			queryKey: results,
-		})
+		questions, respond := requestQuestions(c, siteCode, &runConfig)
+		if respond != nil {
+			return respond()
+		}
+
+		report, appErr := categorySvc.EvaluateSearchSvc(c.Context(), siteCode, questions, runConfig)
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(report)
+	})
+
+	app.Post("/evaluations/:sitecode/labels", func(c *fiber.Ctx) error {
+		siteCode := c.Params("sitecode")
+		questions, respond := requestQuestions(c, siteCode, &eval.RunConfig{})
+		if respond != nil {
+			return respond()
+		}
+
+		labels, appErr := categorySvc.ResolveLabelsSvc(c.Context(), siteCode, questions, c.QueryInt("mapping_version", daos.LatestMappingVersion))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(labels)
+	})
+
+	app.Post("/calibrations/:sitecode", func(c *fiber.Ctx) error {
+		siteCode := c.Params("sitecode")
+		runConfig := eval.RunConfig{
+			K:        c.QueryInt("k", consts.DefaultCalibrationDepth),
+			Source:   "calibrations",
+			Mode:     c.Query("mode"),
+			Reranker: c.Query("reranker"),
+		}
+
+		questions, respond := requestQuestions(c, siteCode, &runConfig)
+		if respond != nil {
+			return respond()
+		}
+
+		calibration, appErr := categorySvc.CalibrateScoresSvc(c.Context(), siteCode, questions, runConfig, c.Query("method"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(calibration)
+	})
+
+	app.Get("/calibrations/:sitecode", func(c *fiber.Ctx) error {
+		calibrations, appErr := categorySvc.GetCalibrationsSvc(c.Context(), c.Params("sitecode"))
+		if appErr != nil {
+			return c.Status(appErr.StatusCode).JSON(fiber.Map{"error": appErr.Message})
+		}
+		return c.JSON(calibrations)
 	})
 	log.Fatal(app.Listen(":3000"))
 }
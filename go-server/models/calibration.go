@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ScoreCalibration maps the raw scores of a site code's searches to the
+// probability that a result is relevant, fitted from labeled questions.
+// Raw scores mean different things per embedding model, retrieval mode and
+// reranker, so Model names all three and a site keeps one calibration per
+// model.
+type ScoreCalibration struct {
+	SiteCode string `bson:"site_code" json:"site_code"`
+	Model    string `bson:"model" json:"model"`
+	Method   string `bson:"method" json:"method"`
+	// Platt scaling: confidence = 1 / (1 + exp(PlattA * score + PlattB))
+	PlattA float64 `bson:"platt_a,omitempty" json:"platt_a,omitempty"`
+	PlattB float64 `bson:"platt_b,omitempty" json:"platt_b,omitempty"`
+	// Isotonic regression: confidence is interpolated between these points
+	IsotonicScores      []float64 `bson:"isotonic_scores,omitempty" json:"isotonic_scores,omitempty"`
+	IsotonicConfidences []float64 `bson:"isotonic_confidences,omitempty" json:"isotonic_confidences,omitempty"`
+	Samples             int       `bson:"samples" json:"samples"`
+	Relevant            int       `bson:"relevant" json:"relevant"`
+	BrierScore          float64   `bson:"brier_score" json:"brier_score"`
+	RunID               string    `bson:"run_id" json:"run_id"`
+	FittedAt            time.Time `bson:"fitted_at" json:"fitted_at"`
+}
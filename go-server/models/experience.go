@@ -554,6 +554,9 @@ type CatalogueDetails struct {
 	ImageURL    string `bson:"image_url" json:"image_url"`
 	ProductUrl  string `bson:"product_url" json:"product_url"`
 	Category    string `bson:"category" json:"category"`
+	// Attributes are extracted from name, description and category at
+	// ingestion, e.g. {"gender": ["women"], "material": ["cotton"]}.
+	Attributes map[string][]string `bson:"attributes,omitempty" json:"attributes,omitempty"`
 }
 
 type VideoGeneration struct {
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// GoldenExpected is a product a golden question expects, with the short
+// code its name resolved to and how relevant it is.
+type GoldenExpected struct {
+	Name string `bson:"name" json:"name"`
+	// ShortCode is empty when the name matched no mapped campaign, or more
+	// than one
+	ShortCode string `bson:"short_code" json:"short_code"`
+	// Relevance grades the product from 1 (relevant) to 3 (the answer)
+	Relevance int `bson:"relevance" json:"relevance"`
+}
+
+// GoldenQuestion is one labeled query of a site code's golden set. Its ID
+// is kept from version to version.
+type GoldenQuestion struct {
+	ID       string           `bson:"id" json:"id"`
+	Text     string           `bson:"text" json:"text"`
+	Expected []GoldenExpected `bson:"expected" json:"expected"`
+	Intent   string           `bson:"intent" json:"intent"`
+	Tags     []string         `bson:"tags" json:"tags"`
+}
+
+// GoldenSet is one version of the labeled questions of a site code. Every
+// change saves the whole set as the next version, so evaluation runs can
+// name the exact questions they were scored on.
+type GoldenSet struct {
+	SiteCode string `bson:"site_code" json:"site_code"`
+	Version  int    `bson:"version" json:"version"`
+	// MappingVersion is the mapping expected names were resolved against
+	MappingVersion int              `bson:"mapping_version" json:"mapping_version"`
+	Change         string           `bson:"change" json:"change"`
+	Questions      []GoldenQuestion `bson:"questions" json:"questions"`
+	Count          int              `bson:"count" json:"count"`
+	CreatedAt      time.Time        `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+type ShortCodeMapping struct {
+	ShortCode  string `bson:"short_code" json:"short_code"`
+	Name       string `bson:"name" json:"name"`
+	CampaignID string `bson:"_id" json:"campaign_id,omitempty"`
+}
+
+// MappingData is one version of a site code's short code to name mapping.
+// Versions start at 1 and are never rewritten, so queries and evaluation
+// runs can pin the exact mapping they used.
+type MappingData struct {
+	SiteCode    string             `bson:"site_code" json:"site_code"`
+	Version     int                `bson:"version" json:"version"`
+	Mappings    []ShortCodeMapping `bson:"mappings" json:"mappings"`
+	LastUpdated string             `bson:"last_updated" json:"last_updated"`
+	Count       int                `bson:"count" json:"count"`
+}
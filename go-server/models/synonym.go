@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Synonym is one entry of a site code's query expansion dictionary. A
+// two_way entry makes each of its synonyms expand to all the others; a
+// one_way entry expands Term to its synonyms but not back.
+type Synonym struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	SiteCode  string             `bson:"site_code" json:"site_code"`
+	Direction string             `bson:"direction" json:"direction"`
+	Term      string             `bson:"term,omitempty" json:"term,omitempty"`
+	Synonyms  []string           `bson:"synonyms" json:"synonyms"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -3,7 +3,37 @@ package consts
 const (
 
 	// Thresholds
-	SimilarityThreshold = 0.11
+	SimilarityThreshold  = 0.11
+	DefaultMinConfidence = 0.5
+
+	// Evaluation
+	DefaultEvalK         = 5
+	DefaultQuestionsFile = "../questions.txt"
+	EvalRunsDir          = "eval_runs"
+	// evaluation runs kept in memory; older ones are read back from disk
+	MaxCachedEvalRuns = 32
+	// results per question scored when fitting a calibration
+	DefaultCalibrationDepth = 20
+	// bounds of generated questions
+	DefaultGeneratedPerIntent = 50
+	DefaultGeneratedExpected  = 10
+
+	// Mappings
+	// pinned mapping versions kept in memory per process
+	MaxCachedMappingVersions = 64
+
+	// Batch search
+	DefaultBatchConcurrency = 8
+	MaxBatchConcurrency     = 32
+	MaxBatchQueries         = 500
+
+	// Search
+	SearchTopK        = 5
+	MaxTopK           = 100
+	LexicalCandidates = 20
+	MaxBrowseResults  = 50
+	// candidates retrieved per result kept, so filters have something left
+	RetrievalDepthFactor = 4
 
 	// routing prefix
 	RoutePrefix            = "campaign-svc"
@@ -27,6 +57,10 @@ const (
 	TemplateCollection          = "templates"
 	RemotionCollection          = "remotion"
 	CategoryCollection          = "category"
+	MappingCollection           = "short_code_mappings"
+	SynonymCollection           = "synonyms"
+	ScoreCalibrationCollection  = "score_calibrations"
+	GoldenSetCollection         = "golden_sets"
 
 	// Status
 	Created       = "CREATED"
@@ -94,6 +128,7 @@ FwIDAQAB
 	EditLogsSubject              = "EDITLOGS.logs"
 	ProductCatalogueSubject      = "product.catalogue.create"
 	ProductCatalogueStreamName   = "PRODUCT_CATALOGUE"
+	MappingUpdatedSubject        = "short.code.mapping.updated"
 
 	//overlay
 	OverlayImage      = "IMAGE"
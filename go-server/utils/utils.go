@@ -35,6 +35,28 @@ func ToPointer[T any](v T) *T {
 	return &v
 }
 
+// SplitList splits a comma separated query parameter, trimming spaces and
+// skipping empty values.
+func SplitList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// StripMilvusRefNo drops the " - <product id>" suffix catalogue campaigns
+// are named with.
+func StripMilvusRefNo(name string) string {
+	parts := strings.Split(name, " - ")
+	if len(parts) > 1 {
+		return parts[0]
+	}
+	return name
+}
+
 type User struct {
 	ID       string `json:"user_id"`
 	Email    string `json:"email"`